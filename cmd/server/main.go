@@ -1,18 +1,96 @@
 package main
 
 import (
+	"battleship-go/internal/chat"
+	"battleship-go/internal/store"
 	"battleship-go/internal/ws"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func main() {
+	// Persist matches to DATA_DIR when set, otherwise keep them in memory
+	if dir := os.Getenv("DATA_DIR"); dir != "" {
+		fs, err := store.NewFileStore(filepath.Join(dir, "matches.log"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer fs.Close()
+		ws.SetStore(fs)
+	}
+	if os.Getenv("ARCHIVE_DAYS") != "" {
+		store.ArchiveRetention = time.Duration(envInt("ARCHIVE_DAYS")) * 24 * time.Hour
+	}
+	policy, err := ws.ParseTimeoutPolicy(os.Getenv("TURN_TIMEOUT_POLICY"))
+	if err != nil {
+		log.Fatalf("TURN_TIMEOUT_POLICY: %v", err)
+	}
+	ws.DefaultClock = ws.ClockConfig{
+		TurnLimit:   envSeconds("TURN_SECONDS"),
+		TotalLimit:  envSeconds("CLOCK_SECONDS"),
+		Policy:      policy,
+		MaxTimeouts: envInt("MAX_TIMEOUTS"),
+	}
+	if os.Getenv("DISCONNECT_GRACE_SECONDS") != "" {
+		ws.DisconnectGrace = envSeconds("DISCONNECT_GRACE_SECONDS")
+	}
+	if words := os.Getenv("CHAT_BLOCKLIST"); words != "" {
+		ws.ChatFilter = chat.NewWordFilter(strings.Split(words, ",")...)
+	}
+	if os.Getenv("SPECTATOR_DELAY_SECONDS") != "" {
+		ws.SpectatorDelay = envSeconds("SPECTATOR_DELAY_SECONDS")
+	}
+	if casters := os.Getenv("CASTERS"); casters != "" {
+		ws.Casters = strings.Split(casters, ",")
+	}
+	if os.Getenv("CHALLENGE_TTL_SECONDS") != "" {
+		ws.ChallengeTTL = envSeconds("CHALLENGE_TTL_SECONDS")
+	}
+	if os.Getenv("TOURNAMENT_NO_SHOW_SECONDS") != "" {
+		ws.TournamentNoShow = envSeconds("TOURNAMENT_NO_SHOW_SECONDS")
+	}
+	if os.Getenv("TOURNAMENT_INVITE_SECONDS") != "" {
+		ws.TournamentInviteTTL = envSeconds("TOURNAMENT_INVITE_SECONDS")
+	}
+	if os.Getenv("SERIES_GAP_SECONDS") != "" {
+		ws.SeriesGap = envSeconds("SERIES_GAP_SECONDS")
+	}
+	if os.Getenv("QUEUE_BAND") != "" {
+		ws.QueueBand = envInt("QUEUE_BAND")
+	}
+	if os.Getenv("QUEUE_WIDEN") != "" {
+		ws.QueueWiden = envInt("QUEUE_WIDEN")
+	}
+	if os.Getenv("GUESTS") == "off" {
+		ws.AllowGuests = false
+	}
+	if err := ws.RestoreMatches(); err != nil {
+		log.Fatal(err)
+	}
+	if os.Getenv("BOTS") != "off" {
+		ws.SpawnBots()
+	}
+
 	mux := http.NewServeMux()
 	mux.HandleFunc("/ws", ws.HandleWS)
 	mux.HandleFunc("/api/players", ws.ListPlayersHandler)
 	mux.HandleFunc("/api/games", ws.ListGamesHandler)
+	mux.HandleFunc("GET /api/games/{id}/replay", ws.ReplayHandler)
+	mux.HandleFunc("/api/fleet/random", ws.RandomFleetHandler)
+	mux.HandleFunc("GET /api/profiles/{id}", ws.ProfileHandler)
+	mux.HandleFunc("GET /api/tournaments", ws.ListTournamentsHandler)
+	mux.HandleFunc("GET /api/tournaments/{id}", ws.TournamentHandler)
+	mux.HandleFunc("POST /api/register", ws.RegisterHandler)
+	mux.HandleFunc("POST /api/login", ws.LoginHandler)
+	mux.HandleFunc("POST /api/logout", ws.LogoutHandler)
+	mux.HandleFunc("GET /api/session", ws.SessionHandler)
+	mux.HandleFunc("/api/protocol/schema", ws.ProtocolSchemaHandler)
 	mux.Handle("/", http.FileServer(http.Dir("web")))
 
 	// Get port from environment variable or default to 8080
@@ -26,3 +104,20 @@ func main() {
 		log.Fatal(err)
 	}
 }
+
+// envInt reads a non-negative integer from the environment, 0 when unset.
+func envInt(name string) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return 0
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		log.Fatalf("%s: expected a non-negative integer, got %q", name, v)
+	}
+	return n
+}
+
+func envSeconds(name string) time.Duration {
+	return time.Duration(envInt(name)) * time.Second
+}
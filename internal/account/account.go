@@ -0,0 +1,59 @@
+// Package account checks registration input and hashes passwords. Account
+// records themselves are kept by the store package.
+package account
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	MinUsernameLength = 3
+	MaxUsernameLength = 20
+	MinPasswordLength = 8
+	// MaxPasswordLength is bcrypt's input limit.
+	MaxPasswordLength = 72
+)
+
+// Cost is the bcrypt work factor for new hashes. Existing hashes keep the
+// cost they were made with.
+var Cost = bcrypt.DefaultCost
+
+var (
+	ErrBadUsername    = errors.New("bad_username")
+	ErrWeakPassword   = errors.New("weak_password")
+	ErrBadCredentials = errors.New("bad_credentials")
+)
+
+// ValidateUsername accepts 3 to 20 letters, digits, '_' and '-'.
+func ValidateUsername(name string) error {
+	if len(name) < MinUsernameLength || len(name) > MaxUsernameLength {
+		return ErrBadUsername
+	}
+	for _, r := range name {
+		ok := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-'
+		if !ok {
+			return ErrBadUsername
+		}
+	}
+	return nil
+}
+
+// ValidatePassword enforces the length limits.
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// HashPassword returns a salted bcrypt hash of password.
+func HashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), Cost)
+}
+
+// CheckPassword reports whether password matches hash.
+func CheckPassword(hash []byte, password string) bool {
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
@@ -0,0 +1,130 @@
+// Package bot contains computer opponents. Strategies only see what a
+// human opponent would: their own shots and which ships were sunk.
+package bot
+
+import (
+	"errors"
+	"math/rand"
+
+	"battleship-go/internal/game"
+)
+
+// View is the shooter's knowledge of the opponent board.
+type View struct {
+	// Shots holds Hit or Miss for cells already fired at, Empty otherwise.
+	Shots game.Board
+	// Sunk marks cells belonging to ships already sunk, indexed [y][x].
+	Sunk [][]bool
+	// Remaining lists the sizes of ships still afloat.
+	Remaining []int
+}
+
+// NewView returns a blank view of an opponent playing under rules.
+func NewView(rules game.RuleSet) *View {
+	v := &View{Shots: rules.NewBoard(), Sunk: make([][]bool, rules.Height)}
+	for y := range v.Sunk {
+		v.Sunk[y] = make([]bool, rules.Width)
+	}
+	v.Remaining = rules.Sizes()
+	return v
+}
+
+// Record notes the outcome of one of our shots.
+func (v *View) Record(x, y int, hit bool) {
+	if hit {
+		v.Shots[y][x] = game.Hit
+	} else {
+		v.Shots[y][x] = game.Miss
+	}
+}
+
+// RecordSunk marks a sunk ship's cells and drops it from Remaining.
+func (v *View) RecordSunk(cells []game.Coord) {
+	for _, c := range cells {
+		v.Sunk[c.Y][c.X] = true
+		v.Shots[c.Y][c.X] = game.Hit
+	}
+	for i, size := range v.Remaining {
+		if size == len(cells) {
+			v.Remaining = append(v.Remaining[:i], v.Remaining[i+1:]...)
+			break
+		}
+	}
+}
+
+// RecordWater marks cells the rules revealed as empty, such as the
+// surroundings of a sunk ship when ships may not touch.
+func (v *View) RecordWater(cells []game.Coord) {
+	for _, c := range cells {
+		v.Shots[c.Y][c.X] = game.Miss
+	}
+}
+
+func (v *View) unknown(x, y int) bool {
+	return v.Shots.InBounds(x, y) && v.Shots[y][x] == game.Empty
+}
+
+// openHits returns hit cells that are not yet part of a sunk ship.
+func (v *View) openHits() []game.Coord {
+	var out []game.Coord
+	for y := range v.Shots {
+		for x := range v.Shots[y] {
+			if v.Shots[y][x] == game.Hit && !v.Sunk[y][x] {
+				out = append(out, game.Coord{X: x, Y: y})
+			}
+		}
+	}
+	return out
+}
+
+func (v *View) unknownCells() []game.Coord {
+	var out []game.Coord
+	for y := range v.Shots {
+		for x := range v.Shots[y] {
+			if v.Shots[y][x] == game.Empty {
+				out = append(out, game.Coord{X: x, Y: y})
+			}
+		}
+	}
+	return out
+}
+
+// Strategy picks the next cell to fire at.
+type Strategy interface {
+	Name() string
+	NextShot(v *View) game.Coord
+}
+
+// Difficulties lists the selectable strategies, easiest first.
+var Difficulties = []string{"easy", "medium", "hard"}
+
+// New returns the strategy for difficulty.
+func New(difficulty string, rng *rand.Rand) (Strategy, error) {
+	switch difficulty {
+	case "easy":
+		return &Random{rng: rng}, nil
+	case "medium":
+		return &HuntTarget{rng: rng}, nil
+	case "hard":
+		return &Probability{rng: rng}, nil
+	}
+	return nil, errors.New("unknown_difficulty")
+}
+
+// Volley asks s for n distinct shots at once, as a salvo needs. Picked cells
+// are treated as misses while choosing so no cell is picked twice.
+func Volley(s Strategy, v *View, n int) []game.Coord {
+	out := make([]game.Coord, 0, n)
+	for len(out) < n && len(v.unknownCells()) > 0 {
+		c := s.NextShot(v)
+		if !v.unknown(c.X, c.Y) {
+			break
+		}
+		v.Shots[c.Y][c.X] = game.Miss
+		out = append(out, c)
+	}
+	for _, c := range out {
+		v.Shots[c.Y][c.X] = game.Empty
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package bot
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"battleship-go/internal/game"
+)
+
+// play lets s fire at a random fleet until it is sunk and returns the
+// number of shots taken. Refused shots fail the test.
+func play(t *testing.T, s Strategy, seed int64) int {
+	t.Helper()
+	rules := game.DefaultRules()
+	fleet, err := game.RandomFleet(rand.New(rand.NewSource(seed)), rules, game.FleetConstraints{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := game.New(game.SideA, rules)
+	g.OpenPlacement()
+	g.PlaceFleet(game.SideA, fleet)
+	g.PlaceFleet(game.SideB, fleet)
+
+	v := NewView(rules)
+	for shots := 1; shots <= rules.Width*rules.Height; shots++ {
+		c := s.NextShot(v)
+		res, err := g.Fire(game.SideA, c.X, c.Y)
+		if err != nil {
+			t.Fatalf("%s, seed %d: shot %d at %v: %v", s.Name(), seed, shots, c, err)
+		}
+		v.Record(c.X, c.Y, res.Hit)
+		if res.Sunk != "" {
+			v.RecordSunk(res.SunkCells)
+		}
+		if res.GameOver {
+			return shots
+		}
+		if !res.Hit {
+			g.PassTurn()
+		}
+	}
+	t.Fatalf("%s, seed %d: fleet still afloat after every cell was shot", s.Name(), seed)
+	return 0
+}
+
+func TestStrategiesSinkTheFleet(t *testing.T) {
+	const games = 30
+	var avg []float64
+	for _, difficulty := range Difficulties {
+		s, err := New(difficulty, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		total := 0
+		for seed := int64(0); seed < games; seed++ {
+			total += play(t, s, seed)
+		}
+		avg = append(avg, float64(total)/games)
+	}
+	// every difficulty must beat the one below it on average
+	for i := 1; i < len(avg); i++ {
+		if avg[i] >= avg[i-1] {
+			t.Errorf("%s takes %.1f shots on average, %s %.1f", Difficulties[i], avg[i], Difficulties[i-1], avg[i-1])
+		}
+	}
+}
+
+func TestNewRejectsUnknownDifficulty(t *testing.T) {
+	if _, err := New("impossible", rand.New(rand.NewSource(1))); err == nil || err.Error() != "unknown_difficulty" {
+		t.Errorf("New(impossible) = %v", err)
+	}
+}
+
+func TestHuntTargetTargets(t *testing.T) {
+	tests := []struct {
+		name string
+		hits []game.Coord
+		want []game.Coord
+	}{
+		{"neighbours of a hit", []game.Coord{{X: 4, Y: 4}}, []game.Coord{{X: 3, Y: 4}, {X: 5, Y: 4}, {X: 4, Y: 3}, {X: 4, Y: 5}}},
+		{"both ends of a line", []game.Coord{{X: 4, Y: 4}, {X: 5, Y: 4}}, []game.Coord{{X: 3, Y: 4}, {X: 6, Y: 4}}},
+		{"along the edge", []game.Coord{{X: 0, Y: 0}, {X: 0, Y: 1}}, []game.Coord{{X: 0, Y: 2}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewView(game.DefaultRules())
+			for _, h := range tt.hits {
+				v.Record(h.X, h.Y, true)
+			}
+			s := &HuntTarget{rng: rand.New(rand.NewSource(1))}
+			for i := 0; i < 20; i++ {
+				if c := s.NextShot(v); !contains(tt.want, c) {
+					t.Fatalf("NextShot = %v, want one of %v", c, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestProbabilitySkipsCellsNoShipFits(t *testing.T) {
+	v := NewView(game.DefaultRules())
+	for y := range v.Shots {
+		for x := range v.Shots[y] {
+			v.Record(x, y, false)
+		}
+	}
+	// a lone hole and a three cell gap remain; only the gap fits a cruiser
+	v.Shots[0][0] = game.Empty
+	gap := []game.Coord{{X: 5, Y: 5}, {X: 6, Y: 5}, {X: 7, Y: 5}}
+	for _, c := range gap {
+		v.Shots[c.Y][c.X] = game.Empty
+	}
+	v.Remaining = []int{3}
+	s := &Probability{rng: rand.New(rand.NewSource(1))}
+	for i := 0; i < 20; i++ {
+		if c := s.NextShot(v); !contains(gap, c) {
+			t.Fatalf("NextShot = %v, want a cell of the gap", c)
+		}
+	}
+}
+
+func TestVolley(t *testing.T) {
+	rules := game.DefaultRules()
+	rules.Width, rules.Height = 5, 5
+	rules.Fleet = []game.ShipSpec{{Type: "boat", Size: 2, Count: 1}}
+	tests := []struct {
+		name    string
+		unknown int
+		n, want int
+	}{
+		{"full volley", 25, 5, 5},
+		{"fewer cells than shots", 3, 5, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewView(rules)
+			for i := tt.unknown; i < 25; i++ {
+				v.Record(i%5, i/5, false)
+			}
+			before := v.Shots.Clone()
+			for _, difficulty := range Difficulties {
+				s, _ := New(difficulty, rand.New(rand.NewSource(1)))
+				shots := Volley(s, v, tt.n)
+				seen := map[game.Coord]bool{}
+				for _, c := range shots {
+					if seen[c] || !v.unknown(c.X, c.Y) {
+						t.Fatalf("%s: volley %v repeats or reuses %v", difficulty, shots, c)
+					}
+					seen[c] = true
+				}
+				if len(shots) != tt.want {
+					t.Errorf("%s: %d shots, want %d", difficulty, len(shots), tt.want)
+				}
+				if !reflect.DeepEqual(v.Shots, before) {
+					t.Fatalf("%s: Volley left marks on the view", difficulty)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordSunk(t *testing.T) {
+	v := NewView(game.DefaultRules())
+	v.RecordSunk([]game.Coord{{X: 2, Y: 2}, {X: 3, Y: 2}, {X: 4, Y: 2}})
+	if want := []int{5, 4, 3, 2}; !reflect.DeepEqual(v.Remaining, want) {
+		t.Errorf("Remaining = %v, want %v", v.Remaining, want)
+	}
+	if !v.Sunk[2][3] || v.Shots[2][3] != game.Hit {
+		t.Error("sunk cells not marked")
+	}
+	v.RecordWater([]game.Coord{{X: 2, Y: 3}})
+	if v.unknown(2, 3) {
+		t.Error("water cell still unknown")
+	}
+}
+
+func contains(cells []game.Coord, c game.Coord) bool {
+	for _, x := range cells {
+		if x == c {
+			return true
+		}
+	}
+	return false
+}
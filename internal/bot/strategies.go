@@ -0,0 +1,161 @@
+package bot
+
+import (
+	"math/rand"
+
+	"battleship-go/internal/game"
+)
+
+// Random fires at any cell not yet shot.
+type Random struct {
+	rng *rand.Rand
+}
+
+func (s *Random) Name() string { return "easy" }
+
+func (s *Random) NextShot(v *View) game.Coord {
+	cells := v.unknownCells()
+	return cells[s.rng.Intn(len(cells))]
+}
+
+// HuntTarget hunts on a checkerboard until it scores a hit, then works the
+// neighbouring cells, following the line once two hits are aligned.
+type HuntTarget struct {
+	rng *rand.Rand
+}
+
+func (s *HuntTarget) Name() string { return "medium" }
+
+func (s *HuntTarget) NextShot(v *View) game.Coord {
+	if targets := s.targets(v); len(targets) > 0 {
+		return targets[s.rng.Intn(len(targets))]
+	}
+
+	var parity []game.Coord
+	for _, c := range v.unknownCells() {
+		if (c.X+c.Y)%2 == 0 {
+			parity = append(parity, c)
+		}
+	}
+	if len(parity) > 0 {
+		return parity[s.rng.Intn(len(parity))]
+	}
+	cells := v.unknownCells()
+	return cells[s.rng.Intn(len(cells))]
+}
+
+func (s *HuntTarget) targets(v *View) []game.Coord {
+	hits := v.openHits()
+	if len(hits) == 0 {
+		return nil
+	}
+
+	// prefer extending a line of two or more adjacent hits
+	var line []game.Coord
+	for _, h := range hits {
+		for _, d := range [][2]int{{1, 0}, {0, 1}} {
+			if !isOpenHit(v, h.X+d[0], h.Y+d[1]) {
+				continue
+			}
+			x, y := h.X, h.Y
+			for isOpenHit(v, x, y) {
+				x, y = x+d[0], y+d[1]
+			}
+			if v.unknown(x, y) {
+				line = append(line, game.Coord{X: x, Y: y})
+			}
+			x, y = h.X, h.Y
+			for isOpenHit(v, x, y) {
+				x, y = x-d[0], y-d[1]
+			}
+			if v.unknown(x, y) {
+				line = append(line, game.Coord{X: x, Y: y})
+			}
+		}
+	}
+	if len(line) > 0 {
+		return line
+	}
+
+	var adj []game.Coord
+	for _, h := range hits {
+		for _, d := range [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}} {
+			if v.unknown(h.X+d[0], h.Y+d[1]) {
+				adj = append(adj, game.Coord{X: h.X + d[0], Y: h.Y + d[1]})
+			}
+		}
+	}
+	return adj
+}
+
+func isOpenHit(v *View, x, y int) bool {
+	return v.Shots.InBounds(x, y) && v.Shots[y][x] == game.Hit && !v.Sunk[y][x]
+}
+
+// Probability counts, for every unknown cell, how many legal positions of
+// the remaining ships cover it, weighting positions through open hits.
+type Probability struct {
+	rng *rand.Rand
+}
+
+func (s *Probability) Name() string { return "hard" }
+
+// hitWeight boosts placements that explain an unresolved hit.
+const hitWeight = 20
+
+func (s *Probability) NextShot(v *View) game.Coord {
+	density := make([][]int, v.Shots.Height())
+	for y := range density {
+		density[y] = make([]int, v.Shots.Width())
+	}
+	for _, size := range v.Remaining {
+		for y := range v.Shots {
+			for x := range v.Shots[y] {
+				for _, dir := range []string{"H", "V"} {
+					cells := game.Placement{X: x, Y: y, Dir: dir}.Cells(size)
+					weight, ok := placementWeight(v, cells)
+					if !ok {
+						continue
+					}
+					for _, c := range cells {
+						if v.Shots[c.Y][c.X] == game.Empty {
+							density[c.Y][c.X] += weight
+						}
+					}
+				}
+			}
+		}
+	}
+
+	best := -1
+	var picks []game.Coord
+	for _, c := range v.unknownCells() {
+		d := density[c.Y][c.X]
+		switch {
+		case d > best:
+			best = d
+			picks = []game.Coord{c}
+		case d == best:
+			picks = append(picks, c)
+		}
+	}
+	return picks[s.rng.Intn(len(picks))]
+}
+
+// placementWeight rejects positions off the board or through misses and
+// sunk ships, and scores the rest by how many open hits they cover.
+func placementWeight(v *View, cells []game.Coord) (int, bool) {
+	weight := 1
+	for _, c := range cells {
+		if !v.Shots.InBounds(c.X, c.Y) {
+			return 0, false
+		}
+		if v.Shots[c.Y][c.X] == game.Miss || v.Sunk[c.Y][c.X] {
+			return 0, false
+		}
+		if v.Shots[c.Y][c.X] == game.Hit {
+			weight *= hitWeight
+		}
+	}
+	return weight, true
+}
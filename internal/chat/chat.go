@@ -0,0 +1,165 @@
+// Package chat holds the transport-independent parts of lobby and match
+// chat: text filters, per-sender rate limiting and bounded history.
+package chat
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"battleship-go/internal/protocol"
+)
+
+var (
+	ErrEmpty   = errors.New("empty_message")
+	ErrTooLong = errors.New("message_too_long")
+)
+
+// Filter moderates a chat line before it is delivered. It may rewrite the
+// text or reject it outright with an error that is reported to the sender.
+type Filter interface {
+	Clean(text string) (string, error)
+}
+
+// Normalize trims text and enforces the length limit in runes.
+func Normalize(text string, maxLen int) (string, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", ErrEmpty
+	}
+	if utf8.RuneCountInString(text) > maxLen {
+		return "", ErrTooLong
+	}
+	return text, nil
+}
+
+// WordFilter masks listed words (case-insensitively, as whole words) with
+// asterisks.
+type WordFilter struct {
+	words map[string]bool
+}
+
+func NewWordFilter(words ...string) *WordFilter {
+	f := &WordFilter{words: make(map[string]bool, len(words))}
+	for _, w := range words {
+		if w = strings.ToLower(strings.TrimSpace(w)); w != "" {
+			f.words[w] = true
+		}
+	}
+	return f
+}
+
+func (f *WordFilter) Clean(text string) (string, error) {
+	if len(f.words) == 0 {
+		return text, nil
+	}
+	var out strings.Builder
+	word := []rune{}
+	flush := func() {
+		if f.words[strings.ToLower(string(word))] {
+			out.WriteString(strings.Repeat("*", len(word)))
+		} else {
+			out.WriteString(string(word))
+		}
+		word = word[:0]
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word = append(word, r)
+			continue
+		}
+		flush()
+		out.WriteRune(r)
+	}
+	flush()
+	return out.String(), nil
+}
+
+// Limiter is a token bucket per sender: Burst messages at once, refilled
+// at one message every Every.
+type Limiter struct {
+	Burst int
+	Every time.Duration
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func NewLimiter(burst int, every time.Duration) *Limiter {
+	return &Limiter{Burst: burst, Every: every, buckets: make(map[string]*bucket)}
+}
+
+// Allow spends one token for id, reporting false when it has none left.
+func (l *Limiter) Allow(id string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	b, ok := l.buckets[id]
+	if !ok {
+		b = &bucket{tokens: float64(l.Burst), last: now}
+		l.buckets[id] = b
+	}
+	if l.Every > 0 {
+		b.tokens += float64(now.Sub(b.last)) / float64(l.Every)
+		if b.tokens > float64(l.Burst) {
+			b.tokens = float64(l.Burst)
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Forget drops the bucket of a sender that has left.
+func (l *Limiter) Forget(id string) {
+	l.mu.Lock()
+	delete(l.buckets, id)
+	l.mu.Unlock()
+}
+
+// History keeps the most recent messages of each channel.
+type History struct {
+	Size int
+
+	mu       sync.Mutex
+	channels map[string][]protocol.ChatMessage
+}
+
+func NewHistory(size int) *History {
+	return &History{Size: size, channels: make(map[string][]protocol.ChatMessage)}
+}
+
+func (h *History) Add(channel string, m protocol.ChatMessage) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	msgs := append(h.channels[channel], m)
+	if len(msgs) > h.Size {
+		msgs = append([]protocol.ChatMessage(nil), msgs[len(msgs)-h.Size:]...)
+	}
+	h.channels[channel] = msgs
+}
+
+// Recent returns a copy of the channel's history, oldest first.
+func (h *History) Recent(channel string) []protocol.ChatMessage {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]protocol.ChatMessage{}, h.channels[channel]...)
+}
+
+// Drop forgets a channel, e.g. once its match is evicted.
+func (h *History) Drop(channel string) {
+	h.mu.Lock()
+	delete(h.channels, channel)
+	h.mu.Unlock()
+}
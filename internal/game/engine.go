@@ -0,0 +1,392 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrOutOfBounds = errors.New("out_of_bounds")
+	ErrNotYourTurn = errors.New("not_your_turn")
+	ErrAlreadyShot = errors.New("already_shot")
+	ErrWrongMode   = errors.New("wrong_mode")
+)
+
+// PhaseError rejects an action that the current lifecycle phase forbids.
+type PhaseError struct {
+	Phase MatchState
+}
+
+func (e *PhaseError) Error() string {
+	return "wrong_phase:" + e.Phase.String()
+}
+
+// require returns a PhaseError unless the game is in one of phases.
+func (g *Game) require(phases ...MatchState) error {
+	for _, p := range phases {
+		if g.state == p {
+			return nil
+		}
+	}
+	return &PhaseError{Phase: g.state}
+}
+
+// Stats counts one side's shooting over the match.
+type Stats struct {
+	Shots     int `json:"shots"`
+	Hits      int `json:"hits"`
+	ShipsSunk int `json:"ships_sunk"`
+}
+
+// PlacedShip tracks one ship of a fleet and how many of its cells were hit.
+type PlacedShip struct {
+	Type  string  `json:"type"`
+	Cells []Coord `json:"cells"`
+	Hits  int     `json:"hits"`
+}
+
+// Sunk reports whether every cell of the ship was hit.
+func (s *PlacedShip) Sunk() bool {
+	return s.Hits >= len(s.Cells)
+}
+
+// PlaceResult is returned by PlaceFleet.
+type PlaceResult struct {
+	Side    PlayerSide
+	Started bool
+	Turn    PlayerSide
+}
+
+// ShotResult describes the outcome of a single Fire call.
+type ShotResult struct {
+	Shooter   PlayerSide
+	X         int
+	Y         int
+	Hit       bool
+	Sunk      string
+	SunkCells []Coord
+	// Water lists the cells around a sunk ship that were marked Miss
+	// under a spacing rule.
+	Water    []Coord
+	GameOver bool
+	Winner   PlayerSide
+	NextTurn PlayerSide
+}
+
+// SalvoResult describes a whole volley fired with FireSalvo. Shots lists
+// each resolved shot in order; a volley that sinks the last ship stops
+// there.
+type SalvoResult struct {
+	Shooter  PlayerSide
+	Shots    []ShotResult
+	GameOver bool
+	Winner   PlayerSide
+	NextTurn PlayerSide
+}
+
+// Game is the transport-free rules engine for a single match. It is not
+// safe for concurrent use; callers serialise access.
+type Game struct {
+	boards [2]Board
+	ships  [2][]*PlacedShip
+	placed [2]bool
+	turn   PlayerSide
+	state  MatchState
+	winner PlayerSide
+	stats  [2]Stats
+	rules  RuleSet
+}
+
+// New returns a game in the Created phase. first shoots first once both
+// fleets are placed. A zero rules value means DefaultRules; callers
+// validate anything else.
+func New(first PlayerSide, rules RuleSet) *Game {
+	if rules.Width == 0 {
+		rules = DefaultRules()
+	}
+	g := &Game{turn: first, state: Created, rules: rules}
+	g.boards = [2]Board{rules.NewBoard(), rules.NewBoard()}
+	return g
+}
+
+// Rules returns the rule set the game was created with.
+func (g *Game) Rules() RuleSet {
+	return g.rules
+}
+
+// OpenPlacement moves a created game into the Placing phase.
+func (g *Game) OpenPlacement() error {
+	if err := g.require(Created); err != nil {
+		return err
+	}
+	g.state = Placing
+	return nil
+}
+
+// PlaceFleet validates and stores a fleet for side. A fleet may be replaced
+// until the battle starts.
+func (g *Game) PlaceFleet(side PlayerSide, placements []Placement) (PlaceResult, error) {
+	if err := g.require(Placing); err != nil {
+		return PlaceResult{}, err
+	}
+	board, err := BuildBoardFromPlacements(g.rules, placements)
+	if err != nil {
+		return PlaceResult{}, err
+	}
+
+	ships := make([]*PlacedShip, 0, len(placements))
+	for _, p := range placements {
+		size, _ := g.rules.ShipSize(p.Type)
+		ships = append(ships, &PlacedShip{Type: p.Type, Cells: p.Cells(size)})
+	}
+	g.boards[side] = board
+	g.ships[side] = ships
+	g.placed[side] = true
+
+	res := PlaceResult{Side: side}
+	if g.placed[SideA] && g.placed[SideB] {
+		g.state = Battle
+		res.Started = true
+		res.Turn = g.turn
+	}
+	return res, nil
+}
+
+// Fire resolves a shot by shooter at (x, y) on the opponent's board. A miss
+// passes the turn; so does a hit unless the rules grant an extra shot.
+func (g *Game) Fire(shooter PlayerSide, x, y int) (ShotResult, error) {
+	if err := g.require(Battle); err != nil {
+		return ShotResult{}, err
+	}
+	if !g.boards[shooter.Opponent()].InBounds(x, y) {
+		return ShotResult{}, ErrOutOfBounds
+	}
+	if g.rules.Mode != ModeClassic {
+		return ShotResult{}, ErrWrongMode
+	}
+	if g.turn != shooter {
+		return ShotResult{}, ErrNotYourTurn
+	}
+	target := shooter.Opponent()
+	if c := g.boards[target][y][x]; c == Hit || c == Miss {
+		return ShotResult{}, ErrAlreadyShot
+	}
+
+	res := g.resolve(shooter, x, y)
+	if !res.GameOver && (!res.Hit || !g.rules.ExtraTurnOnHit) {
+		g.turn = target
+	}
+	res.NextTurn = g.turn
+	return res, nil
+}
+
+// FireSalvo resolves a whole volley at once. Every shot is validated before
+// any is applied, and the turn passes afterwards whatever was hit.
+func (g *Game) FireSalvo(shooter PlayerSide, shots []Coord) (SalvoResult, error) {
+	if err := g.require(Battle); err != nil {
+		return SalvoResult{}, err
+	}
+	if g.rules.Mode != ModeSalvo {
+		return SalvoResult{}, ErrWrongMode
+	}
+	if g.turn != shooter {
+		return SalvoResult{}, ErrNotYourTurn
+	}
+	target := shooter.Opponent()
+	want := g.SalvoSize(shooter)
+	if open := len(g.OpenCells(target)); open < want {
+		want = open
+	}
+	if len(shots) != want {
+		return SalvoResult{}, fmt.Errorf("salvo_size:%d", want)
+	}
+	seen := map[Coord]bool{}
+	for _, c := range shots {
+		if !g.boards[target].InBounds(c.X, c.Y) {
+			return SalvoResult{}, ErrOutOfBounds
+		}
+		if b := g.boards[target][c.Y][c.X]; b == Hit || b == Miss || seen[c] {
+			return SalvoResult{}, ErrAlreadyShot
+		}
+		seen[c] = true
+	}
+
+	res := SalvoResult{Shooter: shooter}
+	for _, c := range shots {
+		shot := g.resolve(shooter, c.X, c.Y)
+		res.Shots = append(res.Shots, shot)
+		if shot.GameOver {
+			res.GameOver = true
+			res.Winner = shooter
+			break
+		}
+	}
+	if !res.GameOver {
+		g.turn = target
+	}
+	res.NextTurn = g.turn
+	for i := range res.Shots {
+		res.Shots[i].NextTurn = g.turn
+	}
+	return res, nil
+}
+
+// SalvoSize is how many shots side fires per volley: the fixed SalvoShots
+// when set, otherwise one per ship still afloat.
+func (g *Game) SalvoSize(side PlayerSide) int {
+	if g.rules.SalvoShots > 0 {
+		return g.rules.SalvoShots
+	}
+	n := 0
+	for _, s := range g.ships[side] {
+		if !s.Sunk() {
+			n++
+		}
+	}
+	return n
+}
+
+// resolve applies one already validated shot and ends the game when it
+// destroys the last ship. Turn handling is left to the caller.
+func (g *Game) resolve(shooter PlayerSide, x, y int) ShotResult {
+	target := shooter.Opponent()
+	board := g.boards[target]
+	res := ShotResult{Shooter: shooter, X: x, Y: y}
+
+	switch board[y][x] {
+	case Ship:
+		board[y][x] = Hit
+		res.Hit = true
+		g.stats[shooter].Hits++
+		if s := g.shipAt(target, x, y); s != nil {
+			s.Hits++
+			if s.Sunk() {
+				res.Sunk = s.Type
+				res.SunkCells = append([]Coord(nil), s.Cells...)
+				g.stats[shooter].ShipsSunk++
+				for _, c := range g.rules.Surrounding(s.Cells) {
+					if board[c.Y][c.X] == Empty {
+						board[c.Y][c.X] = Miss
+						res.Water = append(res.Water, c)
+					}
+				}
+			}
+		}
+	default:
+		board[y][x] = Miss
+	}
+	g.stats[shooter].Shots++
+
+	if g.fleetDestroyed(target) {
+		g.state = Finished
+		g.winner = shooter
+		res.GameOver = true
+		res.Winner = shooter
+	}
+	return res
+}
+
+func (g *Game) shipAt(side PlayerSide, x, y int) *PlacedShip {
+	for _, s := range g.ships[side] {
+		for _, c := range s.Cells {
+			if c.X == x && c.Y == y {
+				return s
+			}
+		}
+	}
+	return nil
+}
+
+func (g *Game) fleetDestroyed(side PlayerSide) bool {
+	for _, row := range g.boards[side] {
+		for _, c := range row {
+			if c == Ship {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// PassTurn hands the turn to the other side without a shot, e.g. when the
+// turn clock runs out.
+func (g *Game) PassTurn() (PlayerSide, error) {
+	if err := g.require(Battle); err != nil {
+		return g.turn, err
+	}
+	g.turn = g.turn.Opponent()
+	return g.turn, nil
+}
+
+// Forfeit ends the game in favour of loser's opponent.
+func (g *Game) Forfeit(loser PlayerSide) error {
+	if g.state.Over() {
+		return &PhaseError{Phase: g.state}
+	}
+	g.state = Finished
+	g.winner = loser.Opponent()
+	return nil
+}
+
+// Abandon ends the game without a winner.
+func (g *Game) Abandon() error {
+	if g.state.Over() {
+		return &PhaseError{Phase: g.state}
+	}
+	g.state = Abandoned
+	return nil
+}
+
+// Stats returns side's shooting statistics.
+func (g *Game) Stats(side PlayerSide) Stats {
+	return g.stats[side]
+}
+
+// OpenCells lists the cells on target's board that have not been shot.
+func (g *Game) OpenCells(target PlayerSide) []Coord {
+	var out []Coord
+	for y, row := range g.boards[target] {
+		for x, c := range row {
+			if c == Empty || c == Ship {
+				out = append(out, Coord{X: x, Y: y})
+			}
+		}
+	}
+	return out
+}
+
+// Winner returns the winning side once the game is finished.
+func (g *Game) Winner() (PlayerSide, bool) {
+	return g.winner, g.state == Finished
+}
+
+// State returns the current phase of the game.
+func (g *Game) State() MatchState {
+	return g.state
+}
+
+// Turn returns the side due to shoot.
+func (g *Game) Turn() PlayerSide {
+	return g.turn
+}
+
+// Ready reports whether side has placed a fleet.
+func (g *Game) Ready(side PlayerSide) bool {
+	return g.placed[side]
+}
+
+// Board returns a copy of side's own board, ships included.
+func (g *Game) Board(side PlayerSide) Board {
+	return g.boards[side].Clone()
+}
+
+// Ships returns a copy of side's fleet.
+func (g *Game) Ships(side PlayerSide) []PlacedShip {
+	out := make([]PlacedShip, 0, len(g.ships[side]))
+	for _, s := range g.ships[side] {
+		cp := *s
+		cp.Cells = append([]Coord(nil), s.Cells...)
+		out = append(out, cp)
+	}
+	return out
+}
@@ -0,0 +1,254 @@
+package game
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPlaceFleet(t *testing.T) {
+	spaced := DefaultRules()
+	spaced.Spacing = SpacingEdges
+	cornered := DefaultRules()
+	cornered.Spacing = SpacingCorners
+	// stacked puts the carrier and battleship on neighbouring rows
+	stacked := classicFleet()
+	stacked[1].Y = 1
+	// diagonal puts the battleship corner to corner with the carrier
+	diagonal := classicFleet()
+	diagonal[1].X, diagonal[1].Y = 5, 1
+
+	with := func(i int, p Placement) []Placement {
+		out := classicFleet()
+		out[i] = p
+		return out
+	}
+	tests := []struct {
+		name  string
+		rules RuleSet
+		ships []Placement
+		err   string
+	}{
+		{"classic", DefaultRules(), classicFleet(), ""},
+		{"vertical", DefaultRules(), with(4, Placement{Type: "destroyer", X: 9, Y: 8, Dir: "V"}), ""},
+		{"bad direction", DefaultRules(), with(4, Placement{Type: "destroyer", X: 0, Y: 8, Dir: "D"}), "invalid_direction"},
+		{"off the board", DefaultRules(), with(0, Placement{Type: "carrier", X: 6, Y: 0, Dir: "H"}), "out_of_bounds:carrier"},
+		{"negative", DefaultRules(), with(4, Placement{Type: "destroyer", X: -1, Y: 8, Dir: "H"}), "out_of_bounds:destroyer"},
+		{"overlap", DefaultRules(), with(4, Placement{Type: "destroyer", X: 0, Y: 0, Dir: "V"}), "overlap"},
+		{"unknown type", DefaultRules(), with(4, Placement{Type: "canoe", X: 0, Y: 8, Dir: "H"}), "unknown_ship_type:canoe"},
+		{"missing ship", DefaultRules(), classicFleet()[:4], "invalid_fleet"},
+		{"duplicate ship", DefaultRules(), with(4, Placement{Type: "carrier", X: 0, Y: 9, Dir: "H"}), "invalid_fleet"},
+		{"flush without spacing", DefaultRules(), stacked, ""},
+		{"touching edges", spaced, stacked, "ships_touching:carrier,battleship"},
+		{"diagonal under edge spacing", spaced, diagonal, ""},
+		{"touching corners", cornered, diagonal, "ships_touching:carrier,battleship"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New(SideA, tt.rules)
+			g.OpenPlacement()
+			res, err := g.PlaceFleet(SideA, tt.ships)
+			if got := errString(err); got != tt.err {
+				t.Fatalf("PlaceFleet error = %q, want %q", got, tt.err)
+			}
+			if g.Ready(SideA) != (tt.err == "") || res.Started {
+				t.Errorf("ready = %v, started = %v", g.Ready(SideA), res.Started)
+			}
+		})
+	}
+}
+
+func TestFire(t *testing.T) {
+	noExtra := DefaultRules()
+	noExtra.ExtraTurnOnHit = false
+	salvo := DefaultRules()
+	salvo.Mode = ModeSalvo
+	spaced := DefaultRules()
+	spaced.Spacing = SpacingCorners
+
+	type shot struct {
+		side PlayerSide
+		x, y int
+	}
+	tests := []struct {
+		name  string
+		rules RuleSet
+		// before are fired first and must succeed
+		before []shot
+		shot   shot
+		err    error
+		hit    bool
+		sunk   string
+		water  int
+		next   PlayerSide
+	}{
+		{"miss passes the turn", DefaultRules(), nil, shot{SideA, 9, 9}, nil, false, "", 0, SideB},
+		{"hit shoots again", DefaultRules(), nil, shot{SideA, 0, 0}, nil, true, "", 0, SideA},
+		{"hit passes without extra turns", noExtra, nil, shot{SideA, 0, 0}, nil, true, "", 0, SideB},
+		{"sinking", DefaultRules(), []shot{{SideA, 0, 8}}, shot{SideA, 1, 8}, nil, true, "destroyer", 0, SideA},
+		{"sinking marks the water", spaced, []shot{{SideA, 0, 8}}, shot{SideA, 1, 8}, nil, true, "destroyer", 7, SideA},
+		{"out of turn", DefaultRules(), nil, shot{SideB, 0, 0}, ErrNotYourTurn, false, "", 0, SideA},
+		{"twice", DefaultRules(), []shot{{SideA, 0, 0}}, shot{SideA, 0, 0}, ErrAlreadyShot, false, "", 0, SideA},
+		{"off the board", DefaultRules(), nil, shot{SideA, 10, 0}, ErrOutOfBounds, false, "", 0, SideA},
+		{"salvo game", salvo, nil, shot{SideA, 0, 0}, ErrWrongMode, false, "", 0, SideA},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := battle(t, tt.rules)
+			for _, s := range tt.before {
+				if _, err := g.Fire(s.side, s.x, s.y); err != nil {
+					t.Fatal(err)
+				}
+			}
+			res, err := g.Fire(tt.shot.side, tt.shot.x, tt.shot.y)
+			if err != tt.err {
+				t.Fatalf("Fire error = %v, want %v", err, tt.err)
+			}
+			if res.Hit != tt.hit || res.Sunk != tt.sunk || len(res.Water) != tt.water {
+				t.Errorf("Fire = hit %v, sunk %q, water %v", res.Hit, res.Sunk, res.Water)
+			}
+			if g.Turn() != tt.next {
+				t.Errorf("turn = %v, want %v", g.Turn(), tt.next)
+			}
+		})
+	}
+}
+
+func TestLastShipWins(t *testing.T) {
+	g := battle(t, DefaultRules())
+	var res ShotResult
+	for _, p := range classicFleet() {
+		size, _ := DefaultRules().ShipSize(p.Type)
+		for _, c := range p.Cells(size) {
+			var err error
+			if res, err = g.Fire(SideA, c.X, c.Y); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if !res.GameOver || res.Winner != SideA || g.State() != Finished {
+		t.Fatalf("after sinking every ship: %+v, state %v", res, g.State())
+	}
+	if w, ok := g.Winner(); !ok || w != SideA {
+		t.Errorf("Winner = %v, %v", w, ok)
+	}
+	if s := g.Stats(SideA); s.Shots != 17 || s.Hits != 17 || s.ShipsSunk != 5 {
+		t.Errorf("stats = %+v", s)
+	}
+	if _, err := g.Fire(SideA, 9, 9); !isPhase(err, Finished) {
+		t.Errorf("shot after the end: %v", err)
+	}
+}
+
+func TestFireSalvo(t *testing.T) {
+	perShip := DefaultRules()
+	perShip.Mode = ModeSalvo
+	fixed := perShip
+	fixed.SalvoShots = 2
+
+	tests := []struct {
+		name  string
+		rules RuleSet
+		side  PlayerSide
+		shots []Coord
+		err   string
+		hits  int
+	}{
+		{"one per ship", perShip, SideA, []Coord{{0, 0}, {9, 9}, {9, 8}, {9, 7}, {0, 8}}, "", 2},
+		{"fixed", fixed, SideA, []Coord{{0, 0}, {9, 9}}, "", 1},
+		{"too few", perShip, SideA, []Coord{{0, 0}}, "salvo_size:5", 0},
+		{"too many", fixed, SideA, []Coord{{0, 0}, {1, 0}, {2, 0}}, "salvo_size:2", 0},
+		{"same cell twice", fixed, SideA, []Coord{{0, 0}, {0, 0}}, "already_shot", 0},
+		{"off the board", fixed, SideA, []Coord{{0, 0}, {0, 10}}, "out_of_bounds", 0},
+		{"out of turn", fixed, SideB, []Coord{{0, 0}, {1, 0}}, "not_your_turn", 0},
+		{"classic game", DefaultRules(), SideA, []Coord{{0, 0}}, "wrong_mode", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := battle(t, tt.rules)
+			res, err := g.FireSalvo(tt.side, tt.shots)
+			if got := errString(err); got != tt.err {
+				t.Fatalf("FireSalvo error = %q, want %q", got, tt.err)
+			}
+			if err != nil {
+				if g.Stats(tt.side).Shots != 0 {
+					t.Error("a rejected volley fired shots")
+				}
+				return
+			}
+			hits := 0
+			for _, s := range res.Shots {
+				if s.Hit {
+					hits++
+				}
+			}
+			if len(res.Shots) != len(tt.shots) || hits != tt.hits || g.Turn() != SideB {
+				t.Errorf("FireSalvo = %d shots, %d hits, turn %v", len(res.Shots), hits, g.Turn())
+			}
+		})
+	}
+}
+
+func TestSalvoShrinksWithTheFleet(t *testing.T) {
+	rules := DefaultRules()
+	rules.Mode = ModeSalvo
+	g := battle(t, rules)
+	// sink B's destroyer and cruiser with A's first volley
+	if _, err := g.FireSalvo(SideA, []Coord{{0, 8}, {1, 8}, {0, 4}, {1, 4}, {2, 4}}); err != nil {
+		t.Fatal(err)
+	}
+	if n := g.SalvoSize(SideB); n != 3 {
+		t.Errorf("B fires %d shots, want 3", n)
+	}
+	if n := g.SalvoSize(SideA); n != 5 {
+		t.Errorf("A fires %d shots, want 5", n)
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	tests := []struct {
+		name   string
+		end    func(g *Game) error
+		state  MatchState
+		winner bool
+	}{
+		{"forfeit", func(g *Game) error { return g.Forfeit(SideA) }, Finished, true},
+		{"abandon", func(g *Game) error { return g.Abandon() }, Abandoned, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := New(SideA, DefaultRules())
+			if _, err := g.Fire(SideA, 0, 0); !isPhase(err, Created) {
+				t.Errorf("shot before placement: %v", err)
+			}
+			if _, err := g.PlaceFleet(SideA, classicFleet()); !isPhase(err, Created) {
+				t.Errorf("placement before it opens: %v", err)
+			}
+			g.OpenPlacement()
+			if err := tt.end(g); err != nil {
+				t.Fatal(err)
+			}
+			w, ok := g.Winner()
+			if g.State() != tt.state || ok != tt.winner || (ok && w != SideB) {
+				t.Errorf("state %v, winner %v %v", g.State(), w, ok)
+			}
+			if err := tt.end(g); !isPhase(err, tt.state) {
+				t.Errorf("ending twice: %v", err)
+			}
+			if _, err := g.PassTurn(); !isPhase(err, tt.state) {
+				t.Errorf("passing after the end: %v", err)
+			}
+		})
+	}
+}
+
+func errString(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+func isPhase(err error, phase MatchState) bool {
+	var pe *PhaseError
+	return errors.As(err, &pe) && pe.Phase == phase
+}
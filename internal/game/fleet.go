@@ -0,0 +1,76 @@
+package game
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Placement is one ship as submitted by a client.
+type Placement struct {
+	Type string `json:"type"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	Dir  string `json:"dir"`
+}
+
+// Cells returns the coordinates covered by the placement. Direction is
+// not validated here.
+func (p Placement) Cells(size int) []Coord {
+	out := make([]Coord, 0, size)
+	for i := 0; i < size; i++ {
+		if p.Dir == "H" || p.Dir == "h" {
+			out = append(out, Coord{X: p.X + i, Y: p.Y})
+		} else {
+			out = append(out, Coord{X: p.X, Y: p.Y + i})
+		}
+	}
+	return out
+}
+
+// BuildBoardFromPlacements lays ships out on a board of the rule set's
+// size, checking bounds, overlaps, spacing and that the fleet matches
+// rules.Fleet.
+func BuildBoardFromPlacements(rules RuleSet, ships []Placement) (Board, error) {
+	b := rules.NewBoard()
+	seen := map[string]int{}
+	owner := map[Coord]string{}
+	for _, s := range ships {
+		size, ok := rules.ShipSize(s.Type)
+		if !ok {
+			return b, errors.New("unknown_ship_type:" + s.Type)
+		}
+		seen[s.Type]++
+
+		if s.Dir != "H" && s.Dir != "V" {
+			return b, errors.New("invalid_direction")
+		}
+		cells := s.Cells(size)
+		for _, c := range cells {
+			if !b.InBounds(c.X, c.Y) {
+				return b, errors.New("out_of_bounds:" + s.Type)
+			}
+		}
+		for _, c := range cells {
+			if b[c.Y][c.X] != Empty {
+				return b, errors.New("overlap")
+			}
+			b[c.Y][c.X] = Ship
+		}
+		for _, c := range rules.Surrounding(cells) {
+			if other, ok := owner[c]; ok {
+				return b, fmt.Errorf("ships_touching:%s,%s", other, s.Type)
+			}
+		}
+		for _, c := range cells {
+			owner[c] = s.Type
+		}
+	}
+
+	for _, spec := range rules.Fleet {
+		if seen[spec.Type] != spec.Count {
+			return b, errors.New("invalid_fleet")
+		}
+	}
+
+	return b, nil
+}
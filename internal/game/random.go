@@ -0,0 +1,109 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+)
+
+// ErrUnplaceable is returned when no fleet satisfying the rules and
+// constraints turned up.
+var ErrUnplaceable = errors.New("fleet_unplaceable")
+
+// fleetAttempts bounds how many whole fleets RandomFleet tries.
+const fleetAttempts = 200
+
+// FleetConstraints narrow what RandomFleet may generate beyond what the
+// rules require.
+type FleetConstraints struct {
+	// Spacing keeps ships apart even when the rules let them touch.
+	Spacing Spacing `json:"spacing,omitempty"`
+	// AvoidEdges keeps ships off the outermost rows and columns.
+	AvoidEdges bool `json:"avoid_edges,omitempty"`
+}
+
+// RandomFleet places the fleet of rules at random, validating the result
+// with BuildBoardFromPlacements. The same rng state, rules and constraints
+// always give the same fleet.
+func RandomFleet(rng *rand.Rand, rules RuleSet, c FleetConstraints) ([]Placement, error) {
+	spaced := rules
+	switch c.Spacing {
+	case SpacingAny:
+	case SpacingEdges:
+		if spaced.Spacing == SpacingAny {
+			spaced.Spacing = SpacingEdges
+		}
+	case SpacingCorners:
+		spaced.Spacing = SpacingCorners
+	default:
+		return nil, errors.New("unknown_spacing:" + string(c.Spacing))
+	}
+
+	for attempt := 0; attempt < fleetAttempts; attempt++ {
+		occupied := rules.NewBoard()
+		fleet := make([]Placement, 0, len(rules.Fleet))
+		ok := true
+		for _, spec := range rules.Fleet {
+			for n := 0; n < spec.Count && ok; n++ {
+				p, placed := placeOne(rng, occupied, spec, c.AvoidEdges)
+				if !placed {
+					ok = false
+					break
+				}
+				fleet = append(fleet, p)
+				// keep the next ships clear of this one's surroundings
+				for _, cell := range spaced.Surrounding(p.Cells(spec.Size)) {
+					if occupied[cell.Y][cell.X] == Empty {
+						occupied[cell.Y][cell.X] = Miss
+					}
+				}
+			}
+		}
+		if !ok {
+			continue
+		}
+		if _, err := BuildBoardFromPlacements(rules, fleet); err == nil {
+			return fleet, nil
+		}
+	}
+	return nil, ErrUnplaceable
+}
+
+// placeOne tries random positions for one ship, marking it on occupied.
+func placeOne(rng *rand.Rand, occupied Board, spec ShipSpec, avoidEdges bool) (Placement, bool) {
+	margin := 0
+	if avoidEdges {
+		margin = 1
+	}
+	for attempt := 0; attempt < 100; attempt++ {
+		p := Placement{Type: spec.Type, Dir: "H"}
+		if rng.Intn(2) == 0 {
+			p.Dir = "V"
+		}
+		maxX, maxY := occupied.Width()-2*margin, occupied.Height()-2*margin
+		if p.Dir == "H" {
+			maxX -= spec.Size - 1
+		} else {
+			maxY -= spec.Size - 1
+		}
+		if maxX <= 0 || maxY <= 0 {
+			continue
+		}
+		p.X, p.Y = margin+rng.Intn(maxX), margin+rng.Intn(maxY)
+		cells := p.Cells(spec.Size)
+		free := true
+		for _, c := range cells {
+			if occupied[c.Y][c.X] != Empty {
+				free = false
+				break
+			}
+		}
+		if !free {
+			continue
+		}
+		for _, c := range cells {
+			occupied[c.Y][c.X] = Ship
+		}
+		return p, true
+	}
+	return Placement{}, false
+}
@@ -0,0 +1,91 @@
+package game
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestRandomFleet(t *testing.T) {
+	spaced := DefaultRules()
+	spaced.Spacing = SpacingEdges
+	crowded := DefaultRules()
+	crowded.Width, crowded.Height = 7, 7
+	crowded.Fleet = []ShipSpec{{Type: "boat", Size: 2, Count: 5}, {Type: "raft", Size: 1, Count: 3}}
+
+	tests := []struct {
+		name  string
+		rules RuleSet
+		c     FleetConstraints
+		// check is the spacing every fleet must satisfy
+		check Spacing
+	}{
+		{"classic", DefaultRules(), FleetConstraints{}, SpacingAny},
+		{"edges apart", DefaultRules(), FleetConstraints{Spacing: SpacingEdges}, SpacingEdges},
+		{"corners apart", DefaultRules(), FleetConstraints{Spacing: SpacingCorners}, SpacingCorners},
+		{"rules already spaced", spaced, FleetConstraints{}, SpacingEdges},
+		{"constraint tightens the rules", spaced, FleetConstraints{Spacing: SpacingCorners}, SpacingCorners},
+		{"off the edges", DefaultRules(), FleetConstraints{AvoidEdges: true}, SpacingAny},
+		{"small board", crowded, FleetConstraints{Spacing: SpacingEdges}, SpacingEdges},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strict := tt.rules
+			strict.Spacing = tt.check
+			for seed := int64(0); seed < 50; seed++ {
+				fleet, err := RandomFleet(rand.New(rand.NewSource(seed)), tt.rules, tt.c)
+				if err != nil {
+					t.Fatalf("seed %d: %v", seed, err)
+				}
+				if _, err := BuildBoardFromPlacements(strict, fleet); err != nil {
+					t.Fatalf("seed %d: fleet breaks %q spacing: %v", seed, tt.check, err)
+				}
+				if !tt.c.AvoidEdges {
+					continue
+				}
+				for _, p := range fleet {
+					size, _ := tt.rules.ShipSize(p.Type)
+					for _, c := range p.Cells(size) {
+						if c.X == 0 || c.Y == 0 || c.X == tt.rules.Width-1 || c.Y == tt.rules.Height-1 {
+							t.Fatalf("seed %d: %s touches the edge at %v", seed, p.Type, c)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestRandomFleetIsReproducible(t *testing.T) {
+	c := FleetConstraints{Spacing: SpacingCorners}
+	a, _ := RandomFleet(rand.New(rand.NewSource(7)), DefaultRules(), c)
+	b, _ := RandomFleet(rand.New(rand.NewSource(7)), DefaultRules(), c)
+	if !reflect.DeepEqual(a, b) {
+		t.Errorf("same seed gave %v and %v", a, b)
+	}
+}
+
+func TestRandomFleetFailures(t *testing.T) {
+	packed := DefaultRules()
+	packed.Width, packed.Height = 5, 5
+	packed.Fleet = []ShipSpec{{Type: "boat", Size: 3, Count: 5}}
+
+	tests := []struct {
+		name  string
+		rules RuleSet
+		c     FleetConstraints
+		err   string
+	}{
+		{"unknown spacing", DefaultRules(), FleetConstraints{Spacing: "far"}, "unknown_spacing:far"},
+		{"no room", packed, FleetConstraints{Spacing: SpacingCorners}, ErrUnplaceable.Error()},
+		{"no room off the edges", packed, FleetConstraints{AvoidEdges: true}, ErrUnplaceable.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RandomFleet(rand.New(rand.NewSource(1)), tt.rules, tt.c)
+			if got := errString(err); got != tt.err {
+				t.Errorf("RandomFleet error = %q, want %q", got, tt.err)
+			}
+		})
+	}
+}
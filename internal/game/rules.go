@@ -0,0 +1,242 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Mode selects the firing rules of a match.
+type Mode string
+
+const (
+	// ModeClassic fires one shot at a time.
+	ModeClassic Mode = "classic"
+	// ModeSalvo fires a volley per turn and always passes the turn.
+	ModeSalvo Mode = "salvo"
+)
+
+// Spacing says how close ships may be placed to each other.
+type Spacing string
+
+const (
+	// SpacingAny lets ships sit flush against each other.
+	SpacingAny Spacing = ""
+	// SpacingEdges forbids ships sharing an edge.
+	SpacingEdges Spacing = "edges"
+	// SpacingCorners forbids ships sharing an edge or a corner.
+	SpacingCorners Spacing = "corners"
+)
+
+// Limits accepted by RuleSet.Validate. The fleet and volley limits keep a
+// place_ships or salvo_fired frame well inside the protocol's frame size.
+const (
+	MinBoardSize = 5
+	MaxBoardSize = 26
+	// MaxShips caps the ships in a fleet, counting each copy.
+	MaxShips = 20
+	// MaxShipTypeLength caps a ship type name, in bytes.
+	MaxShipTypeLength = 20
+	// MaxSalvoShots caps a fixed volley.
+	MaxSalvoShots = MaxShips
+)
+
+// ShipSpec is one kind of ship in a fleet.
+type ShipSpec struct {
+	Type  string `json:"type"`
+	Size  int    `json:"size"`
+	Count int    `json:"count"`
+}
+
+// DefaultFleet is the classic five-ship fleet.
+var DefaultFleet = []ShipSpec{
+	{Type: "carrier", Size: 5, Count: 1},
+	{Type: "battleship", Size: 4, Count: 1},
+	{Type: "cruiser", Size: 3, Count: 1},
+	{Type: "submarine", Size: 3, Count: 1},
+	{Type: "destroyer", Size: 2, Count: 1},
+}
+
+// RuleSet is everything a match is played under, fixed at creation.
+type RuleSet struct {
+	Width  int        `json:"width"`
+	Height int        `json:"height"`
+	Fleet  []ShipSpec `json:"fleet"`
+	// ExtraTurnOnHit lets a classic-mode shooter fire again after a hit.
+	ExtraTurnOnHit bool `json:"extra_turn_on_hit"`
+	// AnnounceSunk tells the shooter which ship type they sank.
+	AnnounceSunk bool `json:"announce_sunk"`
+	Mode         Mode `json:"mode"`
+	// SalvoShots fixes the volley size in salvo mode; 0 means one shot per
+	// ship still afloat.
+	SalvoShots int `json:"salvo_shots,omitempty"`
+	// Spacing keeps ships apart; when set, the water around a sunk ship
+	// is marked as missed.
+	Spacing Spacing `json:"spacing,omitempty"`
+}
+
+// DefaultRules returns the classic game: 10x10, five ships, a hit shoots
+// again and sinkings are announced.
+func DefaultRules() RuleSet {
+	return RuleSet{
+		Width:          10,
+		Height:         10,
+		Fleet:          append([]ShipSpec(nil), DefaultFleet...),
+		ExtraTurnOnHit: true,
+		AnnounceSunk:   true,
+		Mode:           ModeClassic,
+	}
+}
+
+// UnmarshalJSON fills fields missing from the document with the defaults,
+// so clients only send what they want to change.
+func (r *RuleSet) UnmarshalJSON(b []byte) error {
+	type plain RuleSet
+	p := plain(DefaultRules())
+	p.Fleet = nil
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+	if p.Fleet == nil {
+		p.Fleet = append([]ShipSpec(nil), DefaultFleet...)
+	}
+	*r = RuleSet(p)
+	return nil
+}
+
+// Validate checks that r describes a playable game.
+func (r RuleSet) Validate() error {
+	if r.Width < MinBoardSize || r.Width > MaxBoardSize || r.Height < MinBoardSize || r.Height > MaxBoardSize {
+		return fmt.Errorf("bad_board_size:%dx%d", r.Width, r.Height)
+	}
+	if len(r.Fleet) == 0 {
+		return errors.New("empty_fleet")
+	}
+	seen := map[string]bool{}
+	cells, ships := 0, 0
+	for _, s := range r.Fleet {
+		if s.Type == "" || len(s.Type) > MaxShipTypeLength || seen[s.Type] {
+			return errors.New("bad_ship_type:" + s.Type)
+		}
+		seen[s.Type] = true
+		if s.Size < 1 || (s.Size > r.Width && s.Size > r.Height) {
+			return errors.New("bad_ship_size:" + s.Type)
+		}
+		if s.Count < 1 || s.Count > MaxShips {
+			return errors.New("bad_ship_count:" + s.Type)
+		}
+		cells += s.Size * s.Count
+		ships += s.Count
+	}
+	if ships > MaxShips {
+		return errors.New("too_many_ships")
+	}
+	// keep at least half the board as water so fleets can always be placed
+	if cells*2 > r.Width*r.Height {
+		return errors.New("fleet_too_large")
+	}
+	switch r.Spacing {
+	case SpacingAny:
+	case SpacingEdges, SpacingCorners:
+		// a spaced ship of size n claims a (n+1)x2 block of the board
+		// grown by one row and column; keep those blocks to half of it
+		claimed := 0
+		for _, s := range r.Fleet {
+			claimed += (s.Size + 1) * 2 * s.Count
+		}
+		if claimed*2 > (r.Width+1)*(r.Height+1) {
+			return errors.New("fleet_too_large")
+		}
+	default:
+		return errors.New("unknown_spacing:" + string(r.Spacing))
+	}
+	// the water marked around a sunk ship outlines it, giving away what
+	// unannounced sinkings hide
+	if r.Spacing != SpacingAny && !r.AnnounceSunk {
+		return errors.New("spacing_needs_announce_sunk")
+	}
+	switch r.Mode {
+	case ModeClassic:
+		if r.SalvoShots != 0 {
+			return errors.New("salvo_shots_without_salvo")
+		}
+	case ModeSalvo:
+		if r.SalvoShots < 0 || r.SalvoShots > MaxSalvoShots {
+			return errors.New("bad_salvo_shots")
+		}
+	default:
+		return errors.New("unknown_mode:" + string(r.Mode))
+	}
+	return nil
+}
+
+// ShipSize returns the size of ship type typ.
+func (r RuleSet) ShipSize(typ string) (int, bool) {
+	for _, s := range r.Fleet {
+		if s.Type == typ {
+			return s.Size, true
+		}
+	}
+	return 0, false
+}
+
+// Sizes lists the size of every ship in the fleet, one entry per ship.
+func (r RuleSet) Sizes() []int {
+	var out []int
+	for _, s := range r.Fleet {
+		for i := 0; i < s.Count; i++ {
+			out = append(out, s.Size)
+		}
+	}
+	return out
+}
+
+// NewBoard returns an empty board of the rule set's size.
+func (r RuleSet) NewBoard() Board {
+	return NewBoard(r.Width, r.Height)
+}
+
+// Surrounding returns the in-bounds cells next to cells that the spacing
+// rule keeps clear of other ships: edge neighbours, plus diagonal ones
+// under SpacingCorners. It is empty under SpacingAny.
+func (r RuleSet) Surrounding(cells []Coord) []Coord {
+	if r.Spacing == SpacingAny {
+		return nil
+	}
+	dirs := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	if r.Spacing == SpacingCorners {
+		dirs = append(dirs, [2]int{1, 1}, [2]int{1, -1}, [2]int{-1, 1}, [2]int{-1, -1})
+	}
+	own := make(map[Coord]bool, len(cells))
+	for _, c := range cells {
+		own[c] = true
+	}
+	seen := map[Coord]bool{}
+	var out []Coord
+	for _, c := range cells {
+		for _, d := range dirs {
+			n := Coord{X: c.X + d[0], Y: c.Y + d[1]}
+			if n.X < 0 || n.Y < 0 || n.X >= r.Width || n.Y >= r.Height || own[n] || seen[n] {
+				continue
+			}
+			seen[n] = true
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// Equal reports whether r and o describe the same game.
+func (r RuleSet) Equal(o RuleSet) bool {
+	if r.Width != o.Width || r.Height != o.Height || r.ExtraTurnOnHit != o.ExtraTurnOnHit ||
+		r.AnnounceSunk != o.AnnounceSunk || r.Mode != o.Mode || r.SalvoShots != o.SalvoShots ||
+		r.Spacing != o.Spacing || len(r.Fleet) != len(o.Fleet) {
+		return false
+	}
+	for i := range r.Fleet {
+		if r.Fleet[i] != o.Fleet[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package game
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRuleSetValidate(t *testing.T) {
+	with := func(f func(r *RuleSet)) RuleSet {
+		r := DefaultRules()
+		f(&r)
+		return r
+	}
+	tests := []struct {
+		name  string
+		rules RuleSet
+		err   string
+	}{
+		{"default", DefaultRules(), ""},
+		{"smallest board", with(func(r *RuleSet) {
+			r.Width, r.Height = MinBoardSize, MinBoardSize
+			r.Fleet = []ShipSpec{{Type: "boat", Size: 2, Count: 2}}
+		}), ""},
+		{"too small", with(func(r *RuleSet) { r.Width = MinBoardSize - 1 }), "bad_board_size:4x10"},
+		{"too tall", with(func(r *RuleSet) { r.Height = MaxBoardSize + 1 }), "bad_board_size:10x27"},
+		{"no ships", with(func(r *RuleSet) { r.Fleet = nil }), "empty_fleet"},
+		{"nameless ship", with(func(r *RuleSet) { r.Fleet[0].Type = "" }), "bad_ship_type:"},
+		{"long ship name", with(func(r *RuleSet) { r.Fleet[0].Type = strings.Repeat("x", MaxShipTypeLength+1) }), "bad_ship_type:" + strings.Repeat("x", MaxShipTypeLength+1)},
+		{"repeated type", with(func(r *RuleSet) { r.Fleet[1].Type = "carrier" }), "bad_ship_type:carrier"},
+		{"ship longer than the board", with(func(r *RuleSet) { r.Fleet[0].Size = 11 }), "bad_ship_size:carrier"},
+		{"zero size", with(func(r *RuleSet) { r.Fleet[0].Size = 0 }), "bad_ship_size:carrier"},
+		{"zero count", with(func(r *RuleSet) { r.Fleet[0].Count = 0 }), "bad_ship_count:carrier"},
+		{"too many of a type", with(func(r *RuleSet) {
+			r.Width, r.Height = MaxBoardSize, MaxBoardSize
+			r.Fleet = []ShipSpec{{Type: "boat", Size: 1, Count: MaxShips + 1}}
+		}), "bad_ship_count:boat"},
+		{"too many ships", with(func(r *RuleSet) {
+			r.Width, r.Height = MaxBoardSize, MaxBoardSize
+			r.Fleet = []ShipSpec{{Type: "boat", Size: 1, Count: MaxShips}, {Type: "raft", Size: 1, Count: 1}}
+		}), "too_many_ships"},
+		{"fleet fills the board", with(func(r *RuleSet) { r.Fleet[0].Count = 12 }), "fleet_too_large"},
+		{"spaced fleet too large", with(func(r *RuleSet) {
+			r.Width, r.Height = 7, 7
+			r.Spacing = SpacingEdges
+		}), "fleet_too_large"},
+		{"unknown spacing", with(func(r *RuleSet) { r.Spacing = "far" }), "unknown_spacing:far"},
+		{"spacing hides nothing", with(func(r *RuleSet) {
+			r.Spacing = SpacingCorners
+			r.AnnounceSunk = false
+		}), "spacing_needs_announce_sunk"},
+		{"classic volley", with(func(r *RuleSet) { r.SalvoShots = 3 }), "salvo_shots_without_salvo"},
+		{"salvo", with(func(r *RuleSet) { r.Mode, r.SalvoShots = ModeSalvo, 3 }), ""},
+		{"huge volley", with(func(r *RuleSet) { r.Mode, r.SalvoShots = ModeSalvo, MaxSalvoShots+1 }), "bad_salvo_shots"},
+		{"negative volley", with(func(r *RuleSet) { r.Mode, r.SalvoShots = ModeSalvo, -1 }), "bad_salvo_shots"},
+		{"unknown mode", with(func(r *RuleSet) { r.Mode = "blitz" }), "unknown_mode:blitz"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errString(tt.rules.Validate()); got != tt.err {
+				t.Errorf("Validate() = %q, want %q", got, tt.err)
+			}
+		})
+	}
+}
+
+func TestRuleSetDefaults(t *testing.T) {
+	salvo := DefaultRules()
+	salvo.Mode = ModeSalvo
+	small := DefaultRules()
+	small.Width, small.Height = 8, 8
+	small.Fleet = []ShipSpec{{Type: "boat", Size: 2, Count: 3}}
+	quiet := DefaultRules()
+	quiet.ExtraTurnOnHit, quiet.AnnounceSunk = false, false
+
+	tests := []struct {
+		name string
+		doc  string
+		want RuleSet
+	}{
+		{"empty", `{}`, DefaultRules()},
+		{"mode only", `{"mode":"salvo"}`, salvo},
+		{"board and fleet", `{"width":8,"height":8,"fleet":[{"type":"boat","size":2,"count":3}]}`, small},
+		{"switched off", `{"extra_turn_on_hit":false,"announce_sunk":false}`, quiet},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got RuleSet
+			if err := json.Unmarshal([]byte(tt.doc), &got); err != nil {
+				t.Fatal(err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	// defaults must not share the package's fleet
+	var r RuleSet
+	json.Unmarshal([]byte(`{}`), &r)
+	r.Fleet[0].Size = 1
+	if DefaultFleet[0].Size == 1 {
+		t.Error("decoding aliased DefaultFleet")
+	}
+}
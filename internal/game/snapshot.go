@@ -0,0 +1,53 @@
+package game
+
+// Snapshot is a serialisable copy of a Game's full state.
+type Snapshot struct {
+	Boards [2]Board        `json:"boards"`
+	Ships  [2][]PlacedShip `json:"ships"`
+	Placed [2]bool         `json:"placed"`
+	Turn   PlayerSide      `json:"turn"`
+	State  MatchState      `json:"state"`
+	Winner PlayerSide      `json:"winner"`
+	Stats  [2]Stats        `json:"stats"`
+	Rules  RuleSet         `json:"rules"`
+}
+
+// Snapshot captures the game so it can be persisted and later restored.
+// It shares nothing with g, so it stays fixed as the game goes on.
+func (g *Game) Snapshot() Snapshot {
+	return Snapshot{
+		Boards: [2]Board{g.boards[SideA].Clone(), g.boards[SideB].Clone()},
+		Ships:  [2][]PlacedShip{g.Ships(SideA), g.Ships(SideB)},
+		Placed: g.placed,
+		Turn:   g.turn,
+		State:  g.state,
+		Winner: g.winner,
+		Stats:  g.stats,
+		Rules:  g.rules,
+	}
+}
+
+// Restore rebuilds a Game from a snapshot.
+func Restore(s Snapshot) *Game {
+	g := &Game{
+		boards: [2]Board{s.Boards[SideA].Clone(), s.Boards[SideB].Clone()},
+		placed: s.Placed,
+		turn:   s.Turn,
+		state:  s.State,
+		winner: s.Winner,
+		stats:  s.Stats,
+		rules:  s.Rules,
+	}
+	// snapshots from before rule sets were classic games
+	if g.rules.Width == 0 {
+		g.rules = DefaultRules()
+	}
+	for side := range s.Ships {
+		for _, ship := range s.Ships[side] {
+			cp := ship
+			cp.Cells = append([]Coord(nil), ship.Cells...)
+			g.ships[side] = append(g.ships[side], &cp)
+		}
+	}
+	return g
+}
@@ -0,0 +1,52 @@
+package game
+
+import "testing"
+
+// classicFleet lays the default fleet out on rows 0, 2, 4, 6 and 8.
+func classicFleet() []Placement {
+	var out []Placement
+	for i, spec := range DefaultFleet {
+		out = append(out, Placement{Type: spec.Type, X: 0, Y: 2 * i, Dir: "H"})
+	}
+	return out
+}
+
+// battle returns a default game with both fleets placed and A to shoot.
+func battle(t *testing.T, rules RuleSet) *Game {
+	t.Helper()
+	g := New(SideA, rules)
+	if err := g.OpenPlacement(); err != nil {
+		t.Fatal(err)
+	}
+	for _, side := range []PlayerSide{SideA, SideB} {
+		if _, err := g.PlaceFleet(side, classicFleet()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return g
+}
+
+func TestSnapshotIsDetached(t *testing.T) {
+	g := battle(t, DefaultRules())
+	snap := g.Snapshot()
+	if _, err := g.Fire(SideA, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := g.Fire(SideA, 9, 9); err != nil {
+		t.Fatal(err)
+	}
+	if c := snap.Boards[SideB][0][0]; c != Ship {
+		t.Fatalf("snapshot hit cell = %v, want Ship", c)
+	}
+	if c := snap.Boards[SideB][9][9]; c != Empty {
+		t.Fatalf("snapshot missed cell = %v, want Empty", c)
+	}
+
+	restored := Restore(snap)
+	if _, err := restored.Fire(SideA, 0, 0); err != nil {
+		t.Fatalf("restored game refuses a shot the snapshot never saw: %v", err)
+	}
+	if c := g.Board(SideB)[0][0]; c != Hit {
+		t.Fatalf("live board cell = %v, want Hit", c)
+	}
+}
@@ -1,45 +1,109 @@
-package game
-
-import "time"
-
-type Cell int
-
-const (
-	Empty Cell = iota
-	Ship
-	Hit
-	Miss
-)
-
-type Board [10][10]Cell
-
-type PlayerSide int
-
-const (
-	SideA PlayerSide = iota
-	SideB
-)
-
-type MatchState int
-
-const (
-	WaitingForPlayers MatchState = iota
-	InProgress
-	Finished
-)
-
-type Player struct {
-	ID   string
-	Name string
-}
-
-type Match struct {
-	ID      string
-	PlayerA *Player
-	PlayerB *Player
-	BoardA  Board
-	BoardB  Board
-	Turn    PlayerSide
-	State   MatchState
-	Created time.Time
-}
+package game
+
+type Cell int
+
+const (
+	Empty Cell = iota
+	Ship
+	Hit
+	Miss
+)
+
+// Board is a grid of cells indexed [y][x]. Its size comes from the match's
+// RuleSet.
+type Board [][]Cell
+
+// NewBoard returns an empty width x height board.
+func NewBoard(width, height int) Board {
+	b := make(Board, height)
+	for y := range b {
+		b[y] = make([]Cell, width)
+	}
+	return b
+}
+
+func (b Board) Width() int {
+	if len(b) == 0 {
+		return 0
+	}
+	return len(b[0])
+}
+
+func (b Board) Height() int {
+	return len(b)
+}
+
+// InBounds reports whether (x, y) lies on the board.
+func (b Board) InBounds(x, y int) bool {
+	return x >= 0 && y >= 0 && y < len(b) && x < len(b[y])
+}
+
+// Clone returns a deep copy of b.
+func (b Board) Clone() Board {
+	out := make(Board, len(b))
+	for y := range b {
+		out[y] = append([]Cell(nil), b[y]...)
+	}
+	return out
+}
+
+type PlayerSide int
+
+const (
+	SideA PlayerSide = iota
+	SideB
+)
+
+// Opponent returns the other side.
+func (s PlayerSide) Opponent() PlayerSide {
+	if s == SideA {
+		return SideB
+	}
+	return SideA
+}
+
+func (s PlayerSide) String() string {
+	if s == SideA {
+		return "A"
+	}
+	return "B"
+}
+
+// MatchState is the lifecycle phase of a match:
+// Created -> Placing -> Battle -> Finished, or Abandoned from any live phase.
+type MatchState int
+
+const (
+	Created MatchState = iota
+	Placing
+	Battle
+	Finished
+	Abandoned
+)
+
+func (s MatchState) String() string {
+	switch s {
+	case Created:
+		return "created"
+	case Placing:
+		return "placing"
+	case Battle:
+		return "battle"
+	case Finished:
+		return "finished"
+	case Abandoned:
+		return "abandoned"
+	}
+	return "unknown"
+}
+
+// Over reports whether the match has reached a terminal phase.
+func (s MatchState) Over() bool {
+	return s == Finished || s == Abandoned
+}
+
+// Coord is a single board cell.
+type Coord struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
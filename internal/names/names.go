@@ -0,0 +1,87 @@
+// Package names holds the display name policy: Unicode normalization,
+// allowed characters, length limits and reserved words.
+package names
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	MinLength = 2
+	MaxLength = 20
+)
+
+var (
+	ErrTooShort     = errors.New("name_too_short")
+	ErrTooLong      = errors.New("name_too_long")
+	ErrInvalidChars = errors.New("name_invalid_chars")
+	ErrReserved     = errors.New("name_reserved")
+)
+
+// reserved names cannot be taken by players, compared by Key with
+// separators removed so "Ad_min" is caught too.
+var reserved = map[string]bool{
+	"admin": true, "administrator": true, "moderator": true, "mod": true,
+	"system": true, "server": true, "battleship": true, "bot": true,
+	"guest": true, "anonymous": true, "null": true, "undefined": true,
+}
+
+// Normalize returns the canonical form of name: NFKC normalized, trimmed,
+// with runs of whitespace collapsed to one space. It rejects names outside
+// the length limits (in runes), characters other than letters, digits,
+// combining marks, spaces, '_', '-' and '.', and reserved words.
+func Normalize(name string) (string, error) {
+	name = strings.Join(strings.Fields(norm.NFKC.String(name)), " ")
+	n := utf8.RuneCountInString(name)
+	if n < MinLength {
+		return "", ErrTooShort
+	}
+	if n > MaxLength {
+		return "", ErrTooLong
+	}
+	for i, r := range name {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+		case unicode.IsMark(r) && i > 0:
+		case r == ' ', r == '_', r == '-', r == '.':
+		default:
+			return "", ErrInvalidChars
+		}
+	}
+	bare := strings.NewReplacer(" ", "", "_", "", "-", "", ".", "").Replace(Key(name))
+	if reserved[bare] {
+		return "", ErrReserved
+	}
+	return name, nil
+}
+
+// Key is the form names are compared in: two names with the same key count
+// as the same name.
+func Key(name string) string {
+	return strings.ToLower(name)
+}
+
+// Suggest returns the first of name2, name3, ... for which taken is false,
+// shortening name to keep within MaxLength. It gives up with "" after a
+// thousand tries.
+func Suggest(name string, taken func(string) bool) string {
+	runes := []rune(name)
+	for i := 2; i < 1000; i++ {
+		suffix := strconv.Itoa(i)
+		base := runes
+		if len(base)+len(suffix) > MaxLength {
+			base = base[:MaxLength-len(suffix)]
+		}
+		candidate := strings.TrimRight(string(base), " ") + suffix
+		if !taken(candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
@@ -0,0 +1,106 @@
+package names
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		err  error
+	}{
+		{"Ann", "Ann", nil},
+		{"  Ann   Lee\t", "Ann Lee", nil},
+		{"ｆｕｌｌ　ｗｉｄｔｈ", "full width", nil},
+		{"Zoë", "Zoë", nil},
+		// a decomposed e with diaeresis composes under NFKC
+		{"Zoe\u0308", "Zo\u00eb", nil},
+		{"j.r_r-t", "j.r_r-t", nil},
+		{"田中", "田中", nil},
+		{strings.Repeat("a", MaxLength), strings.Repeat("a", MaxLength), nil},
+		{strings.Repeat("é", MaxLength), strings.Repeat("é", MaxLength), nil},
+		{"A", "", ErrTooShort},
+		{"   ", "", ErrTooShort},
+		{strings.Repeat("a", MaxLength+1), "", ErrTooLong},
+		{"Ann!", "", ErrInvalidChars},
+		{"<script>", "", ErrInvalidChars},
+		{"Ann\u200bLee", "", ErrInvalidChars},
+		{"\u0301Ann", "", ErrInvalidChars},
+		{"admin", "", ErrReserved},
+		{"Ad_Min", "", ErrReserved},
+		{"S.Y.S.T.E.M", "", ErrReserved},
+		{"admin2", "admin2", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := Normalize(tt.in)
+			if got != tt.want || err != tt.err {
+				t.Errorf("Normalize(%q) = %q, %v; want %q, %v", tt.in, got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"Ann", "ann", true},
+		{"ANN LEE", "ann lee", true},
+		{"Zoë", "ZOË", true},
+		{"Ann", "Ann2", false},
+		{"Ann Lee", "AnnLee", false},
+	}
+	for _, tt := range tests {
+		if same := Key(tt.a) == Key(tt.b); same != tt.same {
+			t.Errorf("Key(%q) == Key(%q) is %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
+
+func TestSuggest(t *testing.T) {
+	long := strings.Repeat("x", MaxLength)
+	tests := []struct {
+		name  string
+		taken []string
+		want  string
+	}{
+		{"Ann", nil, "Ann2"},
+		{"Ann", []string{"ann2", "ANN3"}, "Ann4"},
+		{long, nil, long[:MaxLength-1] + "2"},
+		{long, upTo(long[:MaxLength-1], 9), long[:MaxLength-2] + "10"},
+		// shortening must not leave a trailing space before the number
+		{strings.Repeat("x", MaxLength-2) + " y", nil, strings.Repeat("x", MaxLength-2) + "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taken := func(n string) bool {
+				for _, tk := range tt.taken {
+					if Key(n) == Key(tk) {
+						return true
+					}
+				}
+				return false
+			}
+			if got := Suggest(tt.name, taken); got != tt.want {
+				t.Errorf("Suggest(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+	if got := Suggest("Ann", func(string) bool { return true }); got != "" {
+		t.Errorf("Suggest with every name taken = %q", got)
+	}
+}
+
+// upTo lists base2 through baseN.
+func upTo(base string, n int) []string {
+	var out []string
+	for i := 2; i <= n; i++ {
+		out = append(out, base+strconv.Itoa(i))
+	}
+	return out
+}
@@ -0,0 +1,667 @@
+package protocol
+
+import (
+	"battleship-go/internal/game"
+	"battleship-go/internal/tournament"
+)
+
+// Client -> server messages.
+
+type Join struct {
+	Name            string `json:"name,omitempty"`
+	ProtocolVersion int    `json:"protocol_version,omitempty"`
+}
+
+func (Join) MessageType() string { return "join" }
+
+// Rename changes the display name of a player who has already joined.
+type Rename struct {
+	Name string `json:"name"`
+}
+
+func (Rename) MessageType() string { return "rename" }
+
+// Challenge invites TargetID to a match. Rules default to the classic game;
+// fields left out of Rules keep their defaults. Mode and SalvoShots are an
+// older shorthand for the same fields of Rules and may not be combined
+// with it.
+type Challenge struct {
+	TargetID   string        `json:"target_id"`
+	Rules      *game.RuleSet `json:"rules,omitempty"`
+	Mode       game.Mode     `json:"mode,omitempty"`
+	SalvoShots int           `json:"salvo_shots,omitempty"`
+	// BestOf asks for a best-of-3, 5 or 7 series rather than one game.
+	BestOf int `json:"best_of,omitempty"`
+}
+
+func (Challenge) MessageType() string { return "challenge" }
+
+// ChallengeResponse answers a challenge_request. ChallengeID names the
+// invite; older clients may send only TargetID, the challenger.
+type ChallengeResponse struct {
+	ChallengeID string `json:"challenge_id,omitempty"`
+	TargetID    string `json:"target_id,omitempty"`
+	Accept      bool   `json:"accept"`
+}
+
+func (ChallengeResponse) MessageType() string { return "challenge_response" }
+
+// ChallengeCancel withdraws a challenge the sender made.
+type ChallengeCancel struct {
+	ChallengeID string `json:"challenge_id"`
+}
+
+func (ChallengeCancel) MessageType() string { return "challenge_cancel" }
+
+type PlaceShips struct {
+	MatchID string           `json:"match_id"`
+	Ships   []game.Placement `json:"ships"`
+}
+
+func (PlaceShips) MessageType() string { return "place_ships" }
+
+type ShotFired struct {
+	MatchID string `json:"match_id"`
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+}
+
+func (ShotFired) MessageType() string { return "shot_fired" }
+
+// SalvoFired fires a whole volley in a salvo-mode match.
+type SalvoFired struct {
+	MatchID string       `json:"match_id"`
+	Shots   []game.Coord `json:"shots"`
+}
+
+func (SalvoFired) MessageType() string { return "salvo_fired" }
+
+// AutoPlace asks for a random fleet under the match's rules. Seed makes
+// the fleet reproducible; Place submits it as place_ships would.
+type AutoPlace struct {
+	MatchID string `json:"match_id"`
+	Seed    *int64 `json:"seed,omitempty"`
+	game.FleetConstraints
+	Place bool `json:"place,omitempty"`
+}
+
+func (AutoPlace) MessageType() string { return "auto_place" }
+
+// QueueJoin enters the matchmaking queue for a game under Rules, or the
+// classic rules when Rules is left out.
+type QueueJoin struct {
+	Rules *game.RuleSet `json:"rules,omitempty"`
+}
+
+func (QueueJoin) MessageType() string { return "queue_join" }
+
+type QueueLeave struct{}
+
+func (QueueLeave) MessageType() string { return "queue_leave" }
+
+// RoomCreate opens a private room playing Rules, or the classic rules.
+// A non-empty Password is required from whoever joins.
+type RoomCreate struct {
+	Rules    *game.RuleSet `json:"rules,omitempty"`
+	Password string        `json:"password,omitempty"`
+}
+
+func (RoomCreate) MessageType() string { return "room_create" }
+
+// RoomJoin enters a private room by its code, starting the match.
+type RoomJoin struct {
+	Code     string `json:"code"`
+	Password string `json:"password,omitempty"`
+}
+
+func (RoomJoin) MessageType() string { return "room_join" }
+
+type RoomClose struct {
+	Code string `json:"code"`
+}
+
+func (RoomClose) MessageType() string { return "room_close" }
+
+// TournamentCreate invites Players to a tournament, seeded in the order
+// given. Rounds sets the number of Swiss rounds (0 for the default); Rules
+// default to the classic game. The organizer need not play.
+type TournamentCreate struct {
+	Name    string            `json:"name,omitempty"`
+	Format  tournament.Format `json:"format"`
+	Players []string          `json:"players"`
+	Rounds  int               `json:"rounds,omitempty"`
+	Rules   *game.RuleSet     `json:"rules,omitempty"`
+	// BestOf plays every pairing as a best-of-3, 5 or 7 series.
+	BestOf int `json:"best_of,omitempty"`
+}
+
+func (TournamentCreate) MessageType() string { return "tournament_create" }
+
+// TournamentCancel stops a tournament; only its organizer may.
+type TournamentCancel struct {
+	TournamentID string `json:"tournament_id"`
+}
+
+func (TournamentCancel) MessageType() string { return "tournament_cancel" }
+
+// TournamentResponse answers the invitation to a tournament. Play starts
+// once every player has accepted; a decline cancels the tournament.
+type TournamentResponse struct {
+	TournamentID string `json:"tournament_id"`
+	Accept       bool   `json:"accept"`
+}
+
+func (TournamentResponse) MessageType() string { return "tournament_response" }
+
+type RematchRequest struct {
+	MatchID   string `json:"match_id"`
+	SwapFirst bool   `json:"swap_first,omitempty"`
+}
+
+func (RematchRequest) MessageType() string { return "rematch_request" }
+
+type RematchResponse struct {
+	MatchID string `json:"match_id"`
+	Accept  bool   `json:"accept"`
+}
+
+func (RematchResponse) MessageType() string { return "rematch_response" }
+
+// Spectate subscribes to a live match. Reveal asks for the delayed
+// full-board feed instead of the fogged live one.
+type Spectate struct {
+	MatchID string `json:"match_id"`
+	Reveal  bool   `json:"reveal,omitempty"`
+}
+
+func (Spectate) MessageType() string { return "spectate" }
+
+type StopSpectating struct {
+	MatchID string `json:"match_id"`
+}
+
+func (StopSpectating) MessageType() string { return "stop_spectating" }
+
+// Chat posts to the lobby, or to a match when MatchID is set.
+type Chat struct {
+	MatchID string `json:"match_id,omitempty"`
+	Text    string `json:"text"`
+}
+
+func (Chat) MessageType() string { return "chat" }
+
+// ChatMute hides (or with Muted false, shows again) another player's chat.
+type ChatMute struct {
+	PlayerID string `json:"player_id"`
+	Muted    bool   `json:"muted"`
+}
+
+func (ChatMute) MessageType() string { return "chat_mute" }
+
+// Server -> client messages.
+
+type Welcome struct {
+	ID                 string `json:"id"`
+	Name               string `json:"name"`
+	Account            string `json:"account,omitempty"`
+	Resumed            bool   `json:"resumed"`
+	ResumeToken        string `json:"resume_token"`
+	ProtocolVersion    int    `json:"protocol_version"`
+	MinProtocolVersion int    `json:"min_protocol_version"`
+}
+
+func (Welcome) MessageType() string { return "welcome" }
+
+// LobbyPlayer is one entry of the lobby list. Rating is left out for
+// bots, which carry their difficulty in Bot instead, and for guests.
+type LobbyPlayer struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Rating int    `json:"rating,omitempty"`
+	Bot    string `json:"bot,omitempty"`
+	Guest  bool   `json:"guest,omitempty"`
+}
+
+// LobbyUpdate keeps the lobby list current. Event is "snapshot" (Players
+// holds the whole list), "joined", "left", "renamed", "status" (Players
+// holds the changed entries) or "match_started" / "match_ended" (MatchID
+// is set and Players holds both players' new entries).
+type LobbyUpdate struct {
+	Event   string        `json:"event"`
+	Players []LobbyPlayer `json:"players"`
+	MatchID string        `json:"match_id,omitempty"`
+}
+
+func (LobbyUpdate) MessageType() string { return "lobby_update" }
+
+type JoinAck struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	ResumeToken     string `json:"resume_token"`
+	ProtocolVersion int    `json:"protocol_version"`
+	Rating          int    `json:"rating,omitempty"`
+}
+
+func (JoinAck) MessageType() string { return "join_ack" }
+
+// JoinError rejects the name given in a join or rename (For). Name is the
+// requested name after normalization when it got that far, and Suggestion
+// a free alternative when the name was taken.
+type JoinError struct {
+	Error      string `json:"error"`
+	For        string `json:"for"`
+	Name       string `json:"name,omitempty"`
+	Suggestion string `json:"suggestion,omitempty"`
+	MinLength  int    `json:"min_length"`
+	MaxLength  int    `json:"max_length"`
+}
+
+func (JoinError) MessageType() string { return "join_error" }
+
+// Renamed confirms a rename.
+type Renamed struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func (Renamed) MessageType() string { return "renamed" }
+
+type Error struct {
+	Error string `json:"error"`
+	For   string `json:"for,omitempty"`
+}
+
+func (Error) MessageType() string { return "error" }
+
+type ChallengeRequest struct {
+	ChallengeID string       `json:"challenge_id"`
+	FromID      string       `json:"from_id"`
+	FromName    string       `json:"from_name"`
+	FromRating  int          `json:"from_rating,omitempty"`
+	Rules       game.RuleSet `json:"rules"`
+	BestOf      int          `json:"best_of,omitempty"`
+	ExpiresInMs int64        `json:"expires_in_ms"`
+}
+
+func (ChallengeRequest) MessageType() string { return "challenge_request" }
+
+// QueueStatus confirms joining or leaving the matchmaking queue. Waiting
+// counts the players queued, including the recipient. A match found
+// through the queue is announced with match_start instead.
+type QueueStatus struct {
+	Queued  bool          `json:"queued"`
+	Waiting int           `json:"waiting,omitempty"`
+	Rules   *game.RuleSet `json:"rules,omitempty"`
+}
+
+func (QueueStatus) MessageType() string { return "queue_status" }
+
+// ChallengeSent confirms a challenge to its sender with the ID needed to
+// cancel it.
+type ChallengeSent struct {
+	ChallengeID string `json:"challenge_id"`
+	TargetID    string `json:"target_id"`
+	ExpiresInMs int64  `json:"expires_in_ms"`
+}
+
+func (ChallengeSent) MessageType() string { return "challenge_sent" }
+
+// ChallengeCancelled tells both sides a pending challenge is gone: the
+// challenger cancelled it, it expired, either player became busy or the
+// challenger disconnected.
+type ChallengeCancelled struct {
+	ChallengeID string `json:"challenge_id"`
+	FromID      string `json:"from_id"`
+	TargetID    string `json:"target_id"`
+	Reason      string `json:"reason"`
+}
+
+func (ChallengeCancelled) MessageType() string { return "challenge_cancelled" }
+
+// TournamentPlayer is a roster entry.
+type TournamentPlayer struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Seed int    `json:"seed"`
+	// Accepted is set once the player agreed to play.
+	Accepted bool `json:"accepted"`
+}
+
+// TournamentPairing is a bracket or round entry. SeriesID and Score follow
+// the series that plays it; MatchID is its latest match.
+type TournamentPairing struct {
+	tournament.Pairing
+	SeriesID string         `json:"series_id,omitempty"`
+	MatchID  string         `json:"match_id,omitempty"`
+	Score    map[string]int `json:"score,omitempty"`
+}
+
+// Tournament is the full state of a tournament. State is "inviting" until
+// every player has accepted, then "running", "finished" or "cancelled".
+// Round and Rounds are only set for round robin and Swiss.
+type Tournament struct {
+	ID          string                `json:"id"`
+	Name        string                `json:"name"`
+	Format      tournament.Format     `json:"format"`
+	State       string                `json:"state"`
+	OrganizerID string                `json:"organizer_id"`
+	Rules       game.RuleSet          `json:"rules"`
+	BestOf      int                   `json:"best_of"`
+	Round       int                   `json:"round,omitempty"`
+	Rounds      int                   `json:"rounds,omitempty"`
+	WinnerID    string                `json:"winner_id,omitempty"`
+	Players     []TournamentPlayer    `json:"players"`
+	Pairings    []TournamentPairing   `json:"pairings"`
+	Standings   []tournament.Standing `json:"standings"`
+}
+
+// TournamentUpdate goes to the organizer and every player whenever a
+// tournament changes. Event is "created", "accepted", "declined",
+// "started", "match_started", "result", "round_started", "finished" or
+// "cancelled"; PairingID names the pairing a match event is about.
+type TournamentUpdate struct {
+	Event      string     `json:"event"`
+	PairingID  string     `json:"pairing_id,omitempty"`
+	Tournament Tournament `json:"tournament"`
+}
+
+func (TournamentUpdate) MessageType() string { return "tournament_update" }
+
+// RoomCreated gives the owner the code and link to share.
+type RoomCreated struct {
+	Code        string       `json:"code"`
+	URL         string       `json:"url"`
+	Rules       game.RuleSet `json:"rules"`
+	HasPassword bool         `json:"has_password"`
+	ExpiresInMs int64        `json:"expires_in_ms"`
+}
+
+func (RoomCreated) MessageType() string { return "room_created" }
+
+// RoomClosed tells the owner a room closed: someone joined it, they closed
+// it, it expired, they opened another, started another match or left.
+type RoomClosed struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+func (RoomClosed) MessageType() string { return "room_closed" }
+
+type ChallengeResponseForward struct {
+	ChallengeID string `json:"challenge_id"`
+	FromID      string `json:"from_id"`
+	FromName    string `json:"from_name"`
+	Accept      bool   `json:"accept"`
+	TargetID    string `json:"target_id"`
+}
+
+func (ChallengeResponseForward) MessageType() string { return "challenge_response_forward" }
+
+type MatchStart struct {
+	MatchID         string         `json:"match_id"`
+	YourSide        string         `json:"your_side"`
+	OpponentID      string         `json:"opponent_id"`
+	OpponentName    string         `json:"opponent_name"`
+	SeriesScore     map[string]int `json:"series_score,omitempty"`
+	PreviousMatchID string         `json:"previous_match_id,omitempty"`
+	Rules           game.RuleSet   `json:"rules"`
+	// SeriesID is set when the match is part of a series; Game counts its
+	// games from 1 and BestOf is 0 for an open-ended run of rematches.
+	SeriesID string `json:"series_id,omitempty"`
+	BestOf   int    `json:"best_of,omitempty"`
+	Game     int    `json:"game,omitempty"`
+}
+
+func (MatchStart) MessageType() string { return "match_start" }
+
+type ShipsOK struct {
+	MatchID string `json:"match_id"`
+}
+
+func (ShipsOK) MessageType() string { return "ships_ok" }
+
+type ShipsError struct {
+	Error string `json:"error"`
+}
+
+func (ShipsError) MessageType() string { return "ships_error" }
+
+// AutoPlaced answers auto_place with the generated fleet and the seed
+// that reproduces it.
+type AutoPlaced struct {
+	MatchID string           `json:"match_id"`
+	Seed    int64            `json:"seed"`
+	Ships   []game.Placement `json:"ships"`
+}
+
+func (AutoPlaced) MessageType() string { return "auto_placed" }
+
+type AllShipsReady struct {
+	MatchID    string `json:"match_id"`
+	StartTurn  string `json:"start_turn"`
+	YourSide   string `json:"your_side"`
+	OpponentID string `json:"opponent_id"`
+	// SalvoSize is the first volley's size in salvo mode.
+	SalvoSize int `json:"salvo_size,omitempty"`
+}
+
+func (AllShipsReady) MessageType() string { return "all_ships_ready" }
+
+type ShotResult struct {
+	MatchID   string `json:"match_id"`
+	X         int    `json:"x"`
+	Y         int    `json:"y"`
+	ShooterID string `json:"shooter_id"`
+	TargetID  string `json:"target_id"`
+	Hit       bool   `json:"hit"`
+	Message   string `json:"message"`
+	GameOver  bool   `json:"game_over"`
+	WinnerID  string `json:"winner_id,omitempty"`
+	NextTurn  string `json:"next_turn,omitempty"`
+}
+
+func (ShotResult) MessageType() string { return "shot_result" }
+
+type SalvoShot struct {
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+	Hit  bool   `json:"hit"`
+	Sunk string `json:"sunk,omitempty"`
+}
+
+// SalvoResult reports a whole volley. NextSalvo is the size of the next
+// volley, fired by the side in NextTurn.
+type SalvoResult struct {
+	MatchID   string      `json:"match_id"`
+	ShooterID string      `json:"shooter_id"`
+	TargetID  string      `json:"target_id"`
+	Shots     []SalvoShot `json:"shots"`
+	Hits      int         `json:"hits"`
+	GameOver  bool        `json:"game_over"`
+	WinnerID  string      `json:"winner_id,omitempty"`
+	NextTurn  string      `json:"next_turn,omitempty"`
+	NextSalvo int         `json:"next_salvo,omitempty"`
+}
+
+func (SalvoResult) MessageType() string { return "salvo_result" }
+
+type ShotError struct {
+	MatchID string `json:"match_id,omitempty"`
+	Error   string `json:"error"`
+}
+
+func (ShotError) MessageType() string { return "shot_error" }
+
+type ShipSunk struct {
+	MatchID  string `json:"match_id"`
+	ShipType string `json:"ship_type"`
+	OwnerID  string `json:"owner_id"`
+	ByID     string `json:"by_id"`
+	// Cells is left out for the shooter and spectators when the rules do
+	// not announce sinkings.
+	Cells []game.Coord `json:"cells,omitempty"`
+	// Water lists surrounding cells marked as missed under a spacing rule.
+	Water []game.Coord `json:"water,omitempty"`
+}
+
+func (ShipSunk) MessageType() string { return "ship_sunk" }
+
+type SunkShip struct {
+	OwnerID  string `json:"owner_id"`
+	ShipType string `json:"ship_type"`
+}
+
+type MatchResync struct {
+	MatchID       string        `json:"match_id"`
+	Phase         string        `json:"phase"`
+	YourSide      string        `json:"your_side"`
+	OpponentID    string        `json:"opponent_id"`
+	OpponentName  string        `json:"opponent_name"`
+	Ready         bool          `json:"ready"`
+	OpponentReady bool          `json:"opponent_ready"`
+	Started       bool          `json:"started"`
+	Turn          string        `json:"turn,omitempty"`
+	YourBoard     [][]game.Cell `json:"your_board"`
+	OpponentBoard [][]game.Cell `json:"opponent_board"`
+	SunkShips     []SunkShip    `json:"sunk_ships"`
+	Rules         game.RuleSet  `json:"rules"`
+	SalvoSize     int           `json:"salvo_size,omitempty"`
+}
+
+func (MatchResync) MessageType() string { return "match_resync" }
+
+type TurnClock struct {
+	MatchID     string           `json:"match_id"`
+	Turn        string           `json:"turn"`
+	PlayerID    string           `json:"player_id"`
+	DeadlineMs  int64            `json:"deadline_ms,omitempty"`
+	TurnMs      int64            `json:"turn_ms,omitempty"`
+	RemainingMs map[string]int64 `json:"remaining_ms,omitempty"`
+}
+
+func (TurnClock) MessageType() string { return "turn_clock" }
+
+type TurnTimeout struct {
+	MatchID  string `json:"match_id"`
+	PlayerID string `json:"player_id"`
+	Side     string `json:"side"`
+	Timeouts int    `json:"timeouts"`
+	Action   string `json:"action"`
+}
+
+func (TurnTimeout) MessageType() string { return "turn_timeout" }
+
+type MatchOver struct {
+	MatchID     string                `json:"match_id"`
+	WinnerID    string                `json:"winner_id,omitempty"`
+	LoserID     string                `json:"loser_id,omitempty"`
+	Reason      string                `json:"reason"`
+	DurationMs  int64                 `json:"duration_ms"`
+	Stats       map[string]game.Stats `json:"stats"`
+	SeriesScore map[string]int        `json:"series_score"`
+	// Ratings holds both players' new ratings after a rated match.
+	Ratings map[string]int `json:"ratings,omitempty"`
+	// SeriesID and BestOf repeat the series the match belongs to. When a
+	// best-of-N series is not yet decided its next game follows on its own.
+	SeriesID string `json:"series_id,omitempty"`
+	BestOf   int    `json:"best_of,omitempty"`
+}
+
+func (MatchOver) MessageType() string { return "match_over" }
+
+// SeriesOver ends a best-of-N series. Reason is "decided", "forfeit" when
+// a game was forfeited or a player did not return for the next one, or
+// "abandoned" when a game ended without a winner.
+type SeriesOver struct {
+	SeriesID string         `json:"series_id"`
+	BestOf   int            `json:"best_of"`
+	WinnerID string         `json:"winner_id,omitempty"`
+	Score    map[string]int `json:"score"`
+	MatchIDs []string       `json:"match_ids"`
+	Reason   string         `json:"reason"`
+}
+
+func (SeriesOver) MessageType() string { return "series_over" }
+
+type OpponentDisconnected struct {
+	MatchID    string `json:"match_id"`
+	PlayerID   string `json:"player_id"`
+	GraceMs    int64  `json:"grace_ms"`
+	DeadlineMs int64  `json:"deadline_ms"`
+}
+
+func (OpponentDisconnected) MessageType() string { return "opponent_disconnected" }
+
+type OpponentReconnected struct {
+	MatchID  string `json:"match_id"`
+	PlayerID string `json:"player_id"`
+}
+
+func (OpponentReconnected) MessageType() string { return "opponent_reconnected" }
+
+type RematchOffer struct {
+	MatchID   string `json:"match_id"`
+	FromID    string `json:"from_id"`
+	FromName  string `json:"from_name"`
+	SwapFirst bool   `json:"swap_first"`
+}
+
+func (RematchOffer) MessageType() string { return "rematch_offer" }
+
+type RematchResponseForward struct {
+	MatchID  string `json:"match_id"`
+	FromID   string `json:"from_id"`
+	FromName string `json:"from_name"`
+	Accept   bool   `json:"accept"`
+	// Reason says why an accepted rematch could not start.
+	Reason string `json:"reason,omitempty"`
+}
+
+func (RematchResponseForward) MessageType() string { return "rematch_response_forward" }
+
+// SpectateState is a spectator's view of a match, keyed by player ID. It is
+// sent on subscribing, when the battle starts and when the match ends.
+type SpectateState struct {
+	MatchID     string                   `json:"match_id"`
+	Reveal      bool                     `json:"reveal"`
+	DelayMs     int64                    `json:"delay_ms,omitempty"`
+	Phase       string                   `json:"phase"`
+	PlayerAID   string                   `json:"playerA_id"`
+	PlayerAName string                   `json:"playerA_name"`
+	PlayerBID   string                   `json:"playerB_id"`
+	PlayerBName string                   `json:"playerB_name"`
+	Turn        string                   `json:"turn,omitempty"`
+	Boards      map[string][][]game.Cell `json:"boards"`
+	SunkShips   []SunkShip               `json:"sunk_ships"`
+	Spectators  int                      `json:"spectators"`
+	Rules       game.RuleSet             `json:"rules"`
+}
+
+func (SpectateState) MessageType() string { return "spectate_state" }
+
+type SpectatorCount struct {
+	MatchID string `json:"match_id"`
+	Count   int    `json:"count"`
+}
+
+func (SpectatorCount) MessageType() string { return "spectator_count" }
+
+type ChatMessage struct {
+	Channel  string `json:"channel"`
+	MatchID  string `json:"match_id,omitempty"`
+	FromID   string `json:"from_id"`
+	FromName string `json:"from_name"`
+	Text     string `json:"text"`
+	SentAt   int64  `json:"sent_at"`
+}
+
+func (ChatMessage) MessageType() string { return "chat_message" }
+
+type ChatHistory struct {
+	Channel  string        `json:"channel"`
+	MatchID  string        `json:"match_id,omitempty"`
+	Messages []ChatMessage `json:"messages"`
+}
+
+func (ChatHistory) MessageType() string { return "chat_history" }
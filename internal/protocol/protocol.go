@@ -0,0 +1,162 @@
+// Package protocol defines the typed WebSocket wire format shared by the
+// server, the web client and bots.
+package protocol
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+)
+
+const (
+	// Version is the protocol revision spoken by this server.
+	Version = 1
+	// MinVersion is the oldest revision the server still accepts.
+	MinVersion = 1
+	// MaxMessageSize bounds an inbound frame. The largest valid message is
+	// a tournament_create with a full roster of UUIDs, a fully escaped name
+	// and custom rules, which stays under half of this.
+	MaxMessageSize = 8 << 10
+)
+
+var (
+	ErrUnknownType = errors.New("unknown_type")
+	ErrBadPayload  = errors.New("bad_payload")
+)
+
+// Message is implemented by every wire message.
+type Message interface {
+	MessageType() string
+}
+
+// Inbound maps each client message type to a constructor for its payload.
+var Inbound = map[string]func() Message{}
+
+// Outbound lists a prototype of every server message, for schema export.
+var Outbound = map[string]Message{}
+
+func registerInbound(f func() Message) {
+	Inbound[f().MessageType()] = f
+}
+
+func registerOutbound(m Message) {
+	Outbound[m.MessageType()] = m
+}
+
+func init() {
+	registerInbound(func() Message { return &Join{} })
+	registerInbound(func() Message { return &Rename{} })
+	registerInbound(func() Message { return &Challenge{} })
+	registerInbound(func() Message { return &ChallengeResponse{} })
+	registerInbound(func() Message { return &ChallengeCancel{} })
+	registerInbound(func() Message { return &PlaceShips{} })
+	registerInbound(func() Message { return &ShotFired{} })
+	registerInbound(func() Message { return &SalvoFired{} })
+	registerInbound(func() Message { return &AutoPlace{} })
+	registerInbound(func() Message { return &QueueJoin{} })
+	registerInbound(func() Message { return &QueueLeave{} })
+	registerInbound(func() Message { return &RoomCreate{} })
+	registerInbound(func() Message { return &RoomJoin{} })
+	registerInbound(func() Message { return &RoomClose{} })
+	registerInbound(func() Message { return &TournamentCreate{} })
+	registerInbound(func() Message { return &TournamentCancel{} })
+	registerInbound(func() Message { return &TournamentResponse{} })
+	registerInbound(func() Message { return &RematchRequest{} })
+	registerInbound(func() Message { return &RematchResponse{} })
+	registerInbound(func() Message { return &Spectate{} })
+	registerInbound(func() Message { return &StopSpectating{} })
+	registerInbound(func() Message { return &Chat{} })
+	registerInbound(func() Message { return &ChatMute{} })
+
+	registerOutbound(Welcome{})
+	registerOutbound(JoinAck{})
+	registerOutbound(JoinError{})
+	registerOutbound(Renamed{})
+	registerOutbound(LobbyUpdate{})
+	registerOutbound(Error{})
+	registerOutbound(ChallengeRequest{})
+	registerOutbound(ChallengeSent{})
+	registerOutbound(ChallengeCancelled{})
+	registerOutbound(RoomCreated{})
+	registerOutbound(RoomClosed{})
+	registerOutbound(TournamentUpdate{})
+	registerOutbound(ChallengeResponseForward{})
+	registerOutbound(QueueStatus{})
+	registerOutbound(MatchStart{})
+	registerOutbound(ShipsOK{})
+	registerOutbound(ShipsError{})
+	registerOutbound(AutoPlaced{})
+	registerOutbound(AllShipsReady{})
+	registerOutbound(ShotResult{})
+	registerOutbound(SalvoResult{})
+	registerOutbound(ShotError{})
+	registerOutbound(ShipSunk{})
+	registerOutbound(MatchResync{})
+	registerOutbound(TurnClock{})
+	registerOutbound(TurnTimeout{})
+	registerOutbound(MatchOver{})
+	registerOutbound(SeriesOver{})
+	registerOutbound(OpponentDisconnected{})
+	registerOutbound(OpponentReconnected{})
+	registerOutbound(RematchOffer{})
+	registerOutbound(RematchResponseForward{})
+	registerOutbound(SpectateState{})
+	registerOutbound(SpectatorCount{})
+	registerOutbound(ChatMessage{})
+	registerOutbound(ChatHistory{})
+}
+
+// Negotiate picks the version to speak with a client that announced
+// clientVersion (0 meaning unspecified).
+func Negotiate(clientVersion int) (int, bool) {
+	if clientVersion == 0 {
+		return Version, true
+	}
+	if clientVersion < MinVersion {
+		return 0, false
+	}
+	if clientVersion > Version {
+		return Version, true
+	}
+	return clientVersion, true
+}
+
+// Encode marshals m and stamps its type into the JSON object.
+func Encode(m Message) ([]byte, error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	typ, _ := json.Marshal(m.MessageType())
+
+	var buf bytes.Buffer
+	buf.WriteString(`{"type":`)
+	buf.Write(typ)
+	if len(body) > 2 {
+		buf.WriteByte(',')
+		buf.Write(body[1:])
+	} else {
+		buf.WriteByte('}')
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode parses an inbound frame into its registered payload type. The
+// type string is returned even when the payload is rejected.
+func Decode(data []byte) (string, Message, error) {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return "", nil, ErrBadPayload
+	}
+	f, ok := Inbound[envelope.Type]
+	if !ok {
+		return envelope.Type, nil, ErrUnknownType
+	}
+	m := f()
+	if err := json.Unmarshal(data, m); err != nil {
+		return envelope.Type, nil, ErrBadPayload
+	}
+	return envelope.Type, m, nil
+}
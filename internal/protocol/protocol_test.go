@@ -0,0 +1,219 @@
+package protocol
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/tournament"
+)
+
+// largestRules is the biggest rule set Validate accepts: every ship its
+// own type with a name of the maximum length.
+func largestRules(t *testing.T) game.RuleSet {
+	t.Helper()
+	r := game.DefaultRules()
+	r.Width, r.Height = game.MaxBoardSize, game.MaxBoardSize
+	r.Mode, r.SalvoShots = game.ModeSalvo, game.MaxSalvoShots
+	r.Fleet = nil
+	for i := 0; i < game.MaxShips; i++ {
+		name := fmt.Sprintf("%02d", i) + strings.Repeat("<", game.MaxShipTypeLength-2)
+		r.Fleet = append(r.Fleet, game.ShipSpec{Type: name, Size: 10, Count: 1})
+	}
+	if err := r.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestLargestMessagesFit(t *testing.T) {
+	rules := largestRules(t)
+	matchID := strings.Repeat("f", 36)
+	var ships []game.Placement
+	var shots []game.Coord
+	for i, spec := range rules.Fleet {
+		ships = append(ships, game.Placement{Type: spec.Type, X: game.MaxBoardSize - 1, Y: i, Dir: "H"})
+		shots = append(shots, game.Coord{X: game.MaxBoardSize - 1, Y: i})
+	}
+	roster := make([]string, tournament.MaxPlayers)
+	for i := range roster {
+		roster[i] = matchID
+	}
+	name := strings.Repeat("<", 40)
+
+	for _, m := range []Message{
+		PlaceShips{MatchID: matchID, Ships: ships},
+		SalvoFired{MatchID: matchID, Shots: shots},
+		Challenge{TargetID: matchID, Rules: &rules, BestOf: 7},
+		TournamentCreate{Name: name, Format: tournament.DoubleElimination, Players: roster, Rules: &rules, BestOf: 7},
+		Chat{MatchID: matchID, Text: strings.Repeat("<", 200)},
+	} {
+		b, err := Encode(m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(b) > MaxMessageSize {
+			t.Errorf("%s: %d bytes, over the %d byte limit", m.MessageType(), len(b), MaxMessageSize)
+		}
+	}
+}
+
+func TestRuleLimits(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(r *game.RuleSet)
+		err  string
+	}{
+		{"too many ships", func(r *game.RuleSet) {
+			r.Fleet = []game.ShipSpec{{Type: "dinghy", Size: 1, Count: game.MaxShips + 1}}
+		}, "bad_ship_count:dinghy"},
+		{"too many kinds", func(r *game.RuleSet) {
+			r.Fleet = nil
+			for i := 0; i <= game.MaxShips; i++ {
+				r.Fleet = append(r.Fleet, game.ShipSpec{Type: fmt.Sprint("s", i), Size: 1, Count: 1})
+			}
+		}, "too_many_ships"},
+		{"long type", func(r *game.RuleSet) {
+			r.Fleet = []game.ShipSpec{{Type: strings.Repeat("x", game.MaxShipTypeLength+1), Size: 2, Count: 1}}
+		}, "bad_ship_type:" + strings.Repeat("x", game.MaxShipTypeLength+1)},
+		{"big volley", func(r *game.RuleSet) {
+			r.Mode, r.SalvoShots = game.ModeSalvo, game.MaxSalvoShots+1
+		}, "bad_salvo_shots"},
+	}
+	for _, tt := range tests {
+		r := largestRules(t)
+		r.Mode, r.SalvoShots = game.ModeClassic, 0
+		tt.edit(&r)
+		if err := r.Validate(); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: Validate() = %v, want %s", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	salvo := game.DefaultRules()
+	salvo.Mode = game.ModeSalvo
+	seed := int64(42)
+	msgs := []Message{
+		&Join{Name: "Ann", ProtocolVersion: Version},
+		&Challenge{TargetID: "b", Rules: &salvo, BestOf: 3},
+		&Challenge{TargetID: "b", Mode: game.ModeSalvo, SalvoShots: 2},
+		&PlaceShips{MatchID: "m", Ships: []game.Placement{{Type: "carrier", X: 1, Y: 2, Dir: "V"}}},
+		&ShotFired{MatchID: "m", X: 3, Y: 4},
+		&SalvoFired{MatchID: "m", Shots: []game.Coord{{X: 1, Y: 1}, {X: 2, Y: 2}}},
+		&AutoPlace{MatchID: "m", Seed: &seed, FleetConstraints: game.FleetConstraints{Spacing: game.SpacingEdges}, Place: true},
+		&TournamentCreate{Name: "Cup", Format: tournament.Swiss, Players: []string{"a", "b", "c"}, Rounds: 2},
+	}
+	for f := range Inbound {
+		// every registered type survives with its zero payload too
+		msgs = append(msgs, Inbound[f]())
+	}
+	for _, m := range msgs {
+		t.Run(m.MessageType(), func(t *testing.T) {
+			b, err := Encode(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			typ, got, err := Decode(b)
+			if err != nil || typ != m.MessageType() {
+				t.Fatalf("Decode(%s) = %q, %v", b, typ, err)
+			}
+			if !reflect.DeepEqual(got, m) {
+				t.Errorf("Decode(%s) = %+v, want %+v", b, got, m)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		frame string
+		typ   string
+		err   error
+	}{
+		{`not json`, "", ErrBadPayload},
+		{`{"type":"teleport"}`, "teleport", ErrUnknownType},
+		{`{"type":"shot_fired","x":"a1"}`, "shot_fired", ErrBadPayload},
+		{`{"type":"challenge","rules":{"width":"wide"}}`, "challenge", ErrBadPayload},
+	}
+	for _, tt := range tests {
+		t.Run(tt.frame, func(t *testing.T) {
+			typ, _, err := Decode([]byte(tt.frame))
+			if typ != tt.typ || err != tt.err {
+				t.Errorf("Decode = %q, %v; want %q, %v", typ, err, tt.typ, tt.err)
+			}
+		})
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		client, want int
+		ok           bool
+	}{
+		{0, Version, true},
+		{MinVersion, MinVersion, true},
+		{Version + 1, Version, true},
+		{-1, 0, false},
+	}
+	for _, tt := range tests {
+		if got, ok := Negotiate(tt.client); got != tt.want || ok != tt.ok {
+			t.Errorf("Negotiate(%d) = %d, %v; want %d, %v", tt.client, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+// TestSchemaMatchesMessages checks every message encodes the fields its
+// schema requires.
+func TestSchemaMatchesMessages(t *testing.T) {
+	defs := Schema(Both)["definitions"].(map[string]interface{})
+	msgs := map[string]Message{}
+	for typ, f := range Inbound {
+		msgs[typ] = f()
+	}
+	for typ, m := range Outbound {
+		msgs[typ] = m
+	}
+	if len(defs) != len(msgs) {
+		t.Errorf("schema has %d definitions for %d messages", len(defs), len(msgs))
+	}
+	for typ, m := range msgs {
+		def, ok := defs[typ].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: no definition", typ)
+			continue
+		}
+		b, _ := Encode(m)
+		var fields map[string]json.RawMessage
+		json.Unmarshal(b, &fields)
+		for _, name := range def["required"].([]string) {
+			if _, ok := fields[name]; !ok {
+				t.Errorf("%s: required field %q is not encoded", typ, name)
+			}
+		}
+	}
+}
+
+func TestSchemaRulesAreOptional(t *testing.T) {
+	defs := Schema(ClientToServer)["definitions"].(map[string]interface{})
+	challenge := defs["challenge"].(map[string]interface{})
+	rules := challenge["properties"].(map[string]interface{})["rules"].(map[string]interface{})
+	if req := rules["required"].([]string); len(req) != 0 {
+		t.Errorf("rules require %v, but missing fields take the defaults", req)
+	}
+	fleet := rules["properties"].(map[string]interface{})["fleet"].(map[string]interface{})
+	if req := fleet["items"].(map[string]interface{})["required"].([]string); len(req) != 3 {
+		t.Errorf("ship specs require %v, want type, size and count", req)
+	}
+
+	_, m, err := Decode([]byte(`{"type":"challenge","target_id":"b","rules":{"mode":"salvo"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := m.(*Challenge).Rules; r.Width != 10 || r.Height != 10 || len(r.Fleet) != len(game.DefaultFleet) {
+		t.Errorf("rules without a board decoded to %+v", r)
+	}
+}
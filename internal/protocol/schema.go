@@ -0,0 +1,127 @@
+package protocol
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Direction selects which half of the protocol a schema describes.
+type Direction string
+
+const (
+	Both           Direction = ""
+	ClientToServer Direction = "inbound"
+	ServerToClient Direction = "outbound"
+)
+
+// Schema builds a JSON Schema (draft-07) document describing every message
+// in dir, keyed by message type under "definitions".
+func Schema(dir Direction) map[string]interface{} {
+	defs := map[string]interface{}{}
+	if dir != ServerToClient {
+		for typ, f := range Inbound {
+			defs[typ] = messageSchema(typ, reflect.TypeOf(f()))
+		}
+	}
+	if dir != ClientToServer {
+		for typ, m := range Outbound {
+			defs[typ] = messageSchema(typ, reflect.TypeOf(m))
+		}
+	}
+
+	names := make([]string, 0, len(defs))
+	for typ := range defs {
+		names = append(names, typ)
+	}
+	sort.Strings(names)
+	refs := make([]interface{}, 0, len(names))
+	for _, typ := range names {
+		refs = append(refs, map[string]interface{}{"$ref": "#/definitions/" + typ})
+	}
+
+	return map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "battleship-go wire protocol",
+		"version":     Version,
+		"definitions": defs,
+		"oneOf":       refs,
+	}
+}
+
+func messageSchema(typ string, t reflect.Type) map[string]interface{} {
+	s := typeSchema(t)
+	props := s["properties"].(map[string]interface{})
+	props["type"] = map[string]interface{}{"const": typ}
+	s["required"] = append([]string{"type"}, s["required"].([]string)...)
+	return s
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func typeSchema(t reflect.Type) map[string]interface{} {
+	t = indirect(t)
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		required := []string{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			// untagged embedded structs are flattened, as encoding/json does
+			if f.Anonymous && name == "" && indirect(f.Type).Kind() == reflect.Struct {
+				inner := typeSchema(f.Type)
+				for k, v := range inner["properties"].(map[string]interface{}) {
+					props[k] = v
+				}
+				required = append(required, inner["required"].([]string)...)
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			props[name] = typeSchema(f.Type)
+			if !strings.Contains(opts, "omitempty") {
+				required = append(required, name)
+			}
+		}
+		// a type that decodes itself, like game.RuleSet, fills in what a
+		// document leaves out
+		if reflect.PointerTo(t).Implements(unmarshalerType) {
+			required = []string{}
+		}
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+			"required":   required,
+		}
+	}
+	return map[string]interface{}{}
+}
@@ -0,0 +1,24 @@
+// Package rating implements Elo ratings for rated matches.
+package rating
+
+import "math"
+
+const (
+	// Initial is the rating a new profile starts with.
+	Initial = 1200
+	// K scales how far a single result moves a rating.
+	K = 32
+)
+
+// Expected returns the score a player rated a is expected to take off a
+// player rated b, between 0 and 1.
+func Expected(a, b int) float64 {
+	return 1 / (1 + math.Pow(10, float64(b-a)/400))
+}
+
+// Update returns the ratings of a and b after a game in which a scored
+// scoreA: 1 for a win, 0 for a loss, 0.5 for a draw.
+func Update(a, b int, scoreA float64) (int, int) {
+	delta := int(math.Round(K * (scoreA - Expected(a, b))))
+	return a + delta, b - delta
+}
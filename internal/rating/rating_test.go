@@ -0,0 +1,44 @@
+package rating
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExpected(t *testing.T) {
+	if got := Expected(1500, 1500); got != 0.5 {
+		t.Errorf("Expected(1500, 1500) = %v, want 0.5", got)
+	}
+	// 400 points apart is ten to one
+	if got := Expected(1600, 1200); math.Abs(got-10.0/11) > 1e-9 {
+		t.Errorf("Expected(1600, 1200) = %v, want %v", got, 10.0/11)
+	}
+	if sum := Expected(1320, 1100) + Expected(1100, 1320); math.Abs(sum-1) > 1e-9 {
+		t.Errorf("expected scores sum to %v, want 1", sum)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		a, b   int
+		scoreA float64
+		wantA  int
+		wantB  int
+	}{
+		{Initial, Initial, 1, Initial + K/2, Initial - K/2},
+		{Initial, Initial, 0, Initial - K/2, Initial + K/2},
+		{Initial, Initial, 0.5, Initial, Initial},
+		// the favourite gains little for a win and loses a lot for a loss
+		{1600, 1200, 1, 1603, 1197},
+		{1600, 1200, 0, 1571, 1229},
+	}
+	for _, tt := range tests {
+		a, b := Update(tt.a, tt.b, tt.scoreA)
+		if a != tt.wantA || b != tt.wantB {
+			t.Errorf("Update(%d, %d, %v) = %d, %d, want %d, %d", tt.a, tt.b, tt.scoreA, a, b, tt.wantA, tt.wantB)
+		}
+		if a+b != tt.a+tt.b {
+			t.Errorf("Update(%d, %d, %v) changed the rating pool by %d", tt.a, tt.b, tt.scoreA, a+b-tt.a-tt.b)
+		}
+	}
+}
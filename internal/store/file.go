@@ -0,0 +1,370 @@
+package store
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// SyncInterval is how often the FileStore flushes and syncs match and
+// series saves and events. A crash loses at most this much of a match's
+// progress; archives, profiles, accounts and revocations are synced before
+// their call returns.
+var SyncInterval = 100 * time.Millisecond
+
+// ArchiveRetention is how long the FileStore keeps finished matches and
+// their events; compaction drops older ones. Zero keeps them forever.
+var ArchiveRetention = 30 * 24 * time.Hour
+
+// compactMin is the fewest appended lines that trigger a compaction.
+const compactMin = 1000
+
+// logEntry is one line of the append-only match log.
+type logEntry struct {
+	Op      string        `json:"op"`
+	Match   *MatchRecord  `json:"match,omitempty"`
+	Event   *Event        `json:"event,omitempty"`
+	Series  *SeriesRecord `json:"series,omitempty"`
+	Profile *Profile      `json:"profile,omitempty"`
+	Account *Account      `json:"account,omitempty"`
+	Revoked *Revocation   `json:"revoked,omitempty"`
+}
+
+// FileStore is an append-only JSON lines log of match saves, archives,
+// match events, series saves, profile saves, account saves and token
+// revocations.
+// The log is replayed into memory on open and compacted to one line per
+// live match, then again whenever it has doubled since.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	w    *bufio.Writer
+	mem  *MemoryStore
+	// dirty is set while written lines await a sync.
+	dirty bool
+	// compacted counts the lines the last compaction wrote and appended
+	// those written since.
+	compacted, appended int
+	stop                chan struct{}
+	done                chan struct{}
+	closeOnce           sync.Once
+	closeErr            error
+}
+
+func NewFileStore(path string) (*FileStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	s := &FileStore{path: path, mem: NewMemoryStore(), stop: make(chan struct{}), done: make(chan struct{})}
+	if err := s.replay(); err != nil {
+		return nil, err
+	}
+	if err := s.compact(); err != nil {
+		return nil, err
+	}
+	go s.syncLoop()
+	return s, nil
+}
+
+// syncLoop commits batched writes every SyncInterval and compacts the log
+// once it has doubled.
+func (s *FileStore) syncLoop() {
+	defer close(s.done)
+	t := time.NewTicker(SyncInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-t.C:
+		}
+		s.mu.Lock()
+		if err := s.sync(); err != nil {
+			log.Println("store: sync failed:", err)
+		}
+		if s.appended >= max(s.compacted, compactMin) {
+			if err := s.compact(); err != nil {
+				log.Println("store: compaction failed:", err)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// sync flushes and syncs the lines written since the last sync. Callers
+// hold s.mu.
+func (s *FileStore) sync() error {
+	if !s.dirty || s.f == nil {
+		return nil
+	}
+	if err := s.w.Flush(); err != nil {
+		return err
+	}
+	s.dirty = false
+	return s.f.Sync()
+}
+
+func (s *FileStore) replay() error {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		var e logEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			// a torn final write after a crash; keep what we have
+			log.Println("store: skipping bad log line:", err)
+			continue
+		}
+		switch e.Op {
+		case "save":
+			if e.Match != nil {
+				s.mem.SaveMatch(*e.Match)
+			}
+		case "archive":
+			if e.Match != nil {
+				s.mem.ArchiveMatch(*e.Match)
+			}
+		case "event":
+			if e.Event != nil {
+				s.mem.AppendEvent(*e.Event)
+			}
+		case "series":
+			if e.Series != nil {
+				s.mem.SaveSeries(*e.Series)
+			}
+		case "profile":
+			if e.Profile != nil {
+				s.mem.SaveProfile(*e.Profile)
+			}
+		case "account":
+			if e.Account != nil {
+				s.mem.SaveAccount(*e.Account)
+			}
+		case "revoke":
+			if e.Revoked != nil {
+				s.mem.RevokeToken(*e.Revoked)
+			}
+		}
+	}
+	return sc.Err()
+}
+
+// compact rewrites the log with one line per live or archived match, per
+// series still being played and per profile, account and unexpired
+// revocation, followed by every match event. Archives past ArchiveRetention are dropped first. Every appended
+// entry is already in memory, so lines still buffered for the old file
+// are dropped with it. Callers other than NewFileStore hold s.mu.
+func (s *FileStore) compact() error {
+	if ArchiveRetention > 0 {
+		if n := s.mem.pruneArchived(time.Now().Add(-ArchiveRetention)); n > 0 {
+			log.Println("store: dropped", n, "archived matches past retention")
+		}
+	}
+	recs, _ := s.mem.LoadMatches()
+	archived := s.mem.loadAllArchived()
+	events := s.mem.loadAllEvents()
+	series, _ := s.mem.LoadSeries()
+	profiles, _ := s.mem.LoadProfiles()
+	accounts := s.mem.loadAllAccounts()
+	revoked := s.mem.loadAllRevoked()
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range recs {
+		if err := enc.Encode(logEntry{Op: "save", Match: &recs[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for i := range archived {
+		if err := enc.Encode(logEntry{Op: "archive", Match: &archived[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for i := range series {
+		if err := enc.Encode(logEntry{Op: "series", Series: &series[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for i := range profiles {
+		if err := enc.Encode(logEntry{Op: "profile", Profile: &profiles[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for i := range accounts {
+		if err := enc.Encode(logEntry{Op: "account", Account: &accounts[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for i := range revoked {
+		if err := enc.Encode(logEntry{Op: "revoke", Revoked: &revoked[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	for i := range events {
+		if err := enc.Encode(logEntry{Op: "event", Event: &events[i]}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	if s.f != nil {
+		s.f.Close()
+	}
+	s.f, err = os.OpenFile(s.path, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		s.f = nil
+		return err
+	}
+	s.w = bufio.NewWriter(s.f)
+	s.dirty = false
+	s.compacted = len(recs) + len(archived) + len(series) + len(profiles) + len(accounts) + len(revoked) + len(events)
+	s.appended = 0
+	return nil
+}
+
+// append writes e and applies it to memory in the same critical section,
+// so a compaction never sees one without the other. Durable entries are
+// synced at once, together with any batched lines before them; the rest
+// wait for syncLoop.
+func (s *FileStore) append(e logEntry, durable bool, apply func() error) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f == nil {
+		return errors.New("store_closed")
+	}
+	if _, err := s.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	s.dirty = true
+	s.appended++
+	if durable {
+		if err := s.sync(); err != nil {
+			return err
+		}
+	}
+	return apply()
+}
+
+func (s *FileStore) SaveMatch(rec MatchRecord) error {
+	return s.append(logEntry{Op: "save", Match: &rec}, false, func() error { return s.mem.SaveMatch(rec) })
+}
+
+func (s *FileStore) LoadMatches() ([]MatchRecord, error) {
+	return s.mem.LoadMatches()
+}
+
+func (s *FileStore) ArchiveMatch(rec MatchRecord) error {
+	return s.append(logEntry{Op: "archive", Match: &rec}, true, func() error { return s.mem.ArchiveMatch(rec) })
+}
+
+func (s *FileStore) LoadArchived(matchID string) (MatchRecord, bool, error) {
+	return s.mem.LoadArchived(matchID)
+}
+
+func (s *FileStore) AppendEvent(ev Event) error {
+	return s.append(logEntry{Op: "event", Event: &ev}, false, func() error { return s.mem.AppendEvent(ev) })
+}
+
+func (s *FileStore) LoadEvents(matchID string) ([]Event, error) {
+	return s.mem.LoadEvents(matchID)
+}
+
+func (s *FileStore) SaveSeries(rec SeriesRecord) error {
+	return s.append(logEntry{Op: "series", Series: &rec}, false, func() error { return s.mem.SaveSeries(rec) })
+}
+
+func (s *FileStore) LoadSeries() ([]SeriesRecord, error) {
+	return s.mem.LoadSeries()
+}
+
+func (s *FileStore) SaveProfile(p Profile) error {
+	return s.append(logEntry{Op: "profile", Profile: &p}, true, func() error { return s.mem.SaveProfile(p) })
+}
+
+func (s *FileStore) LoadProfile(id string) (Profile, bool, error) {
+	return s.mem.LoadProfile(id)
+}
+
+func (s *FileStore) LoadProfiles() ([]Profile, error) {
+	return s.mem.LoadProfiles()
+}
+
+func (s *FileStore) SaveAccount(a Account) error {
+	return s.append(logEntry{Op: "account", Account: &a}, true, func() error { return s.mem.SaveAccount(a) })
+}
+
+func (s *FileStore) LoadAccount(id string) (Account, bool, error) {
+	return s.mem.LoadAccount(id)
+}
+
+func (s *FileStore) FindAccount(username string) (Account, bool, error) {
+	return s.mem.FindAccount(username)
+}
+
+func (s *FileStore) RevokeToken(r Revocation) error {
+	return s.append(logEntry{Op: "revoke", Revoked: &r}, true, func() error { return s.mem.RevokeToken(r) })
+}
+
+func (s *FileStore) TokenRevoked(signature string) (bool, error) {
+	return s.mem.TokenRevoked(signature)
+}
+
+// Close syncs any batched writes and closes the log. Later calls return
+// the first one's result.
+func (s *FileStore) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		<-s.done
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.f == nil {
+			return
+		}
+		s.closeErr = s.sync()
+		if err := s.f.Close(); s.closeErr == nil {
+			s.closeErr = err
+		}
+		s.f = nil
+	})
+	return s.closeErr
+}
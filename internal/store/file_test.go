@@ -0,0 +1,157 @@
+package store
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFileStoreKeepsRevocations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "matches.log")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeToken(Revocation{Signature: "live", Until: time.Now().Add(time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeToken(Revocation{Signature: "stale", Until: time.Now().Add(-time.Hour)}); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for sig, want := range map[string]bool{"live": true, "stale": false, "never": false} {
+		if got, _ := s.TokenRevoked(sig); got != want {
+			t.Errorf("TokenRevoked(%q) = %v, want %v", sig, got, want)
+		}
+	}
+}
+
+func TestFileStoreBatchesAndCompacts(t *testing.T) {
+	defer func(d time.Duration) { SyncInterval = d }(SyncInterval)
+	SyncInterval = 5 * time.Millisecond
+	path := filepath.Join(t.TempDir(), "matches.log")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	// a saved snapshot and an event per shot, as a long match writes them
+	for i := 1; i <= 2*compactMin; i++ {
+		if err := s.SaveMatch(MatchRecord{MatchID: "m", PlayerAID: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AppendEvent(Event{MatchID: "m", Seq: i, Type: "shot"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// without closing the store, the log gets synced and compacted well
+	// below the lines written
+	deadline := time.Now().Add(5 * time.Second)
+	for !complete(t, path) {
+		if time.Now().After(deadline) {
+			t.Fatal("batched writes never reached the log")
+		}
+		time.Sleep(SyncInterval)
+	}
+	if n := lines(t, path); n >= 4*compactMin {
+		t.Errorf("log has %d lines, want it compacted", n)
+	}
+}
+
+// complete reports whether a store opened from a copy of path, as after
+// a crash, has the last save and every event of
+// TestFileStoreBatchesAndCompacts.
+func complete(t *testing.T, path string) bool {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashed := path + ".copy"
+	if err := os.WriteFile(crashed, b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewFileStore(crashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	recs, _ := s.LoadMatches()
+	events, _ := s.LoadEvents("m")
+	return len(recs) == 1 && recs[0].PlayerAID == strconv.Itoa(2*compactMin) && len(events) == 2*compactMin
+}
+
+func lines(t *testing.T, path string) int {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(b, []byte("\n"))
+}
+
+func TestFileStorePrunesOldArchives(t *testing.T) {
+	defer func(d time.Duration) { ArchiveRetention = d }(ArchiveRetention)
+	ArchiveRetention = time.Hour
+	path := filepath.Join(t.TempDir(), "matches.log")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id, age := range map[string]time.Duration{"old": 2 * time.Hour, "recent": time.Minute} {
+		s.AppendEvent(Event{MatchID: id, Seq: 1, Type: "match_start"})
+		s.ArchiveMatch(MatchRecord{MatchID: id, Result: &MatchResult{Reason: "all_ships_sunk", FinishedAt: time.Now().Add(-age)}})
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for id, keep := range map[string]bool{"old": false, "recent": true} {
+		_, ok, _ := s.LoadArchived(id)
+		events, _ := s.LoadEvents(id)
+		if ok != keep || (len(events) == 1) != keep {
+			t.Errorf("%s: archived %v with %d events, want kept = %v", id, ok, len(events), keep)
+		}
+	}
+}
+
+func TestFileStoreKeepsSeries(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "matches.log")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.SaveSeries(SeriesRecord{ID: "open", BestOf: 3, MatchIDs: []string{"m1"}})
+	s.SaveSeries(SeriesRecord{ID: "open", BestOf: 3, Score: map[string]int{"a": 1}, MatchIDs: []string{"m1", "m2"}})
+	s.SaveSeries(SeriesRecord{ID: "done", BestOf: 3})
+	s.SaveSeries(SeriesRecord{ID: "done", BestOf: 3, Over: true})
+	s.Close()
+
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	recs, _ := s.LoadSeries()
+	if len(recs) != 1 || recs[0].ID != "open" || len(recs[0].MatchIDs) != 2 || recs[0].Score["a"] != 1 {
+		t.Errorf("LoadSeries = %+v, want the latest save of the open series", recs)
+	}
+}
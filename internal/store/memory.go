@@ -0,0 +1,236 @@
+package store
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps records in process memory. It is the default when no
+// data directory is configured.
+type MemoryStore struct {
+	mu       sync.RWMutex
+	matches  map[string]MatchRecord
+	archived map[string]MatchRecord
+	events   map[string][]Event
+	series   map[string]SeriesRecord
+	profiles map[string]Profile
+	accounts map[string]Account
+	// usernames maps lower-cased usernames to account IDs
+	usernames map[string]string
+	// revoked maps token signatures to when they stop mattering
+	revoked map[string]time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		matches:   make(map[string]MatchRecord),
+		archived:  make(map[string]MatchRecord),
+		events:    make(map[string][]Event),
+		series:    make(map[string]SeriesRecord),
+		profiles:  make(map[string]Profile),
+		accounts:  make(map[string]Account),
+		usernames: make(map[string]string),
+		revoked:   make(map[string]time.Time),
+	}
+}
+
+func (s *MemoryStore) SaveMatch(rec MatchRecord) error {
+	s.mu.Lock()
+	s.matches[rec.MatchID] = rec
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) LoadMatches() ([]MatchRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]MatchRecord, 0, len(s.matches))
+	for _, rec := range s.matches {
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) ArchiveMatch(rec MatchRecord) error {
+	s.mu.Lock()
+	delete(s.matches, rec.MatchID)
+	s.archived[rec.MatchID] = rec
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) LoadArchived(matchID string) (MatchRecord, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rec, ok := s.archived[matchID]
+	return rec, ok, nil
+}
+
+func (s *MemoryStore) loadAllArchived() []MatchRecord {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]MatchRecord, 0, len(s.archived))
+	for _, rec := range s.archived {
+		out = append(out, rec)
+	}
+	return out
+}
+
+// pruneArchived forgets the archived matches that finished before cutoff,
+// events included, and returns how many it dropped.
+func (s *MemoryStore) pruneArchived(cutoff time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for id, rec := range s.archived {
+		if rec.Result != nil && rec.Result.FinishedAt.Before(cutoff) {
+			delete(s.archived, id)
+			delete(s.events, id)
+			n++
+		}
+	}
+	return n
+}
+
+func (s *MemoryStore) AppendEvent(ev Event) error {
+	s.mu.Lock()
+	s.events[ev.MatchID] = append(s.events[ev.MatchID], ev)
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) LoadEvents(matchID string) ([]Event, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Event(nil), s.events[matchID]...), nil
+}
+
+func (s *MemoryStore) loadAllEvents() []Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := []Event{}
+	for _, evs := range s.events {
+		out = append(out, evs...)
+	}
+	return out
+}
+
+func (s *MemoryStore) SaveSeries(rec SeriesRecord) error {
+	s.mu.Lock()
+	if rec.Over {
+		delete(s.series, rec.ID)
+	} else {
+		s.series[rec.ID] = rec
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) LoadSeries() ([]SeriesRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]SeriesRecord, 0, len(s.series))
+	for _, rec := range s.series {
+		out = append(out, rec)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) SaveProfile(p Profile) error {
+	s.mu.Lock()
+	s.profiles[p.ID] = p
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) LoadProfile(id string) (Profile, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.profiles[id]
+	return p, ok, nil
+}
+
+func (s *MemoryStore) LoadProfiles() ([]Profile, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Profile, 0, len(s.profiles))
+	for _, p := range s.profiles {
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func (s *MemoryStore) SaveAccount(a Account) error {
+	s.mu.Lock()
+	if old, ok := s.accounts[a.ID]; ok {
+		delete(s.usernames, strings.ToLower(old.Username))
+	}
+	s.accounts[a.ID] = a
+	s.usernames[strings.ToLower(a.Username)] = a.ID
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) LoadAccount(id string) (Account, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a, ok := s.accounts[id]
+	return a, ok, nil
+}
+
+func (s *MemoryStore) FindAccount(username string) (Account, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	id, ok := s.usernames[strings.ToLower(username)]
+	if !ok {
+		return Account{}, false, nil
+	}
+	return s.accounts[id], true, nil
+}
+
+func (s *MemoryStore) loadAllAccounts() []Account {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Account, 0, len(s.accounts))
+	for _, a := range s.accounts {
+		out = append(out, a)
+	}
+	return out
+}
+
+func (s *MemoryStore) RevokeToken(r Revocation) error {
+	now := time.Now()
+	s.mu.Lock()
+	for sig, until := range s.revoked {
+		if now.After(until) {
+			delete(s.revoked, sig)
+		}
+	}
+	if now.Before(r.Until) {
+		s.revoked[r.Signature] = r.Until
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) TokenRevoked(signature string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.revoked[signature]
+	return ok, nil
+}
+
+func (s *MemoryStore) loadAllRevoked() []Revocation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]Revocation, 0, len(s.revoked))
+	for sig, until := range s.revoked {
+		out = append(out, Revocation{Signature: sig, Until: until})
+	}
+	return out
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,115 @@
+// Package store persists match and series state so in-flight games survive
+// a restart, along with player profiles.
+package store
+
+import (
+	"encoding/json"
+	"time"
+
+	"battleship-go/internal/game"
+)
+
+// MatchRecord is everything needed to bring a match back after a restart.
+type MatchRecord struct {
+	MatchID   string    `json:"match_id"`
+	PlayerAID string    `json:"player_a_id"`
+	PlayerBID string    `json:"player_b_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	FirstID   string    `json:"first_id"`
+	// BotIDs lists the seats played by built-in bots.
+	BotIDs []string      `json:"bot_ids,omitempty"`
+	Game   game.Snapshot `json:"game"`
+	Result *MatchResult  `json:"result,omitempty"`
+}
+
+// SeriesRecord is a best-of-N series still being played. Score counts the
+// wins of every game in MatchIDs that has ended.
+type SeriesRecord struct {
+	ID        string         `json:"id"`
+	BestOf    int            `json:"best_of"`
+	Rules     game.RuleSet   `json:"rules"`
+	PlayerIDs [2]string      `json:"player_ids"`
+	Score     map[string]int `json:"score"`
+	MatchIDs  []string       `json:"match_ids"`
+	// FirstID shot first in the latest game.
+	FirstID string `json:"first_id,omitempty"`
+	// Over is set once the series has ended; saving it forgets the series.
+	Over bool `json:"over,omitempty"`
+}
+
+// MatchResult records how a finished or abandoned match ended.
+type MatchResult struct {
+	WinnerID   string    `json:"winner_id,omitempty"`
+	Reason     string    `json:"reason"`
+	FinishedAt time.Time `json:"finished_at"`
+}
+
+// Event is one entry of a match's append-only event log. Seq numbers start
+// at 1 and have no gaps; Data holds the type-specific payload.
+type Event struct {
+	MatchID string          `json:"match_id"`
+	Seq     int             `json:"seq"`
+	Type    string          `json:"type"`
+	At      time.Time       `json:"at"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Profile is a player's persistent record, keyed by their stable ID.
+type Profile struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	GamesPlayed int       `json:"games_played"`
+	Wins        int       `json:"wins"`
+	Losses      int       `json:"losses"`
+	Rating      int       `json:"rating"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Account is a registered login. Usernames are unique ignoring case; ID
+// doubles as the player ID of everyone who signs in to it.
+type Account struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	Hash      []byte    `json:"hash"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Revocation is a logged-out session token, kept until it would have
+// expired anyway.
+type Revocation struct {
+	Signature string    `json:"signature"`
+	Until     time.Time `json:"until"`
+}
+
+// Store saves and loads match records, profiles, accounts and revoked
+// session tokens. Implementations must be safe for concurrent use.
+type Store interface {
+	SaveMatch(rec MatchRecord) error
+	// LoadMatches returns the live (not archived) matches.
+	LoadMatches() ([]MatchRecord, error)
+	// ArchiveMatch moves a match out of the live set for good.
+	ArchiveMatch(rec MatchRecord) error
+	LoadArchived(matchID string) (MatchRecord, bool, error)
+	// AppendEvent adds ev to the end of its match's event log.
+	AppendEvent(ev Event) error
+	// LoadEvents returns a match's events in Seq order.
+	LoadEvents(matchID string) ([]Event, error)
+	// SaveSeries records a series, or forgets it once it is over.
+	SaveSeries(s SeriesRecord) error
+	// LoadSeries returns the series not yet over.
+	LoadSeries() ([]SeriesRecord, error)
+	SaveProfile(p Profile) error
+	LoadProfile(id string) (Profile, bool, error)
+	// LoadProfiles returns every stored profile.
+	LoadProfiles() ([]Profile, error)
+	SaveAccount(a Account) error
+	LoadAccount(id string) (Account, bool, error)
+	// FindAccount looks an account up by username, ignoring case.
+	FindAccount(username string) (Account, bool, error)
+	// RevokeToken records a logged-out token signature and forgets the
+	// ones past their Until.
+	RevokeToken(r Revocation) error
+	TokenRevoked(signature string) (bool, error)
+	Close() error
+}
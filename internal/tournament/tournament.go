@@ -0,0 +1,652 @@
+// Package tournament keeps the brackets and pairings of single elimination,
+// double elimination, round robin and Swiss tournaments. It only tracks who
+// plays whom and who won; playing the matches is up to the caller.
+package tournament
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+type Format string
+
+const (
+	SingleElimination Format = "single_elimination"
+	DoubleElimination Format = "double_elimination"
+	RoundRobin        Format = "round_robin"
+	Swiss             Format = "swiss"
+)
+
+// Results of a decided pairing. A bye is decided without a game; a double
+// forfeit has no winner and counts as a loss for both players.
+const (
+	ResultWin           = "win"
+	ResultForfeit       = "forfeit"
+	ResultBye           = "bye"
+	ResultDoubleForfeit = "double_forfeit"
+)
+
+// Brackets of a double elimination tournament.
+const (
+	BracketWinners = "winners"
+	BracketLosers  = "losers"
+	BracketFinal   = "final"
+)
+
+// MaxPlayers bounds the roster.
+const MaxPlayers = 64
+
+var (
+	ErrUnknownFormat   = errors.New("unknown_format")
+	ErrTooFewPlayers   = errors.New("too_few_players")
+	ErrTooManyPlayers  = errors.New("too_many_players")
+	ErrDuplicatePlayer = errors.New("duplicate_player")
+	ErrBadRounds       = errors.New("bad_rounds")
+	ErrNoSuchPairing   = errors.New("no_such_pairing")
+	ErrPairingNotReady = errors.New("pairing_not_ready")
+	ErrNotInPairing    = errors.New("not_in_pairing")
+	ErrBadResult       = errors.New("bad_result")
+)
+
+// Pairing is one game of the tournament. An empty player on a decided
+// side is a bye.
+type Pairing struct {
+	ID       string `json:"id"`
+	Round    int    `json:"round"`
+	Bracket  string `json:"bracket,omitempty"`
+	PlayerA  string `json:"player_a,omitempty"`
+	PlayerB  string `json:"player_b,omitempty"`
+	WinnerID string `json:"winner_id,omitempty"`
+	Result   string `json:"result,omitempty"`
+
+	// setA and setB mark sides whose player is known.
+	setA, setB bool
+	// winTo and loseTo route the winner and loser on in elimination
+	// brackets; a loser with nowhere to go is eliminated.
+	winTo, loseTo *seat
+	grandFinal    bool
+}
+
+// Ready reports whether both players are known and the game is unplayed.
+func (p *Pairing) Ready() bool {
+	return p.setA && p.setB && p.PlayerA != "" && p.PlayerB != "" && p.Result == ""
+}
+
+// Has reports whether id plays in p.
+func (p *Pairing) Has(id string) bool {
+	return id != "" && (p.PlayerA == id || p.PlayerB == id)
+}
+
+type seat struct {
+	p     *Pairing
+	sideB bool
+}
+
+// Standing is a player's record. Points count wins and byes.
+type Standing struct {
+	PlayerID   string `json:"player_id"`
+	Rank       int    `json:"rank"`
+	Seed       int    `json:"seed"`
+	Played     int    `json:"played"`
+	Wins       int    `json:"wins"`
+	Losses     int    `json:"losses"`
+	Byes       int    `json:"byes,omitempty"`
+	Points     int    `json:"points"`
+	Buchholz   int    `json:"buchholz,omitempty"`
+	Eliminated bool   `json:"eliminated,omitempty"`
+}
+
+// Tournament is not safe for concurrent use.
+type Tournament struct {
+	Format Format
+	// Players are in seed order, best first.
+	Players []string
+	// Rounds and Round are the total and current round of round robin and
+	// Swiss tournaments, 0 for eliminations.
+	Rounds int
+	Round  int
+
+	pairings []*Pairing
+	finished bool
+	winner   string
+	// out records the stage each eliminated player went out at; later
+	// stages rank higher.
+	out map[string]int
+}
+
+// New seeds players in the given order. rounds is the number of Swiss
+// rounds, 0 for the default of log2 of the roster; other formats take 0.
+func New(format Format, players []string, rounds int) (*Tournament, error) {
+	if len(players) < 2 {
+		return nil, ErrTooFewPlayers
+	}
+	if len(players) > MaxPlayers {
+		return nil, ErrTooManyPlayers
+	}
+	seen := map[string]bool{}
+	for _, id := range players {
+		if id == "" || seen[id] {
+			return nil, ErrDuplicatePlayer
+		}
+		seen[id] = true
+	}
+	t := &Tournament{Format: format, Players: append([]string(nil), players...), out: map[string]int{}}
+	switch format {
+	case SingleElimination, DoubleElimination, RoundRobin:
+		if rounds != 0 {
+			return nil, ErrBadRounds
+		}
+	case Swiss:
+		if rounds == 0 {
+			for n := 1; n < len(players); n *= 2 {
+				rounds++
+			}
+		}
+		if rounds < 1 || rounds > len(players)-1 {
+			return nil, ErrBadRounds
+		}
+	default:
+		return nil, ErrUnknownFormat
+	}
+
+	switch format {
+	case SingleElimination:
+		t.buildElimination(false)
+	case DoubleElimination:
+		t.buildElimination(true)
+	case RoundRobin:
+		t.buildRoundRobin()
+		t.Round = 1
+	case Swiss:
+		t.Rounds = rounds
+		t.Round = 1
+		t.pairSwiss()
+	}
+	t.advance()
+	return t, nil
+}
+
+func (t *Tournament) add(id string, round int, bracket string) *Pairing {
+	p := &Pairing{ID: id, Round: round, Bracket: bracket}
+	t.pairings = append(t.pairings, p)
+	return p
+}
+
+// seedOrder lists seeds 1..size in bracket order, so that the top seeds
+// meet as late as possible and byes go to them.
+func seedOrder(size int) []int {
+	order := []int{1}
+	for len(order) < size {
+		next := make([]int, 0, 2*len(order))
+		for _, s := range order {
+			next = append(next, s, 2*len(order)+1-s)
+		}
+		order = next
+	}
+	return order
+}
+
+func (t *Tournament) buildElimination(double bool) {
+	size, rounds := 1, 0
+	for size < len(t.Players) {
+		size *= 2
+		rounds++
+	}
+	bracket, prefix := "", "R"
+	if double {
+		bracket, prefix = BracketWinners, "W"
+	}
+
+	// winners bracket (the whole bracket for single elimination)
+	wb := make([][]*Pairing, rounds+1)
+	for r := 1; r <= rounds; r++ {
+		for i := 0; i < size>>r; i++ {
+			wb[r] = append(wb[r], t.add(fmt.Sprintf("%s%d-%d", prefix, r, i+1), r, bracket))
+		}
+		if r > 1 {
+			for i, p := range wb[r-1] {
+				p.winTo = &seat{wb[r][i/2], i%2 == 1}
+			}
+		}
+	}
+
+	if double {
+		// the losers bracket alternates between rounds where winners-bracket
+		// losers drop in and rounds that halve the field
+		lb := make([][]*Pairing, 2*(rounds-1)+1)
+		for r := 1; r < len(lb); r++ {
+			n := size >> (r/2 + 2)
+			if r%2 == 0 {
+				n = size >> (r/2 + 1)
+			}
+			for i := 0; i < n; i++ {
+				lb[r] = append(lb[r], t.add(fmt.Sprintf("L%d-%d", r, i+1), r, BracketLosers))
+			}
+		}
+		for r := 1; r < len(lb); r++ {
+			switch {
+			case r == 1:
+				for i, p := range wb[1] {
+					p.loseTo = &seat{lb[1][i/2], i%2 == 1}
+				}
+			case r%2 == 0:
+				// reverse every other drop so early opponents do not meet
+				// again straight away
+				drop := wb[r/2+1]
+				for i, p := range drop {
+					j := i
+					if (r/2)%2 == 1 {
+						j = len(drop) - 1 - i
+					}
+					p.loseTo = &seat{lb[r][j], true}
+				}
+				for i, p := range lb[r-1] {
+					p.winTo = &seat{lb[r][i], false}
+				}
+			default:
+				for i, p := range lb[r-1] {
+					p.winTo = &seat{lb[r][i/2], i%2 == 1}
+				}
+			}
+		}
+
+		gf := t.add("GF", 1, BracketFinal)
+		gf.grandFinal = true
+		wb[rounds][0].winTo = &seat{gf, false}
+		if rounds == 1 {
+			wb[1][0].loseTo = &seat{gf, true}
+		} else {
+			lb[len(lb)-1][0].winTo = &seat{gf, true}
+		}
+	}
+
+	order := seedOrder(size)
+	for i, p := range wb[1] {
+		t.fill(&seat{p, false}, t.seeded(order[2*i]))
+		t.fill(&seat{p, true}, t.seeded(order[2*i+1]))
+	}
+}
+
+// seeded returns the player with seed s, or "" (a bye) past the roster.
+func (t *Tournament) seeded(s int) string {
+	if s > len(t.Players) {
+		return ""
+	}
+	return t.Players[s-1]
+}
+
+// buildRoundRobin schedules every pairing with the circle method. With an
+// odd roster one player sits out each round.
+func (t *Tournament) buildRoundRobin() {
+	ids := append([]string(nil), t.Players...)
+	if len(ids)%2 == 1 {
+		ids = append(ids, "")
+	}
+	n := len(ids)
+	t.Rounds = n - 1
+	for r := 1; r <= t.Rounds; r++ {
+		k := 0
+		for i := 0; i < n/2; i++ {
+			a, b := ids[i], ids[n-1-i]
+			if a == "" || b == "" {
+				continue
+			}
+			if r%2 == 0 && i == 0 {
+				a, b = b, a
+			}
+			k++
+			p := t.add(fmt.Sprintf("R%d-%d", r, k), r, "")
+			p.PlayerA, p.PlayerB, p.setA, p.setB = a, b, true, true
+		}
+		ids = append([]string{ids[0], ids[n-1]}, ids[1:n-1]...)
+	}
+}
+
+// pairSwiss pairs the current round: players are ranked by points and
+// paired top down, avoiding rematches where possible. With an odd field the
+// lowest ranked player without a bye gets one.
+func (t *Tournament) pairSwiss() {
+	standings := t.Standings()
+	order := make([]string, len(standings))
+	byes := map[string]int{}
+	for i, s := range standings {
+		order[i] = s.PlayerID
+		byes[s.PlayerID] = s.Byes
+	}
+
+	bye := ""
+	if len(order)%2 == 1 {
+		at := len(order) - 1
+		for i := len(order) - 1; i >= 0; i-- {
+			if byes[order[i]] == 0 {
+				at = i
+				break
+			}
+		}
+		bye = order[at]
+		order = append(order[:at:at], order[at+1:]...)
+	}
+
+	met := t.opponents()
+	budget := 20000
+	pairs, ok := pairUp(order, met, &budget)
+	if !ok {
+		// every pairing repeats a game; fall back to rank order
+		pairs = nil
+		for i := 0; i+1 < len(order); i += 2 {
+			pairs = append(pairs, [2]string{order[i], order[i+1]})
+		}
+	}
+	for i, pr := range pairs {
+		p := t.add(fmt.Sprintf("R%d-%d", t.Round, i+1), t.Round, "")
+		p.PlayerA, p.PlayerB, p.setA, p.setB = pr[0], pr[1], true, true
+	}
+	if bye != "" {
+		p := t.add(fmt.Sprintf("R%d-%d", t.Round, len(pairs)+1), t.Round, "")
+		t.fill(&seat{p, true}, "")
+		t.fill(&seat{p, false}, bye)
+	}
+}
+
+// pairUp pairs list in order without rematches, backtracking until budget
+// runs out.
+func pairUp(list []string, met map[string]map[string]bool, budget *int) ([][2]string, bool) {
+	if len(list) == 0 {
+		return nil, true
+	}
+	if *budget--; *budget < 0 {
+		return nil, false
+	}
+	first := list[0]
+	for j := 1; j < len(list); j++ {
+		if met[first][list[j]] {
+			continue
+		}
+		rest := make([]string, 0, len(list)-2)
+		rest = append(rest, list[1:j]...)
+		rest = append(rest, list[j+1:]...)
+		if pairs, ok := pairUp(rest, met, budget); ok {
+			return append([][2]string{{first, list[j]}}, pairs...), true
+		}
+	}
+	return nil, false
+}
+
+// opponents maps each player to everyone they have been paired with.
+func (t *Tournament) opponents() map[string]map[string]bool {
+	met := map[string]map[string]bool{}
+	for _, id := range t.Players {
+		met[id] = map[string]bool{}
+	}
+	for _, p := range t.pairings {
+		if p.PlayerA != "" && p.PlayerB != "" {
+			met[p.PlayerA][p.PlayerB] = true
+			met[p.PlayerB][p.PlayerA] = true
+		}
+	}
+	return met
+}
+
+// fill seats player on one side of a pairing and decides it at once when
+// that leaves a bye.
+func (t *Tournament) fill(s *seat, player string) {
+	if s == nil {
+		return
+	}
+	p := s.p
+	if s.sideB {
+		p.PlayerB, p.setB = player, true
+	} else {
+		p.PlayerA, p.setA = player, true
+	}
+	if !p.setA || !p.setB || p.Result != "" {
+		return
+	}
+	switch {
+	case p.PlayerA == "" && p.PlayerB == "":
+		t.decide(p, "", ResultBye)
+	case p.PlayerB == "":
+		t.decide(p, p.PlayerA, ResultBye)
+	case p.PlayerA == "":
+		t.decide(p, p.PlayerB, ResultBye)
+	}
+}
+
+func (t *Tournament) elimination() bool {
+	return t.Format == SingleElimination || t.Format == DoubleElimination
+}
+
+func (t *Tournament) decide(p *Pairing, winner, result string) {
+	p.WinnerID, p.Result = winner, result
+	if !t.elimination() {
+		return
+	}
+
+	// the losers-bracket champion beating the winners-bracket champion
+	// forces a deciding rematch
+	if p.grandFinal && winner != "" && winner == p.PlayerB && p.PlayerA != "" {
+		reset := t.add("GF2", 2, BracketFinal)
+		reset.PlayerA, reset.PlayerB, reset.setA, reset.setB = p.PlayerA, p.PlayerB, true, true
+		return
+	}
+
+	stage := p.Round
+	switch p.Bracket {
+	case BracketLosers:
+		stage += 100
+	case BracketFinal:
+		stage += 1000
+	}
+	var losers []string
+	switch {
+	case result == ResultDoubleForfeit:
+		losers = []string{p.PlayerA, p.PlayerB}
+	case winner == p.PlayerA:
+		losers = []string{p.PlayerB}
+	default:
+		losers = []string{p.PlayerA}
+	}
+	for _, id := range losers {
+		if id != "" && (p.loseTo == nil || result == ResultDoubleForfeit) {
+			t.out[id] = stage
+		}
+	}
+	if p.loseTo != nil {
+		if result == ResultDoubleForfeit {
+			t.fill(p.loseTo, "")
+		} else {
+			t.fill(p.loseTo, losers[0])
+		}
+	}
+	if p.winTo != nil {
+		t.fill(p.winTo, winner)
+		return
+	}
+	t.finished = true
+	t.winner = winner
+}
+
+// Record enters the result of a ready pairing. winnerID is empty for a
+// double forfeit.
+func (t *Tournament) Record(pairingID, winnerID, result string) error {
+	p := t.find(pairingID)
+	if p == nil {
+		return ErrNoSuchPairing
+	}
+	if !p.Ready() || !t.current(p) {
+		return ErrPairingNotReady
+	}
+	switch result {
+	case ResultWin, ResultForfeit:
+		if !p.Has(winnerID) {
+			return ErrNotInPairing
+		}
+	case ResultDoubleForfeit:
+		if winnerID != "" {
+			return ErrBadResult
+		}
+	default:
+		return ErrBadResult
+	}
+	t.decide(p, winnerID, result)
+	t.advance()
+	return nil
+}
+
+// advance moves round robin and Swiss tournaments on once every pairing
+// of the round is decided.
+func (t *Tournament) advance() {
+	if t.elimination() || t.finished {
+		return
+	}
+	for {
+		for _, p := range t.pairings {
+			if p.Round == t.Round && p.Result == "" {
+				return
+			}
+		}
+		if t.Round == t.Rounds {
+			t.finished = true
+			if s := t.Standings(); s[1].Rank != s[0].Rank {
+				t.winner = s[0].PlayerID
+			}
+			return
+		}
+		t.Round++
+		if t.Format == Swiss {
+			t.pairSwiss()
+		}
+	}
+}
+
+// current reports whether p belongs to the round being played.
+func (t *Tournament) current(p *Pairing) bool {
+	return t.elimination() || p.Round == t.Round
+}
+
+func (t *Tournament) find(id string) *Pairing {
+	for _, p := range t.pairings {
+		if p.ID == id {
+			return p
+		}
+	}
+	return nil
+}
+
+// Pairing returns a copy of the pairing with id.
+func (t *Tournament) Pairing(id string) (Pairing, bool) {
+	if p := t.find(id); p != nil {
+		return *p, true
+	}
+	return Pairing{}, false
+}
+
+// Pairings returns copies of every pairing made so far.
+func (t *Tournament) Pairings() []Pairing {
+	out := make([]Pairing, len(t.pairings))
+	for i, p := range t.pairings {
+		out[i] = *p
+	}
+	return out
+}
+
+// Ready returns the pairings that can be played now.
+func (t *Tournament) Ready() []Pairing {
+	var out []Pairing
+	if t.finished {
+		return out
+	}
+	for _, p := range t.pairings {
+		if p.Ready() && t.current(p) {
+			out = append(out, *p)
+		}
+	}
+	return out
+}
+
+func (t *Tournament) Finished() bool { return t.finished }
+
+// Winner is the champion of a finished tournament, empty while running or
+// when it ended without one (a tie at the top, or a final nobody played).
+func (t *Tournament) Winner() string { return t.winner }
+
+// Standings ranks the players. Eliminations rank by how far each player
+// got; round robin and Swiss by points, then Buchholz (the sum of the
+// opponents' points). Players level on everything share a rank and are
+// listed by seed.
+func (t *Tournament) Standings() []Standing {
+	seed := map[string]int{}
+	by := map[string]*Standing{}
+	out := make([]Standing, len(t.Players))
+	for i, id := range t.Players {
+		seed[id] = i + 1
+		out[i] = Standing{PlayerID: id, Seed: i + 1}
+		by[id] = &out[i]
+	}
+	opponents := map[string][]string{}
+	for _, p := range t.pairings {
+		switch p.Result {
+		case ResultBye:
+			if s := by[p.WinnerID]; s != nil {
+				s.Byes++
+			}
+		case ResultWin, ResultForfeit:
+			loser := p.PlayerA
+			if loser == p.WinnerID {
+				loser = p.PlayerB
+			}
+			by[p.WinnerID].Wins++
+			by[loser].Losses++
+		case ResultDoubleForfeit:
+			by[p.PlayerA].Losses++
+			by[p.PlayerB].Losses++
+		default:
+			continue
+		}
+		if p.PlayerA != "" && p.PlayerB != "" {
+			by[p.PlayerA].Played++
+			by[p.PlayerB].Played++
+			opponents[p.PlayerA] = append(opponents[p.PlayerA], p.PlayerB)
+			opponents[p.PlayerB] = append(opponents[p.PlayerB], p.PlayerA)
+		}
+	}
+	for i := range out {
+		out[i].Points = out[i].Wins + out[i].Byes
+	}
+	for i := range out {
+		for _, opp := range opponents[out[i].PlayerID] {
+			out[i].Buchholz += by[opp].Points
+		}
+		_, out[i].Eliminated = t.out[out[i].PlayerID]
+	}
+
+	// key orders players best first; seed only breaks ties in the listing
+	key := func(s Standing) [2]int {
+		if t.elimination() {
+			reached := 1 << 20
+			if s.PlayerID != t.winner {
+				reached--
+				if stage, gone := t.out[s.PlayerID]; gone {
+					reached = stage
+				}
+			}
+			return [2]int{reached, 0}
+		}
+		return [2]int{s.Points, s.Buchholz}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		ki, kj := key(out[i]), key(out[j])
+		if ki != kj {
+			return ki[0] > kj[0] || ki[0] == kj[0] && ki[1] > kj[1]
+		}
+		return out[i].Seed < out[j].Seed
+	})
+	for i := range out {
+		out[i].Rank = i + 1
+		if i > 0 && key(out[i]) == key(out[i-1]) {
+			out[i].Rank = out[i-1].Rank
+		}
+	}
+	return out
+}
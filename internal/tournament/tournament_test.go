@@ -0,0 +1,337 @@
+package tournament
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+// roster returns n player IDs, p1 being the top seed.
+func roster(n int) []string {
+	out := make([]string, n)
+	for i := range out {
+		out[i] = fmt.Sprintf("p%d", i+1)
+	}
+	return out
+}
+
+// favourite picks the better seeded player of a pairing.
+func favourite(p Pairing) string {
+	var a, b int
+	fmt.Sscanf(p.PlayerA, "p%d", &a)
+	fmt.Sscanf(p.PlayerB, "p%d", &b)
+	if a < b {
+		return p.PlayerA
+	}
+	return p.PlayerB
+}
+
+// playOut records a win for pick in every ready pairing until the
+// tournament is over.
+func playOut(t *testing.T, tr *Tournament, pick func(Pairing) string) {
+	t.Helper()
+	for i := 0; !tr.Finished(); i++ {
+		ready := tr.Ready()
+		if len(ready) == 0 || i > 1000 {
+			t.Fatalf("stuck with nothing ready: %+v", tr.Pairings())
+		}
+		for _, p := range ready {
+			if err := tr.Record(p.ID, pick(p), ResultWin); err != nil {
+				t.Fatalf("Record(%s): %v", p.ID, err)
+			}
+		}
+	}
+}
+
+func losses(tr *Tournament) map[string]int {
+	out := map[string]int{}
+	for _, s := range tr.Standings() {
+		out[s.PlayerID] = s.Losses
+	}
+	return out
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		players []string
+		rounds  int
+		err     error
+		want    int // rounds of a round robin or Swiss tournament
+	}{
+		{"single", SingleElimination, roster(5), 0, nil, 0},
+		{"double", DoubleElimination, roster(2), 0, nil, 0},
+		{"round robin even", RoundRobin, roster(6), 0, nil, 5},
+		{"round robin odd", RoundRobin, roster(5), 0, nil, 5},
+		{"swiss default rounds", Swiss, roster(5), 0, nil, 3},
+		{"swiss fixed rounds", Swiss, roster(8), 2, nil, 2},
+		{"largest", SingleElimination, roster(MaxPlayers), 0, nil, 0},
+		{"alone", SingleElimination, roster(1), 0, ErrTooFewPlayers, 0},
+		{"too many", RoundRobin, roster(MaxPlayers + 1), 0, ErrTooManyPlayers, 0},
+		{"twice", Swiss, []string{"p1", "p2", "p1"}, 0, ErrDuplicatePlayer, 0},
+		{"nameless", Swiss, []string{"p1", ""}, 0, ErrDuplicatePlayer, 0},
+		{"rounds for a bracket", SingleElimination, roster(4), 2, ErrBadRounds, 0},
+		{"more rounds than opponents", Swiss, roster(4), 4, ErrBadRounds, 0},
+		{"unknown format", "ladder", roster(4), 0, ErrUnknownFormat, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := New(tt.format, tt.players, tt.rounds)
+			if err != tt.err {
+				t.Fatalf("New error = %v, want %v", err, tt.err)
+			}
+			if err == nil && tr.Rounds != tt.want {
+				t.Errorf("Rounds = %d, want %d", tr.Rounds, tt.want)
+			}
+		})
+	}
+}
+
+func TestSeeding(t *testing.T) {
+	tests := []struct {
+		players int
+		// first lists the first-round pairings in bracket order, "" for a bye
+		first [][2]string
+	}{
+		{2, [][2]string{{"p1", "p2"}}},
+		{4, [][2]string{{"p1", "p4"}, {"p2", "p3"}}},
+		{5, [][2]string{{"p1", ""}, {"p4", "p5"}, {"p2", ""}, {"p3", ""}}},
+		{8, [][2]string{{"p1", "p8"}, {"p4", "p5"}, {"p2", "p7"}, {"p3", "p6"}}},
+		{6, [][2]string{{"p1", ""}, {"p4", "p5"}, {"p2", ""}, {"p3", "p6"}}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.players), func(t *testing.T) {
+			tr, _ := New(SingleElimination, roster(tt.players), 0)
+			for i, want := range tt.first {
+				p, ok := tr.Pairing(fmt.Sprintf("R1-%d", i+1))
+				if !ok || p.PlayerA != want[0] || p.PlayerB != want[1] {
+					t.Errorf("R1-%d = %s v %s, want %s v %s", i+1, p.PlayerA, p.PlayerB, want[0], want[1])
+				}
+				// byes are decided straight away for the seeded player
+				if want[1] == "" && (p.Result != ResultBye || p.WinnerID != want[0]) {
+					t.Errorf("R1-%d bye: %+v", i+1, p)
+				}
+			}
+			playOut(t, tr, favourite)
+			if tr.Winner() != "p1" {
+				t.Errorf("top seed winning every game came %v", tr.Standings())
+			}
+		})
+	}
+}
+
+func TestEliminationLosses(t *testing.T) {
+	tests := []struct {
+		format Format
+		// lives is how many losses put a player out
+		lives int
+	}{
+		{SingleElimination, 1},
+		{DoubleElimination, 2},
+	}
+	for _, tt := range tests {
+		for _, n := range []int{2, 3, 5, 7, 8, 12, 16} {
+			for seed := int64(0); seed < 5; seed++ {
+				t.Run(fmt.Sprintf("%s/%d/%d", tt.format, n, seed), func(t *testing.T) {
+					rng := rand.New(rand.NewSource(seed))
+					tr, _ := New(tt.format, roster(n), 0)
+					playOut(t, tr, func(p Pairing) string {
+						if rng.Intn(2) == 0 {
+							return p.PlayerA
+						}
+						return p.PlayerB
+					})
+					lost := losses(tr)
+					for _, id := range roster(n) {
+						want := tt.lives
+						if id == tr.Winner() {
+							if lost[id] >= tt.lives {
+								t.Errorf("champion %s lost %d games", id, lost[id])
+							}
+							continue
+						}
+						if lost[id] != want {
+							t.Errorf("%s went out after %d losses, want %d", id, lost[id], want)
+						}
+					}
+					if s := tr.Standings(); s[0].PlayerID != tr.Winner() || s[0].Rank != 1 || s[1].Rank != 2 {
+						t.Errorf("standings %+v for winner %s", s, tr.Winner())
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestGrandFinalReset(t *testing.T) {
+	tests := []struct {
+		name string
+		// winners of W1-1, GF and, when played, GF2
+		results []string
+		reset   bool
+		winner  string
+	}{
+		{"winners champion holds", []string{"p1", "p1"}, false, "p1"},
+		{"reset won by the winners champion", []string{"p1", "p2", "p1"}, true, "p1"},
+		{"reset won by the losers champion", []string{"p1", "p2", "p2"}, true, "p2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _ := New(DoubleElimination, roster(2), 0)
+			for i, id := range []string{"W1-1", "GF", "GF2"}[:len(tt.results)] {
+				if err := tr.Record(id, tt.results[i], ResultWin); err != nil {
+					t.Fatalf("Record(%s): %v", id, err)
+				}
+			}
+			_, reset := tr.Pairing("GF2")
+			if reset != tt.reset || !tr.Finished() || tr.Winner() != tt.winner {
+				t.Errorf("reset %v, finished %v, winner %q", reset, tr.Finished(), tr.Winner())
+			}
+		})
+	}
+
+	// a larger bracket: the losers-bracket champion forces the reset
+	tr, _ := New(DoubleElimination, roster(4), 0)
+	playOut(t, tr, func(p Pairing) string {
+		if p.ID == "W1-1" || p.ID == "GF" || p.ID == "GF2" {
+			return p.PlayerB
+		}
+		return favourite(p)
+	})
+	if _, reset := tr.Pairing("GF2"); !reset || tr.Winner() != "p1" {
+		t.Errorf("reset played %v, winner %q", reset, tr.Winner())
+	}
+}
+
+func TestRoundRobinCircle(t *testing.T) {
+	for n := 2; n <= 9; n++ {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			tr, _ := New(RoundRobin, roster(n), 0)
+			met := map[[2]string]int{}
+			for !tr.Finished() {
+				ready := tr.Ready()
+				seen := map[string]bool{}
+				for _, p := range ready {
+					if p.Round != tr.Round || seen[p.PlayerA] || seen[p.PlayerB] {
+						t.Fatalf("round %d: %+v clashes", tr.Round, p)
+					}
+					seen[p.PlayerA], seen[p.PlayerB] = true, true
+					a, b := p.PlayerA, p.PlayerB
+					if a > b {
+						a, b = b, a
+					}
+					met[[2]string{a, b}]++
+					if err := tr.Record(p.ID, favourite(p), ResultWin); err != nil {
+						t.Fatal(err)
+					}
+				}
+				// everyone plays each round but one sitter-out of an odd field
+				if len(seen) != n-n%2 {
+					t.Fatalf("round %d seated %d of %d", tr.Round, len(seen), n)
+				}
+			}
+			if len(met) != n*(n-1)/2 {
+				t.Errorf("%d distinct games, want %d", len(met), n*(n-1)/2)
+			}
+			for pair, times := range met {
+				if times != 1 {
+					t.Errorf("%v met %d times", pair, times)
+				}
+			}
+			s := tr.Standings()
+			if tr.Winner() != "p1" || s[0].Wins != n-1 || s[n-1].Wins != 0 {
+				t.Errorf("standings %+v", s)
+			}
+		})
+	}
+}
+
+func TestSwissPairing(t *testing.T) {
+	tests := []struct {
+		players, rounds int
+	}{
+		{4, 0}, {5, 0}, {7, 3}, {8, 0}, {9, 4}, {16, 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d/%d", tt.players, tt.rounds), func(t *testing.T) {
+			tr, _ := New(Swiss, roster(tt.players), tt.rounds)
+			// round one pairs by seed from the top
+			if p, _ := tr.Pairing("R1-1"); p.PlayerA != "p1" || p.PlayerB != "p2" {
+				t.Errorf("R1-1 = %s v %s", p.PlayerA, p.PlayerB)
+			}
+			met := map[string]map[string]bool{}
+			for _, id := range roster(tt.players) {
+				met[id] = map[string]bool{}
+			}
+			for !tr.Finished() {
+				round := tr.Round
+				// rematches are avoided while a fresh pairing exists
+				for _, p := range tr.Ready() {
+					if met[p.PlayerA][p.PlayerB] {
+						t.Errorf("round %d repeats %s v %s", round, p.PlayerA, p.PlayerB)
+					}
+					met[p.PlayerA][p.PlayerB], met[p.PlayerB][p.PlayerA] = true, true
+				}
+				for _, p := range tr.Ready() {
+					if err := tr.Record(p.ID, favourite(p), ResultWin); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+			s := tr.Standings()
+			for _, st := range s {
+				if st.Byes > 1 {
+					t.Errorf("%s had %d byes", st.PlayerID, st.Byes)
+				}
+				if st.Played+st.Byes != tr.Rounds {
+					t.Errorf("%s took part in %d of %d rounds", st.PlayerID, st.Played+st.Byes, tr.Rounds)
+				}
+			}
+			if tr.Winner() != "p1" || s[0].Points != tr.Rounds {
+				t.Errorf("standings %+v", s)
+			}
+			// points and then Buchholz order the table
+			for i := 1; i < len(s); i++ {
+				a, b := s[i-1], s[i]
+				if a.Points < b.Points || a.Points == b.Points && a.Buchholz < b.Buchholz {
+					t.Errorf("%s ranked above %s", a.PlayerID, b.PlayerID)
+				}
+			}
+		})
+	}
+}
+
+func TestRecordErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		pairingID string
+		winnerID  string
+		result    string
+		err       error
+	}{
+		{"win", "R1-1", "p4", ResultWin, nil},
+		{"forfeit", "R1-1", "p1", ResultForfeit, nil},
+		{"double forfeit", "R1-1", "", ResultDoubleForfeit, nil},
+		{"unknown pairing", "R9-1", "p1", ResultWin, ErrNoSuchPairing},
+		{"later round", "R2-1", "p1", ResultWin, ErrPairingNotReady},
+		{"outsider", "R1-1", "p2", ResultWin, ErrNotInPairing},
+		{"no winner", "R1-1", "", ResultWin, ErrNotInPairing},
+		{"bye", "R1-1", "p1", ResultBye, ErrBadResult},
+		{"double forfeit with a winner", "R1-1", "p1", ResultDoubleForfeit, ErrBadResult},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, _ := New(SingleElimination, roster(4), 0)
+			if err := tr.Record(tt.pairingID, tt.winnerID, tt.result); err != tt.err {
+				t.Fatalf("Record error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if err := tr.Record(tt.pairingID, tt.winnerID, tt.result); err != ErrPairingNotReady {
+				t.Errorf("recording twice: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package ws
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"battleship-go/internal/account"
+	"battleship-go/internal/names"
+	"battleship-go/internal/store"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// sessionCookie carries the session token for browsers, which cannot set
+	// headers on a WebSocket upgrade.
+	sessionCookie = "bs_session"
+	// sessionTokenTTL bounds how long a login lasts.
+	sessionTokenTTL = 30 * 24 * time.Hour
+)
+
+// AllowGuests lets players connect without an account. Guests get a fresh
+// ID per session and play unrated.
+var AllowGuests = true
+
+var (
+	// accountsMu serialises registrations so usernames stay unique.
+	accountsMu sync.Mutex
+
+	// dummyHash is compared against on unknown usernames so a failed login
+	// takes as long either way.
+	dummyHash, _ = account.HashPassword("not a real password")
+
+	errUsernameTaken = errors.New("username_taken")
+)
+
+type credentials struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type sessionInfo struct {
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Token    string `json:"token"`
+}
+
+// issueSessionToken returns a signed login token for accountID. The
+// "session|" prefix keeps it from being accepted as a resume token.
+func issueSessionToken(accountID string) string {
+	payload := "session|" + accountID + "|" + strconv.FormatInt(time.Now().Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + signToken(payload)
+}
+
+// verifySessionToken checks token and returns the account ID it was issued
+// for.
+func verifySessionToken(token string) (string, error) {
+	enc, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errors.New("bad_session_token")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return "", errors.New("bad_session_token")
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(sig), []byte(signToken(payload))) {
+		return "", errors.New("bad_session_token")
+	}
+	parts := strings.Split(payload, "|")
+	if len(parts) != 3 || parts[0] != "session" || parts[1] == "" {
+		return "", errors.New("bad_session_token")
+	}
+	issued, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return "", errors.New("bad_session_token")
+	}
+	if time.Since(time.Unix(issued, 0)) > sessionTokenTTL {
+		return "", errors.New("session_expired")
+	}
+	gone, err := matchStore.TokenRevoked(sig)
+	if err != nil {
+		return "", err
+	}
+	if gone {
+		return "", errors.New("session_revoked")
+	}
+	return parts[1], nil
+}
+
+// revokeSessionToken logs token out for good; revocations are stored, so
+// they outlive a restart under the same SESSION_SECRET.
+func revokeSessionToken(token string) {
+	enc, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return
+	}
+	until := time.Now().Add(sessionTokenTTL)
+	if raw, err := base64.RawURLEncoding.DecodeString(enc); err == nil {
+		if parts := strings.Split(string(raw), "|"); len(parts) == 3 {
+			if issued, err := strconv.ParseInt(parts[2], 10, 64); err == nil {
+				until = time.Unix(issued, 0).Add(sessionTokenTTL)
+			}
+		}
+	}
+	if err := matchStore.RevokeToken(store.Revocation{Signature: sig, Until: until}); err != nil {
+		log.Println("revokeSessionToken:", err)
+	}
+}
+
+// requestToken returns the session token from a bearer Authorization header
+// or the session cookie.
+func requestToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); h != "" {
+		if t, ok := strings.CutPrefix(h, "Bearer "); ok {
+			return strings.TrimSpace(t)
+		}
+	}
+	if c, err := r.Cookie(sessionCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// authenticate returns the account the request is logged in as.
+func authenticate(r *http.Request) (store.Account, bool) {
+	token := requestToken(r)
+	if token == "" {
+		return store.Account{}, false
+	}
+	id, err := verifySessionToken(token)
+	if err != nil {
+		log.Println("authenticate:", err)
+		return store.Account{}, false
+	}
+	acct, ok, err := matchStore.LoadAccount(id)
+	if err != nil {
+		log.Println("authenticate:", id, err)
+	}
+	return acct, ok
+}
+
+// accountName returns the username of the account with id, empty for
+// guests and bots.
+func accountName(id string) string {
+	acct, ok, _ := matchStore.LoadAccount(id)
+	if !ok {
+		return ""
+	}
+	return acct.Username
+}
+
+func register(c credentials) (store.Account, error) {
+	if err := account.ValidateUsername(c.Username); err != nil {
+		return store.Account{}, err
+	}
+	// usernames double as display names, so they follow the name policy too
+	if _, err := names.Normalize(c.Username); err != nil {
+		return store.Account{}, account.ErrBadUsername
+	}
+	if err := account.ValidatePassword(c.Password); err != nil {
+		return store.Account{}, err
+	}
+	hash, err := account.HashPassword(c.Password)
+	if err != nil {
+		return store.Account{}, err
+	}
+
+	accountsMu.Lock()
+	defer accountsMu.Unlock()
+	if _, taken, _ := matchStore.FindAccount(c.Username); taken {
+		return store.Account{}, errUsernameTaken
+	}
+	acct := store.Account{
+		ID:        uuid.NewString(),
+		Username:  c.Username,
+		Hash:      hash,
+		CreatedAt: time.Now(),
+	}
+	if err := matchStore.SaveAccount(acct); err != nil {
+		return store.Account{}, err
+	}
+	log.Println("register:", acct.Username, acct.ID)
+	return acct, nil
+}
+
+func login(c credentials) (store.Account, error) {
+	acct, ok, err := matchStore.FindAccount(c.Username)
+	if err != nil {
+		return store.Account{}, err
+	}
+	if !ok {
+		account.CheckPassword(dummyHash, c.Password)
+		return store.Account{}, account.ErrBadCredentials
+	}
+	if !account.CheckPassword(acct.Hash, c.Password) {
+		return store.Account{}, account.ErrBadCredentials
+	}
+	return acct, nil
+}
+
+// RegisterHandler creates an account and logs it in.
+func RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var c credentials
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&c); err != nil {
+		http.Error(w, "bad_request", http.StatusBadRequest)
+		return
+	}
+	acct, err := register(c)
+	switch {
+	case err == nil:
+	case errors.Is(err, account.ErrBadUsername), errors.Is(err, account.ErrWeakPassword):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, errUsernameTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		log.Println("RegisterHandler:", err)
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	writeSession(w, r, acct, http.StatusCreated)
+}
+
+// LoginHandler checks a username and password and starts a session.
+func LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var c credentials
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&c); err != nil {
+		http.Error(w, "bad_request", http.StatusBadRequest)
+		return
+	}
+	acct, err := login(c)
+	if errors.Is(err, account.ErrBadCredentials) {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Println("LoginHandler:", err)
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	writeSession(w, r, acct, http.StatusOK)
+}
+
+// LogoutHandler revokes the presented session token and clears the cookie.
+func LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if token := requestToken(r); token != "" {
+		revokeSessionToken(token)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SessionHandler reports the account the request is logged in as.
+func SessionHandler(w http.ResponseWriter, r *http.Request) {
+	acct, ok := authenticate(r)
+	if !ok {
+		http.Error(w, "not_logged_in", http.StatusUnauthorized)
+		return
+	}
+	b, err := json.Marshal(sessionInfo{ID: acct.ID, Username: acct.Username})
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// writeSession sets the session cookie, Secure when r came over TLS, and
+// returns the token in the body for clients that authenticate with a
+// bearer header instead.
+func writeSession(w http.ResponseWriter, r *http.Request, acct store.Account, status int) {
+	token := issueSessionToken(acct.ID)
+	b, err := json.Marshal(sessionInfo{ID: acct.ID, Username: acct.Username, Token: token})
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookie,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(sessionTokenTTL / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(b)
+}
@@ -0,0 +1,74 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func wsURL(srv *httptest.Server, query string) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/ws" + query
+}
+
+func TestResumeTokenNeedsSessionForAccounts(t *testing.T) {
+	acct, err := register(credentials{Username: "resumer", Password: "correct horse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(old bool) { AllowGuests = old }(AllowGuests)
+	AllowGuests = false
+	srv := httptest.NewServer(http.HandlerFunc(HandleWS))
+	defer srv.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(srv, "?resume="+issueResumeToken(acct.ID)), nil)
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("resume without session: err %v, response %v", err, resp)
+	}
+
+	token := issueSessionToken(acct.ID)
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv, ""), http.Header{"Authorization": {"Bearer " + token}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	revokeSessionToken(token)
+	_, resp, err = websocket.DefaultDialer.Dial(wsURL(srv, ""), http.Header{"Authorization": {"Bearer " + token}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("revoked session: err %v, response %v", err, resp)
+	}
+}
+
+func TestUpgradeRefusesOtherOrigins(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleWS))
+	defer srv.Close()
+
+	_, resp, err := websocket.DefaultDialer.Dial(wsURL(srv, ""), http.Header{"Origin": {"https://evil.example"}})
+	if err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("cross-site upgrade: err %v, response %v", err, resp)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL(srv, ""), http.Header{"Origin": {srv.URL}})
+	if err != nil {
+		t.Fatalf("same-origin upgrade: %v", err)
+	}
+	conn.Close()
+}
+
+func TestSessionCookieSecureOverTLS(t *testing.T) {
+	// httptest sets r.TLS for https targets
+	for name, target := range map[string]string{"cookieplain": "/api/register", "cookietls": "https://example.com/api/register"} {
+		body := `{"username":"` + name + `","password":"correct horse"}`
+		w := httptest.NewRecorder()
+		RegisterHandler(w, httptest.NewRequest(http.MethodPost, target, strings.NewReader(body)))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("%s: register: %d %s", name, w.Code, w.Body)
+		}
+		cookies := w.Result().Cookies()
+		if want := name == "cookietls"; len(cookies) != 1 || cookies[0].Secure != want {
+			t.Fatalf("%s: cookies %v, want Secure %v", name, cookies, want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"math/rand"
+	"time"
+
+	"battleship-go/internal/bot"
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+
+	"github.com/google/uuid"
+)
+
+// botThinkTime delays each bot shot so humans can follow the game.
+var botThinkTime = 400 * time.Millisecond
+
+// maxMisfires is how many refused shots in a row a bot takes before it
+// stops firing in a match and leaves the turn to the clock.
+const maxMisfires = 3
+
+// botMatch is a bot's private view of one match it is playing.
+type botMatch struct {
+	side   Side
+	view   *bot.View
+	myTurn bool
+	// salvo is the size of our next volley in salvo mode, 0 otherwise.
+	salvo int
+	// misfires counts shots refused since the last one that landed.
+	misfires int
+}
+
+// botDriver plays as a hub Player. It reads the same messages a socket
+// would receive from p.send and answers through dispatch.
+type botDriver struct {
+	p        *Player
+	strategy bot.Strategy
+	rng      *rand.Rand
+	matches  map[string]*botMatch
+}
+
+// SpawnBot registers a computer opponent of the given difficulty in the
+// lobby. Bots accept every challenge and can play several matches at once.
+func SpawnBot(difficulty string) (*Player, error) {
+	d, err := newBotDriver(difficulty)
+	if err != nil {
+		return nil, err
+	}
+	RegisterPlayer(d.p)
+	go d.run()
+	log.Println("SpawnBot: registered", d.p.Name, d.p.ID)
+	return d.p, nil
+}
+
+func newBotDriver(difficulty string) (*botDriver, error) {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	strategy, err := bot.New(difficulty, rng)
+	if err != nil {
+		return nil, err
+	}
+	p := newPlayer(uuid.NewString())
+	p.Name = "Bot (" + difficulty + ")"
+	p.bot = difficulty
+	return &botDriver{p: p, strategy: strategy, rng: rng, matches: map[string]*botMatch{}}, nil
+}
+
+// SpawnBots registers one bot per difficulty.
+func SpawnBots() {
+	for _, d := range bot.Difficulties {
+		if _, err := SpawnBot(d); err != nil {
+			log.Println("SpawnBots:", err)
+		}
+	}
+}
+
+func (d *botDriver) run() {
+	for raw := range d.p.send {
+		d.handle(raw)
+		// settle everything already queued (a shot's ship_sunk follows its
+		// shot_result) before deciding where to fire next
+		for len(d.p.send) > 0 {
+			d.handle(<-d.p.send)
+		}
+		d.firePending()
+	}
+}
+
+func (d *botDriver) handle(raw []byte) {
+	var envelope struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(raw, &envelope); err != nil {
+		return
+	}
+	switch envelope.Type {
+	case "challenge_request":
+		var m protocol.ChallengeRequest
+		json.Unmarshal(raw, &m)
+		dispatch(d.p, &protocol.ChallengeResponse{ChallengeID: m.ChallengeID, TargetID: m.FromID, Accept: true})
+	case "rematch_offer":
+		var m protocol.RematchOffer
+		json.Unmarshal(raw, &m)
+		dispatch(d.p, &protocol.RematchResponse{MatchID: m.MatchID, Accept: true})
+	case "match_start":
+		var m protocol.MatchStart
+		json.Unmarshal(raw, &m)
+		d.matches[m.MatchID] = &botMatch{side: Side(m.YourSide), view: bot.NewView(m.Rules)}
+		seed := d.rng.Int63()
+		dispatch(d.p, &protocol.AutoPlace{MatchID: m.MatchID, Seed: &seed, Place: true})
+	case "all_ships_ready":
+		var m protocol.AllShipsReady
+		json.Unmarshal(raw, &m)
+		if bm, ok := d.matches[m.MatchID]; ok {
+			bm.salvo = m.SalvoSize
+		}
+		d.setTurn(m.MatchID, m.StartTurn)
+	case "salvo_result":
+		var m protocol.SalvoResult
+		json.Unmarshal(raw, &m)
+		bm, ok := d.matches[m.MatchID]
+		if !ok {
+			return
+		}
+		if m.ShooterID == d.p.ID {
+			for _, s := range m.Shots {
+				bm.view.Record(s.X, s.Y, s.Hit)
+			}
+			bm.misfires = 0
+		}
+		if m.GameOver {
+			delete(d.matches, m.MatchID)
+			return
+		}
+		bm.salvo = m.NextSalvo
+		d.setTurn(m.MatchID, m.NextTurn)
+	case "shot_result":
+		var m protocol.ShotResult
+		json.Unmarshal(raw, &m)
+		bm, ok := d.matches[m.MatchID]
+		if !ok {
+			return
+		}
+		if m.ShooterID == d.p.ID {
+			bm.view.Record(m.X, m.Y, m.Hit)
+			bm.misfires = 0
+		}
+		if m.GameOver {
+			delete(d.matches, m.MatchID)
+			return
+		}
+		d.setTurn(m.MatchID, m.NextTurn)
+	case "turn_clock":
+		var m protocol.TurnClock
+		json.Unmarshal(raw, &m)
+		if bm, ok := d.matches[m.MatchID]; ok {
+			bm.myTurn = m.PlayerID == d.p.ID
+		}
+	case "turn_timeout":
+		var m protocol.TurnTimeout
+		json.Unmarshal(raw, &m)
+		// a passed turn goes to the other player; a random shot or forfeit
+		// is followed by its own result
+		if bm, ok := d.matches[m.MatchID]; ok {
+			bm.myTurn = m.Action == string(TimeoutPass) && m.PlayerID != d.p.ID
+		}
+	case "shot_error":
+		var m protocol.ShotError
+		json.Unmarshal(raw, &m)
+		d.reaim(m.MatchID)
+	case "match_over":
+		var m protocol.MatchOver
+		json.Unmarshal(raw, &m)
+		delete(d.matches, m.MatchID)
+	case "ship_sunk":
+		var m protocol.ShipSunk
+		json.Unmarshal(raw, &m)
+		if bm, ok := d.matches[m.MatchID]; ok && m.OwnerID != d.p.ID {
+			bm.view.RecordSunk(m.Cells)
+			bm.view.RecordWater(m.Water)
+		}
+	}
+}
+
+// reaim catches up with the server after it refused one of our shots: our
+// view takes the shots the server knows of, and we fire again if it is
+// still our turn.
+func (d *botDriver) reaim(matchID string) {
+	bm, ok := d.matches[matchID]
+	g, live := GetGameState(matchID)
+	if !ok || !live {
+		return
+	}
+	bm.misfires++
+	r := buildResync(g, d.p.ID)
+	for y, row := range r.OpponentBoard {
+		for x, c := range row {
+			if c == game.Hit || c == game.Miss {
+				bm.view.Shots[y][x] = c
+			}
+		}
+	}
+	bm.myTurn = Side(r.Turn) == bm.side && bm.misfires < maxMisfires
+	if bm.myTurn && r.SalvoSize > 0 {
+		bm.salvo = r.SalvoSize
+	}
+}
+
+func (d *botDriver) setTurn(matchID, turn string) {
+	if bm, ok := d.matches[matchID]; ok {
+		bm.myTurn = Side(turn) == bm.side
+	}
+}
+
+func (d *botDriver) firePending() {
+	for matchID, bm := range d.matches {
+		if !bm.myTurn {
+			continue
+		}
+		bm.myTurn = false
+		time.Sleep(botThinkTime)
+		if bm.salvo > 0 {
+			shots := bot.Volley(d.strategy, bm.view, bm.salvo)
+			dispatch(d.p, &protocol.SalvoFired{MatchID: matchID, Shots: shots})
+			continue
+		}
+		shot := d.strategy.NextShot(bm.view)
+		dispatch(d.p, &protocol.ShotFired{MatchID: matchID, X: shot.X, Y: shot.Y})
+	}
+}
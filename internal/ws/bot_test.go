@@ -0,0 +1,126 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+// testBot registers a bot without starting its loop; the test pumps its
+// messages by hand.
+func testBot(t *testing.T, difficulty string) *botDriver {
+	t.Helper()
+	d, err := newBotDriver(difficulty)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RegisterPlayer(d.p)
+	t.Cleanup(func() { UnregisterPlayer(d.p.ID) })
+	return d
+}
+
+// pump handles everything queued for d and lets it fire, as run does.
+func (d *botDriver) pump() {
+	for len(d.p.send) > 0 {
+		d.handle(<-d.p.send)
+	}
+	d.firePending()
+}
+
+// botMatchWith starts a match between a human and a bot with both fleets
+// placed. The clock is long enough never to run out on its own.
+func botMatchWith(t *testing.T, first string) (*Player, *botDriver, *GameState) {
+	t.Helper()
+	defer func(c ClockConfig) { DefaultClock = c }(DefaultClock)
+	DefaultClock = ClockConfig{TurnLimit: time.Hour, Policy: TimeoutPass}
+	a, d := testPlayer(t), testBot(t, "medium")
+	firstID := a.ID
+	if first == "bot" {
+		firstID = d.p.ID
+	}
+	g := startMatch(a, d.p, matchOptions{FirstID: firstID, Rules: game.DefaultRules()})
+	t.Cleanup(func() { forfeitMatch(t, g, a) })
+	for len(d.p.send) > 0 {
+		d.handle(<-d.p.send)
+	}
+	if err := SetPlayerShips(g.MatchID, a.ID, testFleet()); err != nil {
+		t.Fatal(err)
+	}
+	return a, d, g
+}
+
+// botShots returns the shots a saw the bot fire.
+func botShots(a *Player, d *botDriver) []protocol.ShotResult {
+	var out []protocol.ShotResult
+	for _, raw := range sent(a, "shot_result") {
+		var m protocol.ShotResult
+		json.Unmarshal(raw, &m)
+		if m.ShooterID == d.p.ID {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+func TestBotFollowsTheClock(t *testing.T) {
+	defer func(think time.Duration) { botThinkTime = think }(botThinkTime)
+	botThinkTime = 0
+
+	tests := []struct {
+		name  string
+		first string
+		shots int
+	}{
+		{"takes a passed turn", "human", 1},
+		{"stops when its own turn passes", "bot", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, d, g := botMatchWith(t, tt.first)
+			g.mu.Lock()
+			seq := g.clock.seq
+			g.mu.Unlock()
+			g.turnTimedOut(seq)
+			d.pump()
+			if n := len(botShots(a, d)); n != tt.shots {
+				t.Errorf("bot fired %d shots, want %d", n, tt.shots)
+			}
+			if errs := sent(d.p, "shot_error"); len(errs) != 0 {
+				t.Errorf("bot fired out of turn: %s", errs[0])
+			}
+		})
+	}
+}
+
+func TestBotReaimsAfterShotError(t *testing.T) {
+	defer func(think time.Duration) { botThinkTime = think }(botThinkTime)
+	botThinkTime = 0
+	a, d, g := botMatchWith(t, "bot")
+	d.pump()
+	shots := botShots(a, d)
+	if len(shots) != 1 {
+		t.Fatalf("bot fired %d shots, want 1", len(shots))
+	}
+	// the bot forgets its shot and fires at the same cell again
+	shot := shots[0]
+	bm := d.matches[g.MatchID]
+	bm.view.Shots[shot.Y][shot.X] = game.Empty
+	refused, _ := protocol.Encode(protocol.ShotError{MatchID: g.MatchID, Error: game.ErrAlreadyShot.Error()})
+
+	for i := 1; i <= maxMisfires; i++ {
+		bm.myTurn = false
+		d.handle(refused)
+		if bm.view.Shots[shot.Y][shot.X] == game.Empty {
+			t.Fatal("view still misses the refused cell")
+		}
+		g.mu.Lock()
+		ours := g.playerOn(g.Game.Turn()) == d.p.ID
+		g.mu.Unlock()
+		if want := ours && i < maxMisfires; bm.myTurn != want {
+			t.Errorf("after %d refused shots: my turn = %v, want %v", i, bm.myTurn, want)
+		}
+	}
+}
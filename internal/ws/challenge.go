@@ -0,0 +1,274 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+
+	"github.com/google/uuid"
+)
+
+// ChallengeTTL is how long a challenge stays open without an answer.
+var ChallengeTTL = 60 * time.Second
+
+// Reasons a pending challenge is withdrawn, sent in challenge_cancelled.
+const (
+	cancelByChallenger = "cancelled"
+	cancelExpired      = "expired"
+	cancelBusy         = "busy"
+	cancelDisconnected = "disconnected"
+)
+
+// challenge is an invite waiting for the target's answer.
+type challenge struct {
+	ID     string
+	FromID string
+	ToID   string
+	Rules  game.RuleSet
+	// BestOf is above 1 for a best-of-N series.
+	BestOf int
+	timer  *time.Timer
+}
+
+var (
+	challengesMu sync.Mutex
+	challenges   = map[string]*challenge{}
+)
+
+// Player statuses published in the lobby list.
+const (
+	statusIdle    = "idle"
+	statusInQueue = "in_queue"
+	statusInMatch = "in_match"
+)
+
+// playerStatus reports what p is doing. Bots play any number of matches
+// at once, so they are always idle.
+func playerStatus(p *Player) string {
+	switch {
+	case p.bot != "":
+		return statusIdle
+	case inLiveMatch(p.ID):
+		return statusInMatch
+	case inQueue(p.ID):
+		return statusInQueue
+	}
+	return statusIdle
+}
+
+// takeChallenge removes and returns the pending challenge id.
+func takeChallenge(id string) (*challenge, bool) {
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	c, ok := challenges[id]
+	if ok {
+		delete(challenges, id)
+		c.timer.Stop()
+	}
+	return c, ok
+}
+
+// withdrawChallenge removes challenge id, if still pending, and tells both
+// sides why.
+func withdrawChallenge(id, reason string) {
+	c, ok := takeChallenge(id)
+	if !ok {
+		return
+	}
+	cancelled(c, reason)
+}
+
+// cancelled tells both sides why the taken challenge c will not be played.
+func cancelled(c *challenge, reason string) {
+	msg := protocol.ChallengeCancelled{ChallengeID: c.ID, FromID: c.FromID, TargetID: c.ToID, Reason: reason}
+	for _, pid := range []string{c.FromID, c.ToID} {
+		if pl, ok := GetPlayer(pid); ok {
+			pl.sendMsg(msg)
+		}
+	}
+	log.Println("challenge:", c.ID, "cancelled:", reason)
+}
+
+// withdrawChallengesOf withdraws every pending challenge sent or received
+// by playerID.
+func withdrawChallengesOf(playerID, reason string) {
+	challengesMu.Lock()
+	var ids []string
+	for id, c := range challenges {
+		if c.FromID == playerID || c.ToID == playerID {
+			ids = append(ids, id)
+		}
+	}
+	challengesMu.Unlock()
+	for _, id := range ids {
+		withdrawChallenge(id, reason)
+	}
+}
+
+func handleChallenge(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.Challenge)
+	if m.TargetID == "" {
+		return
+	}
+	target, ok := GetPlayer(m.TargetID)
+	if !ok {
+		p.sendMsg(protocol.Error{Error: "target_not_found", For: "challenge"})
+		return
+	}
+	rules, err := challengeRuleSet(m)
+	if err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "challenge"})
+		return
+	}
+	if err := checkChallenge(p, target, rules); err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "challenge"})
+		return
+	}
+	if err := checkBestOf(m.BestOf); err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "challenge"})
+		return
+	}
+
+	c := &challenge{ID: uuid.NewString(), FromID: p.ID, ToID: target.ID, Rules: rules, BestOf: m.BestOf}
+	challengesMu.Lock()
+	for _, other := range challenges {
+		if other.FromID == p.ID && other.ToID == target.ID {
+			challengesMu.Unlock()
+			p.sendMsg(protocol.Error{Error: "challenge_pending", For: "challenge"})
+			return
+		}
+	}
+	c.timer = time.AfterFunc(ChallengeTTL, func() { withdrawChallenge(c.ID, cancelExpired) })
+	challenges[c.ID] = c
+	challengesMu.Unlock()
+
+	expires := ChallengeTTL.Milliseconds()
+	p.sendMsg(protocol.ChallengeSent{ChallengeID: c.ID, TargetID: target.ID, ExpiresInMs: expires})
+	target.sendMsg(protocol.ChallengeRequest{
+		ChallengeID: c.ID,
+		FromID:      p.ID,
+		FromName:    p.Name,
+		FromRating:  playerRating(p),
+		Rules:       rules,
+		BestOf:      c.BestOf,
+		ExpiresInMs: expires,
+	})
+}
+
+// checkChallenge rejects challenges that could not start a match.
+func checkChallenge(from, to *Player, rules game.RuleSet) error {
+	if from.ID == to.ID {
+		return errors.New("cannot_challenge_self")
+	}
+	if err := rules.Validate(); err != nil {
+		return err
+	}
+	if playerStatus(from) == statusInMatch {
+		return errors.New("already_in_match")
+	}
+	if playerStatus(to) == statusInMatch {
+		return errors.New("target_busy")
+	}
+	return nil
+}
+
+// challengeRuleSet resolves the rules a challenge asks for, from Rules or
+// the older Mode and SalvoShots shorthand but not both.
+func challengeRuleSet(m *protocol.Challenge) (game.RuleSet, error) {
+	legacy := m.Mode != "" || m.SalvoShots != 0
+	switch {
+	case m.Rules != nil && legacy:
+		return game.RuleSet{}, errors.New("rules_and_mode")
+	case m.Rules != nil:
+		return *m.Rules, nil
+	case m.SalvoShots != 0 && m.Mode == "":
+		return game.RuleSet{}, errors.New("salvo_shots_without_salvo")
+	}
+	rules := game.DefaultRules()
+	if m.Mode != "" {
+		rules.Mode = m.Mode
+		rules.SalvoShots = m.SalvoShots
+	}
+	return rules, nil
+}
+
+// pendingFrom finds the open challenge fromID sent to toID, for clients
+// that answer by challenger ID instead of challenge ID.
+func pendingFrom(fromID, toID string) string {
+	challengesMu.Lock()
+	defer challengesMu.Unlock()
+	for id, c := range challenges {
+		if c.FromID == fromID && c.ToID == toID {
+			return id
+		}
+	}
+	return ""
+}
+
+func handleChallengeResponse(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.ChallengeResponse)
+	id := m.ChallengeID
+	if id == "" {
+		id = pendingFrom(m.TargetID, p.ID)
+	}
+
+	challengesMu.Lock()
+	c, ok := challenges[id]
+	challengesMu.Unlock()
+	if !ok || c.ToID != p.ID {
+		p.sendMsg(protocol.Error{Error: "no_such_challenge", For: "challenge_response"})
+		return
+	}
+	if _, ok := takeChallenge(id); !ok {
+		// expired or cancelled in the meantime
+		p.sendMsg(protocol.Error{Error: "no_such_challenge", For: "challenge_response"})
+		return
+	}
+
+	challenger, ok := GetPlayer(c.FromID)
+	if !ok || (challenger.bot == "" && !challenger.Connected()) {
+		p.sendMsg(protocol.Error{Error: "challenger_not_connected", For: "challenge_response"})
+		cancelled(c, cancelDisconnected)
+		return
+	}
+	if m.Accept {
+		// either side may have started another match since the invite;
+		// the check holds until the match below has seated them
+		seatMu.Lock()
+		defer seatMu.Unlock()
+		if err := checkChallenge(challenger, p, c.Rules); err != nil {
+			p.sendMsg(protocol.Error{Error: err.Error(), For: "challenge_response"})
+			cancelled(c, cancelBusy)
+			return
+		}
+	}
+	challenger.sendMsg(protocol.ChallengeResponseForward{
+		ChallengeID: c.ID,
+		FromID:      p.ID,
+		FromName:    p.Name,
+		Accept:      m.Accept,
+		TargetID:    c.FromID,
+	})
+	switch {
+	case m.Accept && c.BestOf > 1:
+		startSeries(challenger, p, c.BestOf, c.Rules, "", nil)
+	case m.Accept:
+		startMatch(challenger, p, matchOptions{Rules: c.Rules})
+	}
+}
+
+func handleChallengeCancel(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.ChallengeCancel)
+	challengesMu.Lock()
+	c, ok := challenges[m.ChallengeID]
+	challengesMu.Unlock()
+	if !ok || c.FromID != p.ID {
+		p.sendMsg(protocol.Error{Error: "no_such_challenge", For: "challenge_cancel"})
+		return
+	}
+	withdrawChallenge(c.ID, cancelByChallenger)
+}
@@ -0,0 +1,146 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+
+	"github.com/google/uuid"
+)
+
+func TestChallengeRuleSet(t *testing.T) {
+	salvo := game.DefaultRules()
+	salvo.Mode = game.ModeSalvo
+	fixed := salvo
+	fixed.SalvoShots = 3
+
+	tests := []struct {
+		name string
+		m    protocol.Challenge
+		want game.RuleSet
+		err  string
+	}{
+		{"default", protocol.Challenge{}, game.DefaultRules(), ""},
+		{"rules", protocol.Challenge{Rules: &salvo}, salvo, ""},
+		{"shorthand", protocol.Challenge{Mode: game.ModeSalvo, SalvoShots: 3}, fixed, ""},
+		{"both", protocol.Challenge{Rules: &salvo, Mode: game.ModeClassic}, game.RuleSet{}, "rules_and_mode"},
+		{"shots with rules", protocol.Challenge{Rules: &salvo, SalvoShots: 3}, game.RuleSet{}, "rules_and_mode"},
+		{"shots alone", protocol.Challenge{SalvoShots: 3}, game.RuleSet{}, "salvo_shots_without_salvo"},
+	}
+	for _, tt := range tests {
+		got, err := challengeRuleSet(&tt.m)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: err = %v, want %s", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("%s: got %+v, %v", tt.name, got, err)
+		}
+	}
+}
+
+// forfeitMatch ends g with loser forfeiting, as the clock would.
+func forfeitMatch(t *testing.T, g *GameState, loser *Player) {
+	t.Helper()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	side, _ := g.sideOf(loser.ID)
+	if err := g.Game.Forfeit(side); err != nil {
+		t.Fatal(err)
+	}
+	g.endMatch("forfeit_timeout")
+}
+
+func TestSeriesGapKeepsPlayersBusy(t *testing.T) {
+	defer func(gap time.Duration) { SeriesGap = gap }(SeriesGap)
+	SeriesGap = time.Hour
+	a, b, c := testPlayer(t), testPlayer(t), testPlayer(t)
+	s := startSeries(a, b, 3, game.DefaultRules(), "", nil)
+	_, matchID := s.state()
+	g, _ := GetGameState(matchID)
+	g.mu.Lock()
+	g.Game.Forfeit(game.SideB)
+	g.mu.Unlock()
+	seriesMatchOver(matchID, a.ID, "all_ships_sunk")
+	defer s.finish("", seriesAbandoned)
+
+	if !inLiveMatch(a.ID) || !inLiveMatch(b.ID) {
+		t.Fatal("players between series games should be busy")
+	}
+	if err := checkChallenge(c, a, game.DefaultRules()); err == nil || err.Error() != "target_busy" {
+		t.Errorf("challenge during the gap: got %v, want target_busy", err)
+	}
+	s.finish(a.ID, seriesDecided)
+	if inLiveMatch(a.ID) {
+		t.Error("players should be free once the series is over")
+	}
+}
+
+func TestRematchRefusesBusyPlayers(t *testing.T) {
+	a, b, g := testBattle(t, game.DefaultRules())
+	forfeitMatch(t, g, b)
+	c := testPlayer(t)
+	startMatch(a, c, matchOptions{Rules: game.DefaultRules()})
+
+	handleRematchRequest(b, &protocol.RematchRequest{MatchID: g.MatchID})
+	handleRematchResponse(a, &protocol.RematchResponse{MatchID: g.MatchID, Accept: true})
+
+	var e protocol.Error
+	if errs := sent(a, "error"); len(errs) != 1 || json.Unmarshal(errs[0], &e) != nil || e.Error != "already_in_match" {
+		t.Fatalf("acceptor errors = %s, want already_in_match", errs)
+	}
+	var fwd protocol.RematchResponseForward
+	if msgs := sent(b, "rematch_response_forward"); len(msgs) != 1 || json.Unmarshal(msgs[0], &fwd) != nil {
+		t.Fatalf("requester got %d forwards", len(msgs))
+	}
+	if fwd.Accept || fwd.Reason != "already_in_match" {
+		t.Errorf("forward = %+v, want refused with already_in_match", fwd)
+	}
+	if len(matchesFor(b.ID)) != 1 {
+		t.Error("no rematch should have started")
+	}
+}
+
+func TestFailedAcceptCancelsChallenge(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(a, b *Player)
+		want  string
+	}{
+		{"challenger gone", func(a, b *Player) {}, cancelDisconnected},
+		{"target between games", func(a, b *Player) {
+			// a bot challenger counts as present
+			a.bot = "easy"
+			s := newSeries(b.ID, uuid.NewString(), 3, game.DefaultRules())
+			seriesMu.Lock()
+			seriesWaiting[s.ID] = s
+			seriesMu.Unlock()
+			t.Cleanup(func() { s.finish("", seriesAbandoned) })
+		}, cancelBusy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := testPlayer(t), testPlayer(t)
+			handleChallenge(a, &protocol.Challenge{TargetID: b.ID})
+			var req protocol.ChallengeRequest
+			if msgs := sent(b, "challenge_request"); len(msgs) != 1 || json.Unmarshal(msgs[0], &req) != nil {
+				t.Fatalf("target got %d challenges", len(msgs))
+			}
+			tt.setup(a, b)
+
+			handleChallengeResponse(b, &protocol.ChallengeResponse{ChallengeID: req.ChallengeID, Accept: true})
+			for _, p := range []*Player{a, b} {
+				var cc protocol.ChallengeCancelled
+				msgs := sent(p, "challenge_cancelled")
+				if len(msgs) != 1 || json.Unmarshal(msgs[0], &cc) != nil || cc.Reason != tt.want {
+					t.Errorf("%s got challenge_cancelled %s, want reason %q", p.Name, msgs, tt.want)
+				}
+			}
+		})
+	}
+}
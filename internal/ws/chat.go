@@ -0,0 +1,173 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"battleship-go/internal/chat"
+	"battleship-go/internal/protocol"
+)
+
+// Chat channels. Players of a match and its spectators talk in separate
+// channels so spectators cannot pass ship positions to a player.
+const (
+	ChannelLobby      = "lobby"
+	ChannelMatch      = "match"
+	ChannelSpectators = "spectators"
+)
+
+var (
+	// ChatMaxLength caps a chat line, in characters.
+	ChatMaxLength = 200
+	// ChatFilter moderates every line before delivery.
+	ChatFilter chat.Filter = chat.NewWordFilter()
+	// ChatLimiter allows a burst of 5 lines, then one every 2 seconds.
+	ChatLimiter = chat.NewLimiter(5, 2*time.Second)
+	// chatHistory keeps recent lines per channel for latecomers.
+	chatHistory = chat.NewHistory(50)
+)
+
+func historyKey(channel, matchID string) string {
+	if matchID == "" {
+		return channel
+	}
+	return channel + ":" + matchID
+}
+
+// mutes reports whether p has muted fromID.
+func (p *Player) mutes(fromID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.muted[fromID]
+}
+
+func handleChat(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.Chat)
+	if err := sendChat(p, m.MatchID, m.Text); err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "chat"})
+	}
+}
+
+func sendChat(p *Player, matchID, text string) error {
+	text, err := chat.Normalize(text, ChatMaxLength)
+	if err != nil {
+		return err
+	}
+	if !ChatLimiter.Allow(p.ID) {
+		return errors.New("rate_limited")
+	}
+	if text, err = ChatFilter.Clean(text); err != nil {
+		return err
+	}
+	out := protocol.ChatMessage{
+		Channel:  ChannelLobby,
+		MatchID:  matchID,
+		FromID:   p.ID,
+		FromName: p.Name,
+		Text:     text,
+		SentAt:   time.Now().UnixMilli(),
+	}
+
+	if matchID == "" {
+		if !p.inLobby() {
+			return errNotJoined
+		}
+		chatHistory.Add(ChannelLobby, out)
+		for _, pl := range lobbyPlayers() {
+			if !pl.mutes(p.ID) {
+				pl.sendMsg(out)
+			}
+		}
+		return nil
+	}
+
+	g, ok := GetGameState(matchID)
+	if !ok {
+		return errors.New("match_not_found")
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var to []*Player
+	if _, seated := g.sideOf(p.ID); seated {
+		out.Channel = ChannelMatch
+		for _, id := range []string{g.PlayerAID, g.PlayerBID} {
+			if pl, ok := GetPlayer(id); ok {
+				to = append(to, pl)
+			}
+		}
+	} else if _, watching := g.spectators[p.ID]; watching {
+		out.Channel = ChannelSpectators
+		for _, s := range g.spectators {
+			to = append(to, s.p)
+		}
+	} else {
+		return errors.New("not_in_match")
+	}
+	chatHistory.Add(historyKey(out.Channel, matchID), out)
+	for _, pl := range to {
+		if !pl.mutes(p.ID) {
+			pl.sendMsg(out)
+		}
+	}
+	return nil
+}
+
+// inLobby reports whether p has joined the lobby and is still listed.
+func (p *Player) inLobby() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.listed
+}
+
+// lobbyPlayers lists the connected humans who joined the lobby and so
+// receive its chat and updates.
+func lobbyPlayers() []*Player {
+	playersMu.RLock()
+	defer playersMu.RUnlock()
+	out := make([]*Player, 0, len(players))
+	for _, p := range players {
+		if p.bot == "" && p.Connected() && p.inLobby() {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// sendChatHistory replays a channel's recent lines to p, leaving out
+// senders p has muted.
+func sendChatHistory(p *Player, channel, matchID string) {
+	recent := chatHistory.Recent(historyKey(channel, matchID))
+	msgs := make([]protocol.ChatMessage, 0, len(recent))
+	for _, m := range recent {
+		if !p.mutes(m.FromID) {
+			msgs = append(msgs, m)
+		}
+	}
+	p.sendMsg(protocol.ChatHistory{Channel: channel, MatchID: matchID, Messages: msgs})
+}
+
+// dropChatHistory forgets a match's channels once it leaves memory.
+func dropChatHistory(matchID string) {
+	chatHistory.Drop(historyKey(ChannelMatch, matchID))
+	chatHistory.Drop(historyKey(ChannelSpectators, matchID))
+}
+
+func handleChatMute(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.ChatMute)
+	if m.PlayerID == "" || m.PlayerID == p.ID {
+		p.sendMsg(protocol.Error{Error: "bad_mute_target", For: "chat_mute"})
+		return
+	}
+	p.mu.Lock()
+	if p.muted == nil {
+		p.muted = make(map[string]bool)
+	}
+	if m.Muted {
+		p.muted[m.PlayerID] = true
+	} else {
+		delete(p.muted, m.PlayerID)
+	}
+	p.mu.Unlock()
+	log.Println("handleChatMute:", p.ID, "muted", m.PlayerID, m.Muted)
+}
@@ -0,0 +1,123 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"battleship-go/internal/chat"
+	"battleship-go/internal/protocol"
+)
+
+// joined gives p a socket and lists it in the lobby.
+func joined(p *Player) *Player {
+	announceJoin(online(p), false)
+	return p
+}
+
+// lobbyLines drains p's lobby chat lines.
+func lobbyLines(t *testing.T, p *Player) []string {
+	t.Helper()
+	var out []string
+	for _, raw := range sent(p, "chat_message") {
+		var m protocol.ChatMessage
+		if err := json.Unmarshal(raw, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Channel == ChannelLobby {
+			out = append(out, m.Text)
+		}
+	}
+	return out
+}
+
+func TestLobbyChatNeedsJoin(t *testing.T) {
+	a, b := joined(testPlayer(t)), joined(testPlayer(t))
+	stranger := online(testPlayer(t))
+
+	if err := sendChat(stranger, "", "hello"); err != errNotJoined {
+		t.Fatalf("unjoined sender: got %v, want %v", err, errNotJoined)
+	}
+	if got := lobbyLines(t, a); len(got) != 0 {
+		t.Fatalf("lobby got %q from an unjoined sender", got)
+	}
+
+	if err := sendChat(a, "", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got := lobbyLines(t, b); len(got) != 1 || got[0] != "hello" {
+		t.Errorf("joined player got %q, want [hello]", got)
+	}
+	if got := lobbyLines(t, stranger); len(got) != 0 {
+		t.Errorf("unjoined socket got %q", got)
+	}
+
+	announceLeave(b)
+	sendChat(a, "", "again")
+	if got := lobbyLines(t, b); len(got) != 0 {
+		t.Errorf("player who left got %q", got)
+	}
+}
+
+func TestChatFilter(t *testing.T) {
+	defer func(f chat.Filter) { ChatFilter = f }(ChatFilter)
+	ChatFilter = chat.NewWordFilter("badword")
+	a, b := joined(testPlayer(t)), joined(testPlayer(t))
+
+	if err := sendChat(a, "", "a BadWord here"); err != nil {
+		t.Fatal(err)
+	}
+	if got := lobbyLines(t, b); len(got) != 1 || got[0] != "a ******* here" {
+		t.Errorf("got %q, want the word starred out", got)
+	}
+	if err := sendChat(a, "", "   "); err != chat.ErrEmpty {
+		t.Errorf("blank line: got %v, want %v", err, chat.ErrEmpty)
+	}
+}
+
+func TestChatRateLimit(t *testing.T) {
+	a, b := joined(testPlayer(t)), joined(testPlayer(t))
+	for i := 0; i < ChatLimiter.Burst; i++ {
+		if err := sendChat(a, "", "hi"); err != nil {
+			t.Fatalf("line %d: %v", i+1, err)
+		}
+	}
+	if err := sendChat(a, "", "hi"); err == nil || err.Error() != "rate_limited" {
+		t.Fatalf("line past the burst: got %v, want rate_limited", err)
+	}
+	if got := lobbyLines(t, b); len(got) != ChatLimiter.Burst {
+		t.Errorf("got %d lines, want %d", len(got), ChatLimiter.Burst)
+	}
+	if err := sendChat(b, "", "hi"); err != nil {
+		t.Errorf("other sender limited too: %v", err)
+	}
+}
+
+func TestChatMute(t *testing.T) {
+	a, b := joined(testPlayer(t)), joined(testPlayer(t))
+	handleChatMute(b, &protocol.ChatMute{PlayerID: a.ID, Muted: true})
+
+	sendChat(a, "", "muted line")
+	if got := lobbyLines(t, b); len(got) != 0 {
+		t.Errorf("muted sender reached b: %q", got)
+	}
+	sendChatHistory(b, ChannelLobby, "")
+	var h protocol.ChatHistory
+	if msgs := sent(b, "chat_history"); len(msgs) != 1 || json.Unmarshal(msgs[0], &h) != nil {
+		t.Fatalf("b got %d chat_history", len(msgs))
+	}
+	for _, m := range h.Messages {
+		if m.FromID == a.ID {
+			t.Errorf("history replays muted line %q", m.Text)
+		}
+	}
+
+	handleChatMute(b, &protocol.ChatMute{PlayerID: a.ID, Muted: false})
+	sendChat(a, "", "back")
+	if got := lobbyLines(t, b); len(got) != 1 || got[0] != "back" {
+		t.Errorf("after unmute got %q, want [back]", got)
+	}
+	handleChatMute(b, &protocol.ChatMute{PlayerID: b.ID, Muted: true})
+	if msgs := sent(b, "error"); len(msgs) != 1 {
+		t.Errorf("muting yourself: got %d errors, want 1", len(msgs))
+	}
+}
@@ -0,0 +1,176 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"math/rand"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+// TimeoutPolicy decides what happens when a turn clock runs out.
+type TimeoutPolicy string
+
+const (
+	TimeoutPass       TimeoutPolicy = "pass"
+	TimeoutRandomShot TimeoutPolicy = "random_shot"
+	TimeoutForfeit    TimeoutPolicy = "forfeit"
+)
+
+// ParseTimeoutPolicy checks a configured policy; empty means TimeoutPass.
+func ParseTimeoutPolicy(s string) (TimeoutPolicy, error) {
+	switch p := TimeoutPolicy(s); p {
+	case "":
+		return TimeoutPass, nil
+	case TimeoutPass, TimeoutRandomShot, TimeoutForfeit:
+		return p, nil
+	}
+	return "", errors.New("unknown_timeout_policy:" + s)
+}
+
+// ClockConfig configures per-match timing. Zero limits disable the
+// corresponding clock.
+type ClockConfig struct {
+	// TurnLimit bounds a single turn (reset after every shot).
+	TurnLimit time.Duration
+	// TotalLimit is a chess-style budget per side for the whole battle.
+	TotalLimit time.Duration
+	Policy     TimeoutPolicy
+	// MaxTimeouts forfeits a side after this many timeouts (0 = never).
+	MaxTimeouts int
+}
+
+// DefaultClock is applied to every new match.
+var DefaultClock = ClockConfig{Policy: TimeoutPass}
+
+// turnClock is owned by a GameState and only touched under its mu.
+type turnClock struct {
+	cfg       ClockConfig
+	timer     *time.Timer
+	seq       int
+	side      game.PlayerSide
+	started   time.Time
+	remaining [2]time.Duration
+	timeouts  [2]int
+}
+
+func newTurnClock(cfg ClockConfig) *turnClock {
+	return &turnClock{cfg: cfg, remaining: [2]time.Duration{cfg.TotalLimit, cfg.TotalLimit}}
+}
+
+func (c *turnClock) enabled() bool {
+	return c.cfg.TurnLimit > 0 || c.cfg.TotalLimit > 0
+}
+
+// start times the side due to shoot and announces the deadline.
+func (c *turnClock) start(g *GameState) {
+	if !c.enabled() {
+		return
+	}
+	c.stop()
+	c.side = g.Game.Turn()
+	c.started = time.Now()
+
+	limit := c.cfg.TurnLimit
+	if c.cfg.TotalLimit > 0 && (limit == 0 || c.remaining[c.side] < limit) {
+		limit = c.remaining[c.side]
+	}
+	seq := c.seq
+	c.timer = time.AfterFunc(limit, func() { g.turnTimedOut(seq) })
+
+	msg := protocol.TurnClock{
+		MatchID:    g.MatchID,
+		Turn:       string(wireSide(c.side)),
+		PlayerID:   g.playerOn(c.side),
+		DeadlineMs: c.started.Add(limit).UnixMilli(),
+		TurnMs:     limit.Milliseconds(),
+	}
+	if c.cfg.TotalLimit > 0 {
+		msg.RemainingMs = map[string]int64{
+			string(SideA): c.remaining[game.SideA].Milliseconds(),
+			string(SideB): c.remaining[game.SideB].Milliseconds(),
+		}
+	}
+	g.broadcast(msg)
+}
+
+// shotTaken charges the shooter for the time used and restarts the clock.
+func (c *turnClock) shotTaken(g *GameState) {
+	if !c.enabled() {
+		return
+	}
+	c.charge()
+	c.start(g)
+}
+
+func (c *turnClock) charge() {
+	if c.cfg.TotalLimit == 0 || c.timer == nil {
+		return
+	}
+	c.remaining[c.side] -= time.Since(c.started)
+	if c.remaining[c.side] < 0 {
+		c.remaining[c.side] = 0
+	}
+}
+
+// stop cancels the running timer; a callback already in flight sees a
+// stale seq and does nothing.
+func (c *turnClock) stop() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.seq++
+}
+
+func (g *GameState) turnTimedOut(seq int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	c := g.clock
+	if seq != c.seq || g.Game.State() != game.Battle {
+		return
+	}
+	side := c.side
+	c.charge()
+	c.stop()
+	c.timeouts[side]++
+
+	action := c.cfg.Policy
+	flagged := c.cfg.TotalLimit > 0 && c.remaining[side] <= 0
+	if flagged || (c.cfg.MaxTimeouts > 0 && c.timeouts[side] >= c.cfg.MaxTimeouts) {
+		action = TimeoutForfeit
+	}
+	log.Println("turnTimedOut: match", g.MatchID, "side", side, "timeouts", c.timeouts[side], "action", action)
+
+	playerID := g.playerOn(side)
+	g.broadcast(protocol.TurnTimeout{
+		MatchID:  g.MatchID,
+		PlayerID: playerID,
+		Side:     string(wireSide(side)),
+		Timeouts: c.timeouts[side],
+		Action:   string(action),
+	})
+
+	switch action {
+	case TimeoutForfeit:
+		g.Game.Forfeit(side)
+		g.logEvent("forfeit", eventForfeit{PlayerID: playerID, Reason: "forfeit_timeout"})
+		g.endMatch("forfeit_timeout")
+	case TimeoutRandomShot:
+		if g.Game.Rules().Mode == game.ModeSalvo {
+			g.fireSalvo(playerID, side, g.randomVolley(side))
+			return
+		}
+		cells := g.Game.OpenCells(side.Opponent())
+		shot := cells[rand.Intn(len(cells))]
+		g.fire(playerID, side, shot.X, shot.Y)
+	default:
+		g.Game.PassTurn()
+		g.logEvent("turn_passed", eventPlayer{PlayerID: playerID})
+		g.persist()
+		c.start(g)
+	}
+}
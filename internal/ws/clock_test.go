@@ -0,0 +1,106 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+func TestParseTimeoutPolicy(t *testing.T) {
+	tests := []struct {
+		in   string
+		want TimeoutPolicy
+		err  string
+	}{
+		{"", TimeoutPass, ""},
+		{"pass", TimeoutPass, ""},
+		{"random_shot", TimeoutRandomShot, ""},
+		{"forfeit", TimeoutForfeit, ""},
+		{"forfiet", "", "unknown_timeout_policy:forfiet"},
+	}
+	for _, tt := range tests {
+		got, err := ParseTimeoutPolicy(tt.in)
+		msg := ""
+		if err != nil {
+			msg = err.Error()
+		}
+		if got != tt.want || msg != tt.err {
+			t.Errorf("ParseTimeoutPolicy(%q) = %q, %v; want %q, %s", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestTurnTimedOut(t *testing.T) {
+	salvo := game.DefaultRules()
+	salvo.Mode = game.ModeSalvo
+
+	tests := []struct {
+		name        string
+		policy      TimeoutPolicy
+		maxTimeouts int
+		rules       game.RuleSet
+		action      TimeoutPolicy
+		shot        string // message b sees for a's automatic shot
+	}{
+		{"pass", TimeoutPass, 0, game.DefaultRules(), TimeoutPass, ""},
+		{"random shot", TimeoutRandomShot, 0, game.DefaultRules(), TimeoutRandomShot, "shot_result"},
+		{"random volley", TimeoutRandomShot, 0, salvo, TimeoutRandomShot, "salvo_result"},
+		{"forfeit", TimeoutForfeit, 0, game.DefaultRules(), TimeoutForfeit, ""},
+		{"too many timeouts", TimeoutPass, 1, game.DefaultRules(), TimeoutForfeit, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func(c ClockConfig) { DefaultClock = c }(DefaultClock)
+			DefaultClock = ClockConfig{TurnLimit: time.Hour, Policy: tt.policy, MaxTimeouts: tt.maxTimeouts}
+			a, b, g := testBattle(t, tt.rules)
+			sent(b, "")
+			g.mu.Lock()
+			seq := g.clock.seq
+			g.mu.Unlock()
+			g.turnTimedOut(seq)
+
+			var timeouts []protocol.TurnTimeout
+			shots := 0
+			for len(b.send) > 0 {
+				raw := <-b.send
+				var m struct {
+					Type string `json:"type"`
+					protocol.TurnTimeout
+				}
+				json.Unmarshal(raw, &m)
+				switch m.Type {
+				case "turn_timeout":
+					timeouts = append(timeouts, m.TurnTimeout)
+				case tt.shot:
+					shots++
+				}
+			}
+			if len(timeouts) != 1 || timeouts[0].PlayerID != a.ID || timeouts[0].Action != string(tt.action) {
+				t.Fatalf("timeouts announced: %+v, want a %s for a", timeouts, tt.action)
+			}
+			if tt.action == TimeoutForfeit {
+				if res := archived(t, g.MatchID); res.Reason != "forfeit_timeout" || res.WinnerID != b.ID {
+					t.Errorf("ended %q won by %q, want a timeout forfeit to b", res.Reason, res.WinnerID)
+				}
+				return
+			}
+			defer forfeitMatch(t, g, a)
+			g.mu.Lock()
+			turn, state := g.playerOn(g.Game.Turn()), g.Game.State()
+			g.mu.Unlock()
+			if state != game.Battle {
+				t.Fatalf("match left the battle: %s", state)
+			}
+			if tt.shot == "" {
+				if turn != b.ID {
+					t.Error("turn not passed to b")
+				}
+			} else if shots != 1 {
+				t.Errorf("no %s for the automatic shot", tt.shot)
+			}
+		})
+	}
+}
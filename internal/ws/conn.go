@@ -1,18 +1,21 @@
 package ws
 
 import (
-	"encoding/json"
 	"log"
-	"net/http"
+	"sync"
 	"time"
 
+	"battleship-go/internal/protocol"
+
 	"github.com/gorilla/websocket"
 )
 
+// upgrader keeps gorilla's default origin check: the upgrade is
+// authenticated by the session cookie, so a page on another site must not
+// be able to open a socket.
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin:     func(r *http.Request) bool { return true },
 }
 
 const (
@@ -26,217 +29,193 @@ type Player struct {
 	Name string
 	conn *websocket.Conn
 	send chan []byte
+
+	// version is the protocol revision negotiated on join.
+	version int
+	// bot is the difficulty of a computer player, empty for humans.
+	bot string
+	// account is the username the player is logged in as, empty for guests
+	// and bots.
+	account string
+
+	// mu guards the connection attachment below. The send channel outlives
+	// any single connection so messages queued while detached are flushed
+	// by the next writePump.
+	mu         sync.Mutex
+	done       chan struct{}
+	unsent     [][]byte
+	detachedAt time.Time
+	// muted holds the IDs whose chat this player does not want to see.
+	muted map[string]bool
+	// listed is set while the lobby knows about this player.
+	listed bool
+}
+
+func newPlayer(id string) *Player {
+	return &Player{
+		ID:      id,
+		send:    make(chan []byte, 256),
+		version: protocol.Version,
+	}
 }
 
-func (p *Player) readPump() {
+// attach binds conn to the player, replacing (and closing) any previous
+// connection. greeting is written before anything already queued.
+func (p *Player) attach(conn *websocket.Conn, greeting []byte) chan struct{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conn != nil {
+		close(p.done)
+		p.conn.Close()
+	}
+	p.conn = conn
+	p.done = make(chan struct{})
+	p.detachedAt = time.Time{}
+	if greeting != nil {
+		p.unsent = append([][]byte{greeting}, p.unsent...)
+	}
+	return p.done
+}
+
+// detach drops conn if it is still the active one and schedules the player
+// for removal unless it resumes within sessionGrace.
+func (p *Player) detach(conn *websocket.Conn) {
+	p.mu.Lock()
+	if p.conn != conn {
+		p.mu.Unlock()
+		return
+	}
+	close(p.done)
+	p.conn = nil
+	p.detachedAt = time.Now()
+	detachedAt := p.detachedAt
+	p.mu.Unlock()
+
+	playerDisconnected(p)
+	unspectateAll(p)
+	leaveQueue(p.ID)
+	withdrawChallengesOf(p.ID, cancelDisconnected)
+	closeRoomsOf(p.ID, roomOwnerLeft)
+	announceLeave(p)
+
+	time.AfterFunc(sessionGrace, func() {
+		p.mu.Lock()
+		expired := p.conn == nil && p.detachedAt.Equal(detachedAt)
+		p.mu.Unlock()
+		if expired {
+			log.Println("detach: session expired for", p.ID)
+			UnregisterPlayer(p.ID)
+		}
+	})
+}
+
+// Connected reports whether the player currently has a live socket.
+func (p *Player) Connected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.conn != nil
+}
+
+// sendMsg encodes m and queues it without blocking the caller.
+func (p *Player) sendMsg(m protocol.Message) {
+	b, err := protocol.Encode(m)
+	if err != nil {
+		log.Println("sendMsg: encode failed for", p.ID, err)
+		return
+	}
+	select {
+	case p.send <- b:
+	default:
+		log.Println("sendMsg: send blocked for", p.ID, m.MessageType())
+	}
+}
+
+func (p *Player) takeUnsent() [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	out := p.unsent
+	p.unsent = nil
+	return out
+}
+
+func (p *Player) keepUnsent(msg []byte) {
+	p.mu.Lock()
+	p.unsent = append(p.unsent, msg)
+	p.mu.Unlock()
+}
+
+func (p *Player) readPump(conn *websocket.Conn) {
 	defer func() {
 
-		UnregisterPlayer(p.ID)
+		p.detach(conn)
 
-		p.conn.Close()
+		conn.Close()
 		log.Println("readPump: exiting for", p.ID)
 	}()
 	log.Println("readPump: starting for", p.ID)
 
-	log.Println("readPump: starting for", p.ID)
-	p.conn.SetReadLimit(512)
-	p.conn.SetReadDeadline(time.Now().Add(pongWait))
-	p.conn.SetPongHandler(func(string) error { p.conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
+	conn.SetReadLimit(protocol.MaxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error { conn.SetReadDeadline(time.Now().Add(pongWait)); return nil })
 
 	for {
-		mt, message, err := p.conn.ReadMessage()
+		mt, message, err := conn.ReadMessage()
 		if err != nil {
-			log.Println("readPump: starting for", p.ID)
+			log.Println("readPump: read error for", p.ID, err)
 			break
 		}
 		if mt != websocket.TextMessage {
 			continue
 		}
-		var envelope struct {
-			Type string `json:"type"`
-			Name string `json:"name,omitempty"`
-		}
-		if err := json.Unmarshal(message, &envelope); err != nil {
-			continue
-		}
-		switch envelope.Type {
-		case "join":
-			if envelope.Name != "" {
-				p.Name = envelope.Name
-			} else {
-				p.Name = "Player-" + p.ID[:8]
-			}
-			ack := map[string]string{
-				"type": "join_ack",
-				"id":   p.ID,
-				"name": p.Name,
-			}
-			ackBytes, _ := json.Marshal(ack)
-			p.send <- ackBytes
-		case "challenge":
-			var payload struct {
-				TargetID string `json:"target_id"`
-			}
-			if err := json.Unmarshal(message, &payload); err != nil || payload.TargetID == "" {
-				continue
-			}
-			if target, ok := GetPlayer(payload.TargetID); ok {
-				req := map[string]string{
-					"type":      "challenge_request",
-					"from_id":   p.ID,
-					"from_name": p.Name,
-				}
-				b, _ := json.Marshal(req)
-				target.send <- b
-			} else {
-				errMsg := map[string]string{
-					"type":  "error",
-					"error": "target_not_found",
-				}
-				b, _ := json.Marshal(errMsg)
-				p.send <- b
-			}
-
-		case "challenge_response":
-			var payload struct {
-				TargetID string `json:"target_id"`
-				Accept   bool   `json:"accept"`
-			}
-			if err := json.Unmarshal(message, &payload); err != nil || payload.TargetID == "" {
-				continue
-			}
-
-			challenger, ok := GetPlayer(payload.TargetID)
-			if !ok {
-
-				resp := map[string]string{
-					"type":  "error",
-					"error": "challenger_not_connected",
-				}
-				b, _ := json.Marshal(resp)
-				p.send <- b
-				continue
-			}
-
-			forward := map[string]interface{}{
-				"type":      "challenge_response_forward",
-				"from_id":   p.ID,
-				"from_name": p.Name,
-				"accept":    payload.Accept,
-				"target_id": payload.TargetID,
-			}
-			fb, _ := json.Marshal(forward)
-			challenger.send <- fb
-
-			if payload.Accept {
-
-				m, assignment := createMatch(p.ID, challenger.ID)
-
-				RegisterMatchState(m)
-
-				chalSide := assignment[challenger.ID]
-				chalMsg := map[string]interface{}{
-					"type":          "match_start",
-					"match_id":      m.ID,
-					"your_side":     string(chalSide),
-					"opponent_id":   p.ID,
-					"opponent_name": p.Name,
-				}
-				cb, _ := json.Marshal(chalMsg)
-				challenger.send <- cb
-
-				accSide := assignment[p.ID]
-				accMsg := map[string]interface{}{
-					"type":          "match_start",
-					"match_id":      m.ID,
-					"your_side":     string(accSide),
-					"opponent_id":   challenger.ID,
-					"opponent_name": challenger.Name,
-				}
-				ab, _ := json.Marshal(accMsg)
-				p.send <- ab
-
-			}
-		case "place_ships":
-
-			var payload struct {
-				MatchID string          `json:"match_id"`
-				Ships   []ShipPlacement `json:"ships"`
-			}
-			if err := json.Unmarshal(message, &payload); err != nil {
-
-				errMsg := map[string]string{"type": "error", "error": "bad_place_ships"}
-				b, _ := json.Marshal(errMsg)
-				p.send <- b
-				continue
-			}
-
-			if err := SetPlayerShips(payload.MatchID, p.ID, payload.Ships); err != nil {
-				errMsg := map[string]string{"type": "ships_error", "error": err.Error()}
-				b, _ := json.Marshal(errMsg)
-				p.send <- b
-				continue
-			}
-
-			okMsg := map[string]string{"type": "ships_ok", "match_id": payload.MatchID}
-			b, _ := json.Marshal(okMsg)
-			p.send <- b
-
-		case "shot_fired":
-
-			var payload struct {
-				MatchID string `json:"match_id"`
-				X       int    `json:"x"`
-				Y       int    `json:"y"`
-			}
-			if err := json.Unmarshal(message, &payload); err != nil {
-				log.Println("conn: bad_shot_payload", err)
-				errMsg := map[string]string{"type": "error", "error": "bad_shot_payload"}
-				b, _ := json.Marshal(errMsg)
-				p.send <- b
-				continue
-			}
-			log.Println("conn: shot_fired received from", p.ID, "payload:", payload)
-
-			_, err := ProcessShot(payload.MatchID, p.ID, payload.X, payload.Y)
-			if err != nil {
-				errMsg := map[string]string{"type": "shot_error", "error": err.Error()}
-				b, _ := json.Marshal(errMsg)
-				p.send <- b
-				continue
-			}
-
+		typ, msg, err := protocol.Decode(message)
+		switch err {
+		case nil:
+			dispatch(p, msg)
+		case protocol.ErrUnknownType:
+			log.Println("readPump: ignoring unknown message type", typ, "from", p.ID)
 		default:
-
+			p.sendMsg(protocol.Error{Error: err.Error(), For: typ})
 		}
 	}
 	log.Println("readPump: exiting for", p.ID)
 }
 
-func (p *Player) writePump() {
+func (p *Player) writePump(conn *websocket.Conn, done chan struct{}) {
 	log.Println("writePump: starting for", p.ID)
 	ticker := time.NewTicker(pingPeriod)
 	defer func() {
 		ticker.Stop()
-		p.conn.Close()
+		conn.Close()
 		log.Println("writePump: exiting for", p.ID)
 	}()
 
+	// flush whatever was queued before this connection attached
+	backlog := p.takeUnsent()
+	for i, msg := range backlog {
+		conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			for _, m := range backlog[i:] {
+				p.keepUnsent(m)
+			}
+			return
+		}
+	}
+
 	for {
 		select {
-		case msg, ok := <-p.send:
-			p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if !ok {
-				log.Println("writePump: send channel closed for", p.ID)
-				p.conn.WriteMessage(websocket.CloseMessage, []byte{})
-				return
-			}
-			if err := p.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+		case <-done:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			conn.WriteMessage(websocket.CloseMessage, []byte{})
+			return
+		case msg := <-p.send:
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				p.keepUnsent(msg)
 				return
 			}
 		case <-ticker.C:
-			p.conn.SetWriteDeadline(time.Now().Add(writeWait))
-			if err := p.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
 		}
@@ -0,0 +1,79 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"battleship-go/internal/tournament"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// readType reads frames from conn until one of type typ arrives.
+func readType(t *testing.T, conn *websocket.Conn, typ string) map[string]interface{} {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		_, b, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(b, &m); err != nil {
+			t.Fatal(err)
+		}
+		if m["type"] == typ {
+			return m
+		}
+		if m["type"] == "error" {
+			t.Fatalf("waiting for %s: got %v", typ, m)
+		}
+	}
+}
+
+func TestTournamentCreateFullRoster(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(HandleWS))
+	defer srv.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	id := readType(t, conn, "welcome")["id"].(string)
+
+	roster := []string{id}
+	for len(roster) < tournament.MaxPlayers {
+		p := newPlayer(uuid.NewString())
+		p.Name = "p" + p.ID[:8]
+		RegisterPlayer(p)
+		roster = append(roster, p.ID)
+	}
+	frame, err := json.Marshal(map[string]interface{}{
+		"type":    "tournament_create",
+		"name":    strings.Repeat("<", maxTournamentName),
+		"format":  tournament.SingleElimination,
+		"players": roster,
+		"rules":   map[string]interface{}{"mode": "salvo", "salvo_shots": 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frame) <= 512 {
+		t.Fatalf("frame is only %d bytes", len(frame))
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, frame); err != nil {
+		t.Fatal(err)
+	}
+	update := readType(t, conn, "tournament_update")
+	if update["event"] != "created" {
+		t.Fatalf("event = %v, want created", update["event"])
+	}
+	if n := len(update["tournament"].(map[string]interface{})["players"].([]interface{})); n != tournament.MaxPlayers {
+		t.Fatalf("roster of %d, want %d", n, tournament.MaxPlayers)
+	}
+}
@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"log"
+	"time"
+
+	"battleship-go/internal/protocol"
+)
+
+// DisconnectGrace is how long a match waits for a dropped player to come
+// back before awarding it to the opponent.
+var DisconnectGrace = 60 * time.Second
+
+// matchesFor returns the matches playerID is seated in.
+func matchesFor(playerID string) []*GameState {
+	gamesMu.RLock()
+	defer gamesMu.RUnlock()
+	out := make([]*GameState, 0)
+	for _, g := range games {
+		if g.PlayerAID == playerID || g.PlayerBID == playerID {
+			out = append(out, g)
+		}
+	}
+	return out
+}
+
+// playerDisconnected starts the grace window in every live match of p.
+func playerDisconnected(p *Player) {
+	for _, g := range matchesFor(p.ID) {
+		g.mu.Lock()
+		side, _ := g.sideOf(p.ID)
+		if g.Game.State().Over() {
+			g.mu.Unlock()
+			continue
+		}
+		g.startGrace(p.ID)
+
+		if opp, ok := GetPlayer(g.playerOn(side.Opponent())); ok {
+			opp.sendMsg(protocol.OpponentDisconnected{
+				MatchID:    g.MatchID,
+				PlayerID:   p.ID,
+				GraceMs:    DisconnectGrace.Milliseconds(),
+				DeadlineMs: time.Now().Add(DisconnectGrace).UnixMilli(),
+			})
+		}
+		g.mu.Unlock()
+		log.Println("playerDisconnected:", p.ID, "match", g.MatchID, "grace", DisconnectGrace)
+	}
+}
+
+// startGrace gives playerID DisconnectGrace to come back before the match
+// is forfeited. Callers hold g.mu.
+func (g *GameState) startGrace(playerID string) {
+	if g.graceTimers == nil {
+		g.graceTimers = make(map[string]*time.Timer)
+	}
+	if t, ok := g.graceTimers[playerID]; ok {
+		t.Stop()
+	}
+	g.graceTimers[playerID] = time.AfterFunc(DisconnectGrace, func() { g.graceExpired(playerID) })
+}
+
+// playerReconnected cancels pending forfeits for p and tells opponents.
+func playerReconnected(p *Player) {
+	for _, g := range matchesFor(p.ID) {
+		g.mu.Lock()
+		t, waiting := g.graceTimers[p.ID]
+		if waiting {
+			t.Stop()
+			delete(g.graceTimers, p.ID)
+			side, _ := g.sideOf(p.ID)
+			if opp, ok := GetPlayer(g.playerOn(side.Opponent())); ok {
+				opp.sendMsg(protocol.OpponentReconnected{MatchID: g.MatchID, PlayerID: p.ID})
+			}
+		}
+		g.mu.Unlock()
+	}
+}
+
+func (g *GameState) graceExpired(playerID string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, waiting := g.graceTimers[playerID]; !waiting {
+		return
+	}
+	delete(g.graceTimers, playerID)
+	if g.Game.State().Over() {
+		return
+	}
+	side, _ := g.sideOf(playerID)
+	// with both players gone there is nobody to award the match to
+	if _, oppGone := g.graceTimers[g.playerOn(side.Opponent())]; oppGone {
+		g.Game.Abandon()
+		g.logEvent("abandoned", nil)
+		g.endMatch("abandoned")
+		return
+	}
+	g.Game.Forfeit(side)
+	g.logEvent("forfeit", eventForfeit{PlayerID: playerID, Reason: "forfeit_disconnect"})
+	g.endMatch("forfeit_disconnect")
+}
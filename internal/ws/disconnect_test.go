@@ -0,0 +1,160 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/store"
+)
+
+// archived waits for matchID to be archived and returns how it ended.
+func archived(t *testing.T, matchID string) *store.MatchResult {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if rec, ok, _ := matchStore.LoadArchived(matchID); ok {
+			return rec.Result
+		}
+	}
+	t.Fatalf("match %s never ended", matchID)
+	return nil
+}
+
+func TestGraceExpiry(t *testing.T) {
+	defer func(d time.Duration) { DisconnectGrace = d }(DisconnectGrace)
+	DisconnectGrace = 20 * time.Millisecond
+
+	tests := []struct {
+		name   string
+		leave  string // "a", "b" or "ab"
+		back   bool
+		reason string
+		winner string
+	}{
+		{"opponent wins", "b", false, "forfeit_disconnect", "a"},
+		{"both gone", "ab", false, "abandoned", ""},
+		{"back in time", "b", true, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b, g := testBattle(t, game.DefaultRules())
+			seats := map[byte]*Player{'a': a, 'b': b}
+			for i := range tt.leave {
+				playerDisconnected(seats[tt.leave[i]])
+			}
+			if tt.leave == "b" && len(sent(a, "opponent_disconnected")) != 1 {
+				t.Error("opponent not told about the disconnect")
+			}
+			if tt.back {
+				playerReconnected(b)
+				time.Sleep(3 * DisconnectGrace)
+				if _, ok, _ := matchStore.LoadArchived(g.MatchID); ok {
+					t.Fatal("match ended although the player came back")
+				}
+				if len(sent(a, "opponent_reconnected")) != 1 {
+					t.Error("opponent not told about the reconnect")
+				}
+				return
+			}
+			res := archived(t, g.MatchID)
+			winner := ""
+			if tt.winner != "" {
+				winner = seats[tt.winner[0]].ID
+			}
+			if res.Reason != tt.reason || res.WinnerID != winner {
+				t.Errorf("ended %q won by %q, want %q won by %q", res.Reason, res.WinnerID, tt.reason, winner)
+			}
+		})
+	}
+}
+
+func TestRestoreResumesMatches(t *testing.T) {
+	defer func(s store.Store, d time.Duration) { matchStore, DisconnectGrace = s, d }(matchStore, DisconnectGrace)
+	SetStore(store.NewMemoryStore())
+	DisconnectGrace = 20 * time.Millisecond
+
+	a, _, human := testBattle(t, game.DefaultRules())
+	c, bot := testPlayer(t), testPlayer(t)
+	bot.bot = "easy"
+	vsBot := startMatch(c, bot, matchOptions{Rules: game.DefaultRules()})
+	// a restart keeps nothing but the store
+	human.mu.Lock()
+	human.clock.stop()
+	human.mu.Unlock()
+	gamesMu.Lock()
+	delete(games, human.MatchID)
+	delete(games, vsBot.MatchID)
+	gamesMu.Unlock()
+
+	if err := RestoreMatches(); err != nil {
+		t.Fatal(err)
+	}
+	if res, ok, _ := matchStore.LoadArchived(vsBot.MatchID); !ok || res.Result.Reason != "abandoned" {
+		t.Errorf("match against a bot survived the restart: %+v", res.Result)
+	}
+	restored, ok := GetGameState(human.MatchID)
+	if !ok {
+		t.Fatal("match not restored")
+	}
+	restored.mu.Lock()
+	timers := len(restored.graceTimers)
+	restored.mu.Unlock()
+	if timers != 2 {
+		t.Fatalf("%d grace timers armed, want one per seat", timers)
+	}
+	playerReconnected(a)
+	if res := archived(t, human.MatchID); res.Reason != "forfeit_disconnect" || res.WinnerID != a.ID {
+		t.Errorf("ended %q won by %q, want a forfeit to the returning player", res.Reason, res.WinnerID)
+	}
+}
+
+func TestRestoreArchivesFinishedMatches(t *testing.T) {
+	defer func(s store.Store) { matchStore = s }(matchStore)
+	tests := []struct {
+		name   string
+		logged bool // whether endMatch got to log match_over
+	}{
+		{"only the archive missing", true},
+		{"stopped before ending", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStore(store.NewMemoryStore())
+			a, b, g := testBattle(t, game.DefaultRules())
+			// the server stops right after b's forfeit is saved
+			g.mu.Lock()
+			g.clock.stop()
+			side, _ := g.sideOf(b.ID)
+			g.Game.Forfeit(side)
+			g.logEvent("forfeit", eventForfeit{PlayerID: b.ID, Reason: "forfeit_disconnect"})
+			if tt.logged {
+				g.logEvent("match_over", eventMatchOver{WinnerID: a.ID, Reason: "forfeit_disconnect"})
+			}
+			g.persist()
+			g.mu.Unlock()
+			gamesMu.Lock()
+			delete(games, g.MatchID)
+			gamesMu.Unlock()
+
+			if err := RestoreMatches(); err != nil {
+				t.Fatal(err)
+			}
+			if res := archived(t, g.MatchID); res.Reason != "forfeit_disconnect" || res.WinnerID != a.ID {
+				t.Errorf("archived as %q won by %q, want a disconnect forfeit to a", res.Reason, res.WinnerID)
+			}
+			if live, _ := matchStore.LoadMatches(); len(live) != 0 {
+				t.Errorf("%d matches still live", len(live))
+			}
+			events, _ := matchStore.LoadEvents(g.MatchID)
+			overs := 0
+			for _, ev := range events {
+				if ev.Type == "match_over" {
+					overs++
+				}
+			}
+			if overs != 1 {
+				t.Errorf("%d match_over events logged, want 1", overs)
+			}
+		})
+	}
+}
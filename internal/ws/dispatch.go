@@ -0,0 +1,119 @@
+package ws
+
+import (
+	"log"
+	"strings"
+
+	"battleship-go/internal/protocol"
+)
+
+// handlerFunc processes one decoded inbound message for p.
+type handlerFunc func(p *Player, msg protocol.Message)
+
+// handlers is the registry of inbound message handlers keyed by type.
+var handlers = map[string]handlerFunc{}
+
+func handle(typ string, h handlerFunc) {
+	if _, dup := protocol.Inbound[typ]; !dup {
+		log.Fatal("handle: no protocol message registered for ", typ)
+	}
+	handlers[typ] = h
+}
+
+func init() {
+	handle("join", handleJoin)
+	handle("rename", handleRename)
+	handle("challenge", handleChallenge)
+	handle("challenge_response", handleChallengeResponse)
+	handle("challenge_cancel", handleChallengeCancel)
+	handle("place_ships", handlePlaceShips)
+	handle("shot_fired", handleShotFired)
+	handle("salvo_fired", handleSalvoFired)
+	handle("auto_place", handleAutoPlace)
+	handle("queue_join", handleQueueJoin)
+	handle("queue_leave", handleQueueLeave)
+	handle("room_create", handleRoomCreate)
+	handle("room_join", handleRoomJoin)
+	handle("room_close", handleRoomClose)
+	handle("tournament_create", handleTournamentCreate)
+	handle("tournament_cancel", handleTournamentCancel)
+	handle("tournament_response", handleTournamentResponse)
+	handle("rematch_request", handleRematchRequest)
+	handle("rematch_response", handleRematchResponse)
+	handle("spectate", handleSpectate)
+	handle("stop_spectating", handleStopSpectating)
+	handle("chat", handleChat)
+	handle("chat_mute", handleChatMute)
+}
+
+func dispatch(p *Player, msg protocol.Message) {
+	h, ok := handlers[msg.MessageType()]
+	if !ok {
+		log.Println("dispatch: no handler for", msg.MessageType())
+		return
+	}
+	h(p, msg)
+}
+
+func handleJoin(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.Join)
+	version, ok := protocol.Negotiate(m.ProtocolVersion)
+	if !ok {
+		p.sendMsg(protocol.Error{Error: "unsupported_protocol_version", For: "join"})
+		return
+	}
+	p.version = version
+
+	// accounts always play under their username; a guest without a name
+	// keeps the one it has or gets a generated one
+	previous := p.Name
+	switch {
+	case p.account != "":
+		p.Name = p.account
+	case strings.TrimSpace(m.Name) == "":
+		if p.Name == "" {
+			claimDefaultName(p)
+		}
+	default:
+		if name, suggestion, err := claimName(p, m.Name); err != nil {
+			nameError(p, "join", name, suggestion, err)
+			return
+		}
+	}
+	p.sendMsg(protocol.JoinAck{
+		ID:              p.ID,
+		Name:            p.Name,
+		ResumeToken:     issueResumeToken(p.ID),
+		ProtocolVersion: p.version,
+		Rating:          touchProfile(p).Rating,
+	})
+	announceJoin(p, p.Name != previous)
+	sendChatHistory(p, ChannelLobby, "")
+	// a returning player may be due in a tournament
+	go scheduleTournaments()
+}
+
+func handlePlaceShips(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.PlaceShips)
+	if err := SetPlayerShips(m.MatchID, p.ID, m.Ships); err != nil {
+		p.sendMsg(protocol.ShipsError{Error: err.Error()})
+		return
+	}
+	p.sendMsg(protocol.ShipsOK{MatchID: m.MatchID})
+}
+
+func handleSalvoFired(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.SalvoFired)
+	if _, err := ProcessSalvo(m.MatchID, p.ID, m.Shots); err != nil {
+		p.sendMsg(protocol.ShotError{MatchID: m.MatchID, Error: err.Error()})
+	}
+}
+
+func handleShotFired(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.ShotFired)
+	log.Println("conn: shot_fired received from", p.ID, "payload:", *m)
+
+	if _, err := ProcessShot(m.MatchID, p.ID, m.X, m.Y); err != nil {
+		p.sendMsg(protocol.ShotError{MatchID: m.MatchID, Error: err.Error()})
+	}
+}
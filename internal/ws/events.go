@@ -0,0 +1,208 @@
+package ws
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/store"
+)
+
+// Event payloads, one per store.Event type. Together they are enough to
+// rebuild a match from scratch with replayMatch.
+type (
+	eventMatchStart struct {
+		PlayerAID string       `json:"player_a_id"`
+		PlayerBID string       `json:"player_b_id"`
+		FirstID   string       `json:"first_id"`
+		Rules     game.RuleSet `json:"rules"`
+	}
+	eventFleetPlaced struct {
+		PlayerID string           `json:"player_id"`
+		Ships    []game.Placement `json:"ships"`
+	}
+	eventShot struct {
+		PlayerID string `json:"player_id"`
+		X        int    `json:"x"`
+		Y        int    `json:"y"`
+		Hit      bool   `json:"hit"`
+		Sunk     string `json:"sunk,omitempty"`
+		GameOver bool   `json:"game_over,omitempty"`
+	}
+	eventSalvo struct {
+		PlayerID string       `json:"player_id"`
+		Shots    []game.Coord `json:"shots"`
+		Hits     int          `json:"hits"`
+		GameOver bool         `json:"game_over,omitempty"`
+	}
+	eventShipSunk struct {
+		OwnerID  string       `json:"owner_id"`
+		ByID     string       `json:"by_id"`
+		ShipType string       `json:"ship_type"`
+		Cells    []game.Coord `json:"cells"`
+		Water    []game.Coord `json:"water,omitempty"`
+	}
+	eventPlayer struct {
+		PlayerID string `json:"player_id"`
+	}
+	eventForfeit struct {
+		PlayerID string `json:"player_id"`
+		Reason   string `json:"reason,omitempty"`
+	}
+	eventMatchOver struct {
+		WinnerID string `json:"winner_id,omitempty"`
+		Reason   string `json:"reason"`
+	}
+)
+
+// logEvent appends the next event to the match log. Callers hold g.mu.
+func (g *GameState) logEvent(typ string, data interface{}) {
+	g.eventSeq++
+	ev := store.Event{MatchID: g.MatchID, Seq: g.eventSeq, Type: typ, At: time.Now()}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			log.Println("logEvent:", typ, "for", g.MatchID, "failed:", err)
+			return
+		}
+		ev.Data = b
+	}
+	if err := matchStore.AppendEvent(ev); err != nil {
+		log.Println("logEvent: appending", typ, "for", g.MatchID, "failed:", err)
+	}
+}
+
+// replayMatch rebuilds a match by running its events through a fresh
+// engine, checking every recorded outcome along the way.
+func replayMatch(events []store.Event) (*GameState, error) {
+	if len(events) == 0 || events[0].Type != "match_start" {
+		return nil, errors.New("replay_no_start")
+	}
+	g, err := replayStart(events[0])
+	if err != nil {
+		return nil, fmt.Errorf("replay_failed:%d: %w", events[0].Seq, err)
+	}
+	for i, ev := range events {
+		if ev.Seq != i+1 {
+			return nil, fmt.Errorf("replay_gap:%d", ev.Seq)
+		}
+		if i > 0 {
+			if err := g.apply(ev); err != nil {
+				return nil, fmt.Errorf("replay_failed:%d: %w", ev.Seq, err)
+			}
+		}
+		g.eventSeq = ev.Seq
+	}
+	return g, nil
+}
+
+func replayStart(ev store.Event) (*GameState, error) {
+	var d eventMatchStart
+	if err := json.Unmarshal(ev.Data, &d); err != nil {
+		return nil, err
+	}
+	first := game.SideA
+	if d.FirstID == d.PlayerBID {
+		first = game.SideB
+	}
+	g := &GameState{
+		MatchID:   ev.MatchID,
+		PlayerAID: d.PlayerAID,
+		PlayerBID: d.PlayerBID,
+		CreatedAt: ev.At,
+		FirstID:   d.FirstID,
+		Game:      game.New(first, d.Rules),
+		clock:     newTurnClock(DefaultClock),
+	}
+	g.Game.OpenPlacement()
+	return g, nil
+}
+
+// apply replays one event after match_start onto g.
+func (g *GameState) apply(ev store.Event) error {
+	side := func(id string) (game.PlayerSide, error) {
+		s, ok := g.sideOf(id)
+		if !ok {
+			return s, errors.New("unknown_player")
+		}
+		return s, nil
+	}
+	switch ev.Type {
+	case "fleet_placed":
+		var d eventFleetPlaced
+		if err := json.Unmarshal(ev.Data, &d); err != nil {
+			return err
+		}
+		s, err := side(d.PlayerID)
+		if err != nil {
+			return err
+		}
+		_, err = g.Game.PlaceFleet(s, d.Ships)
+		return err
+	case "shot":
+		var d eventShot
+		if err := json.Unmarshal(ev.Data, &d); err != nil {
+			return err
+		}
+		s, err := side(d.PlayerID)
+		if err != nil {
+			return err
+		}
+		res, err := g.Game.Fire(s, d.X, d.Y)
+		if err != nil {
+			return err
+		}
+		if res.Hit != d.Hit || res.Sunk != d.Sunk || res.GameOver != d.GameOver {
+			return errors.New("diverged")
+		}
+	case "salvo":
+		var d eventSalvo
+		if err := json.Unmarshal(ev.Data, &d); err != nil {
+			return err
+		}
+		s, err := side(d.PlayerID)
+		if err != nil {
+			return err
+		}
+		res, err := g.Game.FireSalvo(s, d.Shots)
+		if err != nil {
+			return err
+		}
+		hits := 0
+		for _, shot := range res.Shots {
+			if shot.Hit {
+				hits++
+			}
+		}
+		if hits != d.Hits || res.GameOver != d.GameOver {
+			return errors.New("diverged")
+		}
+	case "turn_passed":
+		if _, err := g.Game.PassTurn(); err != nil {
+			return err
+		}
+	case "forfeit":
+		var d eventForfeit
+		if err := json.Unmarshal(ev.Data, &d); err != nil {
+			return err
+		}
+		s, err := side(d.PlayerID)
+		if err != nil {
+			return err
+		}
+		return g.Game.Forfeit(s)
+	case "abandoned":
+		return g.Game.Abandon()
+	case "match_over":
+		var d eventMatchOver
+		if err := json.Unmarshal(ev.Data, &d); err != nil {
+			return err
+		}
+	case "match_start":
+		return errors.New("duplicate_start")
+	}
+	return nil
+}
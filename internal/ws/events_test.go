@@ -0,0 +1,29 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"battleship-go/internal/store"
+)
+
+func TestReplayMatchRejectsBadStart(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []store.Event
+		want   string
+	}{
+		{"no events", nil, "replay_no_start"},
+		{"not a start", []store.Event{{Seq: 1, Type: "shot"}}, "replay_no_start"},
+		{"corrupt start", []store.Event{{Seq: 1, Type: "match_start", Data: json.RawMessage(`{"player_a_id":1}`)}}, "replay_failed:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := replayMatch(tt.events)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.want) {
+				t.Errorf("replayMatch: got %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package ws
+
+import (
+	"math/rand"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+// randomFleet generates a fleet for rules from seed, drawing a fresh seed
+// when none is given, and returns the seed used.
+func randomFleet(rules game.RuleSet, seed *int64, c game.FleetConstraints) (int64, []game.Placement, error) {
+	s := rand.Int63()
+	if seed != nil {
+		s = *seed
+	}
+	ships, err := game.RandomFleet(rand.New(rand.NewSource(s)), rules, c)
+	return s, ships, err
+}
+
+func handleAutoPlace(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.AutoPlace)
+	g, err := lookupMatch(m.MatchID)
+	if err != nil {
+		p.sendMsg(protocol.ShipsError{Error: err.Error()})
+		return
+	}
+	if _, ok := g.sideOf(p.ID); !ok {
+		p.sendMsg(protocol.ShipsError{Error: "not_in_match"})
+		return
+	}
+	g.mu.Lock()
+	rules := g.Game.Rules()
+	g.mu.Unlock()
+
+	seed, ships, err := randomFleet(rules, m.Seed, m.FleetConstraints)
+	if err != nil {
+		p.sendMsg(protocol.ShipsError{Error: err.Error()})
+		return
+	}
+	p.sendMsg(protocol.AutoPlaced{MatchID: m.MatchID, Seed: seed, Ships: ships})
+	if m.Place {
+		handlePlaceShips(p, &protocol.PlaceShips{MatchID: m.MatchID, Ships: ships})
+	}
+}
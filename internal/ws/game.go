@@ -1,25 +1,35 @@
 package ws
 
 import (
-	"encoding/json"
 	"errors"
-	"fmt"
 	"log"
+	"math/rand"
 	"sync"
+	"time"
 
 	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
 )
 
+// GameState binds a rules engine instance to the two connected players.
+// All engine access goes through mu.
 type GameState struct {
-	MatchID    string
-	PlayerAID  string
-	PlayerBID  string
-	Boards     map[string]game.Board
-	Ready      map[string]bool
-	Turn       Side
-	mu         sync.Mutex
-	ShipCells  map[string]map[string]map[string]bool
-	ShipHealth map[string]map[string]int
+	MatchID   string
+	PlayerAID string
+	PlayerBID string
+	CreatedAt time.Time
+	// FirstID is the player who shoots first.
+	FirstID string
+	Game    *game.Game
+	mu      sync.Mutex
+	clock   *turnClock
+	// graceTimers holds a pending forfeit per disconnected player.
+	graceTimers map[string]*time.Timer
+	spectators  map[string]*spectator
+	// eventSeq is the Seq of the last event logged for this match.
+	eventSeq int
+	// series is set when the match is a game of a series.
+	series *series
 }
 
 var (
@@ -27,28 +37,34 @@ var (
 	games   = make(map[string]*GameState)
 )
 
-func RegisterMatchState(m *Match) {
+func RegisterMatchState(m *Match) *GameState {
+	first := game.SideA
+	switch {
+	case m.FirstID == m.PlayerAID:
+	case m.FirstID == m.PlayerBID:
+		first = game.SideB
+	case rand.Intn(2) == 0:
+		first = game.SideB
+	}
 	g := &GameState{
 		MatchID:   m.ID,
 		PlayerAID: m.PlayerAID,
 		PlayerBID: m.PlayerBID,
-		Boards:    map[string]game.Board{},
-		Ready:     map[string]bool{m.PlayerAID: false, m.PlayerBID: false},
+		CreatedAt: m.CreatedAt,
+		FirstID:   m.PlayerAID,
+		Game:      game.New(first, m.Rules),
+		clock:     newTurnClock(DefaultClock),
 	}
-
-	g.ShipCells = make(map[string]map[string]map[string]bool)
-	g.ShipHealth = make(map[string]map[string]int)
-
-	g.ShipCells[m.PlayerAID] = make(map[string]map[string]bool)
-	g.ShipCells[m.PlayerBID] = make(map[string]map[string]bool)
-
-	g.ShipHealth[m.PlayerAID] = make(map[string]int)
-	g.ShipHealth[m.PlayerBID] = make(map[string]int)
+	if first == game.SideB {
+		g.FirstID = m.PlayerBID
+	}
+	g.persist()
 
 	gamesMu.Lock()
 	games[m.ID] = g
 	gamesMu.Unlock()
 	log.Println("RegisterMatchState: created game state for match", m.ID, "players:", m.PlayerAID, m.PlayerBID)
+	return g
 }
 
 func GetGameState(matchID string) (*GameState, bool) {
@@ -58,310 +74,323 @@ func GetGameState(matchID string) (*GameState, bool) {
 	return g, ok
 }
 
-func SetPlayerShips(matchID, playerID string, placements []ShipPlacement) error {
-	log.Println("SetPlayerShips called - match:", matchID, "player:", playerID, "placements:", len(placements))
-	g, ok := GetGameState(matchID)
-	if !ok {
-		log.Println("SetPlayerShips: match not found:", matchID)
-		return errors.New("match_not_found")
+// sideOf maps a player ID onto its engine side.
+func (g *GameState) sideOf(playerID string) (game.PlayerSide, bool) {
+	switch playerID {
+	case g.PlayerAID:
+		return game.SideA, true
+	case g.PlayerBID:
+		return game.SideB, true
 	}
-	log.Println("SetPlayerShips: match players registered:", "playerA:", g.PlayerAID, "playerB:", g.PlayerBID)
+	return game.SideA, false
+}
 
-	board, err := BuildBoardFromPlacements(placements)
-	if err != nil {
-		log.Println("SetPlayerShips: validation failed for player", playerID, "err:", err)
-		return err
+// playerOn returns the player ID seated on side.
+func (g *GameState) playerOn(side game.PlayerSide) string {
+	if side == game.SideA {
+		return g.PlayerAID
 	}
+	return g.PlayerBID
+}
 
-	if g.ShipCells[playerID] == nil {
-		g.ShipCells[playerID] = make(map[string]map[string]bool)
-	}
-	if g.ShipHealth[playerID] == nil {
-		g.ShipHealth[playerID] = make(map[string]int)
+func wireSide(s game.PlayerSide) Side {
+	if s == game.SideA {
+		return SideA
 	}
+	return SideB
+}
 
-	for _, p := range placements {
-		shipType := p.Type
-		size, ok := ShipSizes[shipType]
-		if !ok {
-			log.Println("SetPlayerShips: unknown ship type (shouldn't happen):", shipType)
-			continue
-		}
-
-		g.mu.Lock()
-		if g.ShipCells[playerID][shipType] == nil {
-			g.ShipCells[playerID][shipType] = make(map[string]bool)
-		}
-		g.mu.Unlock()
-
-		for i := 0; i < size; i++ {
-			rx := p.X
-			ry := p.Y
-			if p.Dir == "H" || p.Dir == "h" {
-				rx = p.X + i
-				ry = p.Y
-			} else {
-				rx = p.X
-				ry = p.Y + i
-			}
-			if rx < 0 || ry < 0 || rx > 9 || ry > 9 {
-				continue
-			}
-			key := fmt.Sprintf("%d_%d", rx, ry)
-
-			g.mu.Lock()
-			g.ShipCells[playerID][shipType][key] = true
-			g.mu.Unlock()
-		}
-
-		g.mu.Lock()
-		g.ShipHealth[playerID][shipType] = size
-		g.mu.Unlock()
-
-		log.Println("SetPlayerShips: populated ship", shipType, "for player", playerID, "size:", size)
+func SetPlayerShips(matchID, playerID string, placements []game.Placement) error {
+	log.Println("SetPlayerShips called - match:", matchID, "player:", playerID, "placements:", len(placements))
+	g, err := lookupMatch(matchID)
+	if err != nil {
+		log.Println("SetPlayerShips: match not available:", matchID, err)
+		return err
+	}
+	side, ok := g.sideOf(playerID)
+	if !ok {
+		return errors.New("unknown_player")
 	}
 
 	g.mu.Lock()
-	log.Println("DEBUG ShipHealth for player", playerID, ":", g.ShipHealth[playerID])
-	g.mu.Unlock()
-	g.mu.Lock()
-	g.Boards[playerID] = board
-	g.Ready[playerID] = true
-	readyA := g.Ready[g.PlayerAID]
-	readyB := g.Ready[g.PlayerBID]
+	res, err := g.Game.PlaceFleet(side, placements)
+	if err == nil {
+		g.logEvent("fleet_placed", eventFleetPlaced{PlayerID: playerID, Ships: placements})
+		g.persist()
+	}
 	g.mu.Unlock()
-	log.Println("SetPlayerShips: stored board for player", playerID, "readyA:", readyA, "readyB:", readyB)
+	if err != nil {
+		log.Println("SetPlayerShips: validation failed for player", playerID, "err:", err)
+		return err
+	}
+	log.Println("SetPlayerShips: stored board for player", playerID, "started:", res.Started)
 
-	if readyA && readyB {
+	if res.Started {
 		log.Println("SetPlayerShips: both players ready for match", matchID)
-		if rng != nil {
-			if rng.Intn(2) == 0 {
-				g.Turn = SideA
-			} else {
-				g.Turn = SideB
-			}
-		} else {
-			g.Turn = SideA
-		}
 
+		salvo := 0
+		if g.Game.Rules().Mode == game.ModeSalvo {
+			g.mu.Lock()
+			salvo = g.Game.SalvoSize(res.Turn)
+			g.mu.Unlock()
+		}
 		notify := func(pID, opponentID string, yourSide Side) {
-			msg := map[string]interface{}{
-				"type":        "all_ships_ready",
-				"match_id":    matchID,
-				"start_turn":  string(g.Turn),
-				"your_side":   string(yourSide),
-				"opponent_id": opponentID,
-			}
-			b, _ := json.Marshal(msg)
 			if pl, ok := GetPlayer(pID); ok {
-				pl.send <- b
+				pl.sendMsg(protocol.AllShipsReady{
+					MatchID:    matchID,
+					StartTurn:  string(wireSide(res.Turn)),
+					YourSide:   string(yourSide),
+					OpponentID: opponentID,
+					SalvoSize:  salvo,
+				})
 			}
 		}
 
-		notify(g.PlayerAID, g.PlayerBID, assignSideForPlayer(g, g.PlayerAID))
-		notify(g.PlayerBID, g.PlayerAID, assignSideForPlayer(g, g.PlayerBID))
+		notify(g.PlayerAID, g.PlayerBID, SideA)
+		notify(g.PlayerBID, g.PlayerAID, SideB)
+
+		g.mu.Lock()
+		g.castState()
+		g.clock.start(g)
+		g.mu.Unlock()
 	}
 
 	return nil
 }
 
-func assignSideForPlayer(g *GameState, playerID string) Side {
-	if playerID == g.PlayerAID {
-		return SideA
-	}
-	return SideB
-}
-
-func ProcessShot(matchID, shooterID string, x, y int) (map[string]interface{}, error) {
+func ProcessShot(matchID, shooterID string, x, y int) (protocol.ShotResult, error) {
 	log.Println("ProcessShot: ENTER match", matchID, "shooter", shooterID, "x", x, "y", y)
-	g, ok := GetGameState(matchID)
-	if !ok {
-		log.Println("ProcessShot: match_not_found")
-		return nil, errors.New("match_not_found")
+	g, err := lookupMatch(matchID)
+	if err != nil {
+		log.Println("ProcessShot:", err)
+		return protocol.ShotResult{}, err
 	}
 
-	if x < 0 || x > 9 || y < 0 || y > 9 {
-		log.Println("ProcessShot: out_of_bounds")
-		return nil, errors.New("out_of_bounds")
+	shooterSide, ok := g.sideOf(shooterID)
+	if !ok {
+		return protocol.ShotResult{}, errors.New("unknown_player")
 	}
 
 	g.mu.Lock()
 	defer g.mu.Unlock()
 
-	if _, ok := g.Boards[g.PlayerAID]; !ok {
-		return nil, errors.New("playerA_board_missing")
+	return g.fire(shooterID, shooterSide, x, y)
+}
+
+// fire resolves one shot and notifies both players. Callers hold g.mu.
+func (g *GameState) fire(shooterID string, shooterSide game.PlayerSide, x, y int) (protocol.ShotResult, error) {
+	shot, err := g.Game.Fire(shooterSide, x, y)
+	if err != nil {
+		log.Println("ProcessShot: rejected:", err)
+		return protocol.ShotResult{}, err
 	}
-	if _, ok := g.Boards[g.PlayerBID]; !ok {
-		return nil, errors.New("playerB_board_missing")
+	g.logEvent("shot", eventShot{
+		PlayerID: shooterID,
+		X:        x,
+		Y:        y,
+		Hit:      shot.Hit,
+		Sunk:     shot.Sunk,
+		GameOver: shot.GameOver,
+	})
+	g.persist()
+	oppID := g.playerOn(shooterSide.Opponent())
+
+	result := protocol.ShotResult{
+		MatchID:   g.MatchID,
+		X:         x,
+		Y:         y,
+		ShooterID: shooterID,
+		TargetID:  oppID,
+		Hit:       shot.Hit,
+		Message:   "miss",
+		GameOver:  shot.GameOver,
+	}
+	if shot.Hit {
+		result.Message = "hit"
+	}
+	if shot.GameOver {
+		result.WinnerID = g.playerOn(shot.Winner)
+	} else {
+		result.NextTurn = string(wireSide(shot.NextTurn))
+	}
+	log.Println("ProcessShot: match", g.MatchID, "shooter", shooterID, "hit", shot.Hit, "next turn", shot.NextTurn)
+
+	g.broadcast(result)
+	g.cast(result)
+
+	if shot.Sunk != "" {
+		sunk := protocol.ShipSunk{
+			MatchID:  g.MatchID,
+			ShipType: shot.Sunk,
+			OwnerID:  oppID,
+			ByID:     shooterID,
+			Cells:    shot.SunkCells,
+			Water:    shot.Water,
+		}
+		g.logEvent("ship_sunk", eventShipSunk{
+			OwnerID:  oppID,
+			ByID:     shooterID,
+			ShipType: shot.Sunk,
+			Cells:    shot.SunkCells,
+			Water:    shot.Water,
+		})
+		g.announceSunk(sunk)
+		log.Println("ship_sunk emitted:", shot.Sunk, "for match", g.MatchID, "owner", oppID)
 	}
 
-	var shooterSide Side
-	if shooterID == g.PlayerAID {
-		shooterSide = SideA
-	} else if shooterID == g.PlayerBID {
-		shooterSide = SideB
+	if shot.GameOver {
+		g.endMatch("all_ships_sunk")
 	} else {
-		return nil, errors.New("unknown_player")
+		g.clock.shotTaken(g)
 	}
 
-	if g.Turn != shooterSide {
-		log.Println("ProcessShot: not_your_turn. Turn:", g.Turn, "Shooter:", shooterSide)
-		return nil, errors.New("not_your_turn")
+	return result, nil
+}
+
+func ProcessSalvo(matchID, shooterID string, shots []game.Coord) (protocol.SalvoResult, error) {
+	g, err := lookupMatch(matchID)
+	if err != nil {
+		log.Println("ProcessSalvo:", err)
+		return protocol.SalvoResult{}, err
+	}
+	side, ok := g.sideOf(shooterID)
+	if !ok {
+		return protocol.SalvoResult{}, errors.New("unknown_player")
 	}
 
-	var oppID string
-	if shooterID == g.PlayerAID {
-		oppID = g.PlayerBID
-	} else {
-		oppID = g.PlayerAID
-	}
-
-	board := g.Boards[oppID]
-
-	cell := board[y][x]
-	hit := false
-	var sunkShip string
-
-	result := map[string]interface{}{
-		"type":       "shot_result",
-		"match_id":   matchID,
-		"x":          x,
-		"y":          y,
-		"shooter_id": shooterID,
-		"hit":        hit,
-	}
-
-	log.Println("ProcessShot: match", matchID, "shooter", shooterID, "x", x, "y", y, "cell", cell)
-
-	switch cell {
-	case game.Ship:
-		board[y][x] = game.Hit
-		result["hit"] = true
-		result["message"] = "hit"
-		hit = true
-		log.Println("ProcessShot: HIT!")
-
-		key := fmt.Sprintf("%d_%d", x, y)
-		if playerShips, ok := g.ShipCells[oppID]; ok {
-			for shipType, cells := range playerShips {
-				if cells == nil {
-					continue
-				}
-				if cells[key] {
-
-					remaining, has := g.ShipHealth[oppID][shipType]
-					if !has {
-						remaining = 0
-						for _ = range cells {
-							remaining++
-						}
-					}
-					if remaining > 0 {
-						g.ShipHealth[oppID][shipType] = remaining - 1
-						log.Println("Ship hit:", shipType, "owner:", oppID, "remaining:", g.ShipHealth[oppID][shipType])
-						if g.ShipHealth[oppID][shipType] == 0 {
-
-							g.ShipHealth[oppID][shipType] = -1
-							sunkShip = shipType
-						}
-					}
-					break
-				}
-			}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.fireSalvo(shooterID, side, shots)
+}
+
+// fireSalvo resolves a volley, then reports it as one salvo_result plus a
+// ship_sunk per sunk ship. Callers hold g.mu.
+func (g *GameState) fireSalvo(shooterID string, side game.PlayerSide, shots []game.Coord) (protocol.SalvoResult, error) {
+	volley, err := g.Game.FireSalvo(side, shots)
+	if err != nil {
+		log.Println("ProcessSalvo: rejected:", err)
+		return protocol.SalvoResult{}, err
+	}
+	oppID := g.playerOn(side.Opponent())
+
+	result := protocol.SalvoResult{
+		MatchID:   g.MatchID,
+		ShooterID: shooterID,
+		TargetID:  oppID,
+		Shots:     make([]protocol.SalvoShot, 0, len(volley.Shots)),
+		GameOver:  volley.GameOver,
+	}
+	fired := make([]game.Coord, 0, len(volley.Shots))
+	for _, s := range volley.Shots {
+		result.Shots = append(result.Shots, protocol.SalvoShot{X: s.X, Y: s.Y, Hit: s.Hit, Sunk: s.Sunk})
+		fired = append(fired, game.Coord{X: s.X, Y: s.Y})
+		if s.Hit {
+			result.Hits++
 		}
+	}
+	if volley.GameOver {
+		result.WinnerID = g.playerOn(volley.Winner)
+	} else {
+		result.NextTurn = string(wireSide(volley.NextTurn))
+		result.NextSalvo = g.Game.SalvoSize(volley.NextTurn)
+	}
+	g.logEvent("salvo", eventSalvo{PlayerID: shooterID, Shots: fired, Hits: result.Hits, GameOver: volley.GameOver})
+	g.persist()
+	log.Println("ProcessSalvo: match", g.MatchID, "shooter", shooterID, "shots", len(fired), "hits", result.Hits)
+
+	public := result
+	public.Shots = make([]protocol.SalvoShot, len(result.Shots))
+	for i, s := range result.Shots {
+		s.Sunk = g.publicShipType(s.Sunk)
+		public.Shots[i] = s
+	}
+	if pl, ok := GetPlayer(oppID); ok {
+		pl.sendMsg(result)
+	}
+	if pl, ok := GetPlayer(shooterID); ok {
+		pl.sendMsg(public)
+	}
+	g.cast(public)
 
-	case game.Empty:
-		board[y][x] = game.Miss
-		result["hit"] = false
-		result["message"] = "miss"
-		log.Println("ProcessShot: MISS!")
-	case game.Hit, game.Miss:
-		return nil, errors.New("already_shot")
-	default:
-		board[y][x] = game.Miss
-		result["hit"] = false
-		result["message"] = "miss"
-		log.Println("ProcessShot: MISS (default)!")
-	}
-
-	g.Boards[oppID] = board
-
-	oppShipsRemain := false
-	for ry := 0; ry < 10; ry++ {
-		for rx := 0; rx < 10; rx++ {
-			if g.Boards[oppID][ry][rx] == game.Ship {
-				oppShipsRemain = true
-				break
-			}
+	for _, s := range volley.Shots {
+		if s.Sunk == "" {
+			continue
 		}
-		if oppShipsRemain {
-			break
+		sunk := protocol.ShipSunk{
+			MatchID:  g.MatchID,
+			ShipType: s.Sunk,
+			OwnerID:  oppID,
+			ByID:     shooterID,
+			Cells:    s.SunkCells,
+			Water:    s.Water,
 		}
+		g.logEvent("ship_sunk", eventShipSunk{
+			OwnerID:  oppID,
+			ByID:     shooterID,
+			ShipType: s.Sunk,
+			Cells:    s.SunkCells,
+			Water:    s.Water,
+		})
+		g.announceSunk(sunk)
 	}
 
-	if !oppShipsRemain {
-		result["game_over"] = true
-		result["winner_id"] = shooterID
+	if volley.GameOver {
+		g.endMatch("all_ships_sunk")
 	} else {
+		g.clock.shotTaken(g)
+	}
+	return result, nil
+}
 
-		if !hit {
-			log.Println("ProcessShot: Switching turn. Current:", g.Turn)
-			if g.Turn == SideA {
-				g.Turn = SideB
-			} else {
-				g.Turn = SideA
-			}
-			log.Println("ProcessShot: New turn:", g.Turn)
-		} else {
-			log.Println("ProcessShot: Hit! Keeping turn:", g.Turn)
-		}
-		result["game_over"] = false
-		result["next_turn"] = string(g.Turn)
+// randomVolley picks a full volley of open cells for side.
+func (g *GameState) randomVolley(side game.PlayerSide) []game.Coord {
+	cells := g.Game.OpenCells(side.Opponent())
+	rand.Shuffle(len(cells), func(i, j int) { cells[i], cells[j] = cells[j], cells[i] })
+	n := g.Game.SalvoSize(side)
+	if n > len(cells) {
+		n = len(cells)
 	}
+	return cells[:n]
+}
 
-	result["target_id"] = oppID
+// hiddenShip stands in for a sunk ship's type when the rules do not
+// announce sinkings.
+const hiddenShip = "unknown"
 
-	b, _ := json.Marshal(result)
-	if pl, ok := GetPlayer(shooterID); ok {
-		select {
-		case pl.send <- b:
-		default:
-			log.Println("ProcessShot: shooter send blocked", shooterID)
-		}
+// publicShipType is what anyone but the owner may learn about a sunk ship:
+// its type, or hiddenShip. Callers hold g.mu.
+func (g *GameState) publicShipType(shipType string) string {
+	if shipType == "" || g.Game.Rules().AnnounceSunk {
+		return shipType
 	}
-	if pl, ok := GetPlayer(oppID); ok {
-		select {
-		case pl.send <- b:
-		default:
-			log.Println("ProcessShot: opponent send blocked", oppID)
-		}
+	return hiddenShip
+}
+
+// announceSunk reports a sinking. The owner always learns the ship type;
+// the shooter and spectators only when the rules announce it, and
+// otherwise not the cells either, since a ship's length gives its type.
+// Callers hold g.mu.
+func (g *GameState) announceSunk(m protocol.ShipSunk) {
+	public := m
+	public.ShipType = g.publicShipType(m.ShipType)
+	if public.ShipType != m.ShipType {
+		public.Cells = nil
+	}
+	if pl, ok := GetPlayer(m.OwnerID); ok {
+		pl.sendMsg(m)
+	}
+	if pl, ok := GetPlayer(m.ByID); ok {
+		pl.sendMsg(public)
 	}
+	g.cast(public)
+}
 
-	if sunkShip != "" {
-		payload := map[string]interface{}{
-			"type":      "ship_sunk",
-			"match_id":  matchID,
-			"ship_type": sunkShip,
-			"owner_id":  oppID,
-			"by_id":     shooterID,
-		}
-		pb, _ := json.Marshal(payload)
-		if pl, ok := GetPlayer(shooterID); ok {
-			select {
-			case pl.send <- pb:
-			default:
-				log.Println("ProcessShot: shooter ship_sunk send blocked", shooterID)
-			}
+// broadcast sends m to both seated players that are still registered.
+func (g *GameState) broadcast(m protocol.Message) {
+	for _, id := range []string{g.PlayerAID, g.PlayerBID} {
+		if pl, ok := GetPlayer(id); ok {
+			pl.sendMsg(m)
 		}
-		if pl, ok := GetPlayer(oppID); ok {
-			select {
-			case pl.send <- pb:
-			default:
-				log.Println("ProcessShot: opponent ship_sunk send blocked", oppID)
-			}
-		}
-		log.Println("ship_sunk emitted:", sunkShip, "for match", matchID, "owner", oppID)
 	}
-
-	return result, nil
 }
@@ -0,0 +1,97 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+
+	"github.com/google/uuid"
+)
+
+// testPlayer registers a player without a connection; what it is sent
+// piles up in its send channel.
+func testPlayer(t *testing.T) *Player {
+	t.Helper()
+	p := newPlayer(uuid.NewString())
+	p.Name = "t" + p.ID[:8]
+	RegisterPlayer(p)
+	t.Cleanup(func() { UnregisterPlayer(p.ID) })
+	return p
+}
+
+// sent drains p's queued messages of type typ.
+func sent(p *Player, typ string) []json.RawMessage {
+	var out []json.RawMessage
+	for {
+		select {
+		case b := <-p.send:
+			var env struct {
+				Type string `json:"type"`
+			}
+			json.Unmarshal(b, &env)
+			if env.Type == typ {
+				out = append(out, b)
+			}
+		default:
+			return out
+		}
+	}
+}
+
+// testFleet lays the default fleet out on rows 0, 2, 4, 6 and 8.
+func testFleet() []game.Placement {
+	var out []game.Placement
+	for i, spec := range game.DefaultFleet {
+		out = append(out, game.Placement{Type: spec.Type, X: 0, Y: 2 * i, Dir: "H"})
+	}
+	return out
+}
+
+// testBattle starts a match between two fresh players under rules, with
+// both fleets placed and a to shoot first.
+func testBattle(t *testing.T, rules game.RuleSet) (a, b *Player, g *GameState) {
+	t.Helper()
+	a, b = testPlayer(t), testPlayer(t)
+	g = startMatch(a, b, matchOptions{FirstID: a.ID, Rules: rules})
+	for _, p := range []*Player{a, b} {
+		if err := SetPlayerShips(g.MatchID, p.ID, testFleet()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a, b, g
+}
+
+func TestUnannouncedSinkingHidesCells(t *testing.T) {
+	for _, announce := range []bool{true, false} {
+		rules := game.DefaultRules()
+		rules.AnnounceSunk = announce
+		a, b, g := testBattle(t, rules)
+		// the destroyer is the last ship, on row 8
+		for x := 0; x < 2; x++ {
+			if _, err := ProcessShot(g.MatchID, a.ID, x, 8); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, c := range []struct {
+			p     *Player
+			typ   string
+			cells int
+		}{
+			{a, map[bool]string{true: "destroyer", false: hiddenShip}[announce], map[bool]int{true: 2, false: 0}[announce]},
+			{b, "destroyer", 2},
+		} {
+			msgs := sent(c.p, "ship_sunk")
+			if len(msgs) != 1 {
+				t.Fatalf("announce %v: %d ship_sunk messages", announce, len(msgs))
+			}
+			var m protocol.ShipSunk
+			json.Unmarshal(msgs[0], &m)
+			if m.ShipType != c.typ || len(m.Cells) != c.cells {
+				t.Errorf("announce %v, owner %v: got %q with %d cells, want %q with %d",
+					announce, c.p == b, m.ShipType, len(m.Cells), c.typ, c.cells)
+			}
+		}
+	}
+}
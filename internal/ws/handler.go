@@ -1,29 +1,71 @@
 package ws
 
 import (
+	"log"
 	"net/http"
 
+	"battleship-go/internal/protocol"
+
 	"github.com/google/uuid"
 )
 
 func HandleWS(w http.ResponseWriter, r *http.Request) {
+	// a logged-in socket plays as its account; otherwise a valid resume
+	// token reattaches it to an existing guest identity. Accounts need
+	// their session, so logging out also ends resuming.
+	var id, username string
+	resumed := false
+	if acct, ok := authenticate(r); ok {
+		id, username = acct.ID, acct.Username
+		_, resumed = GetPlayer(id)
+		resumed = resumed || len(matchesFor(id)) > 0
+	} else if token := r.URL.Query().Get("resume"); token != "" {
+		rid, err := verifyResumeToken(token)
+		switch {
+		case err != nil:
+			log.Println("HandleWS: rejecting resume token:", err)
+		case accountName(rid) != "":
+			log.Println("HandleWS: rejecting resume token of account", rid, "without a session")
+		default:
+			id, resumed = rid, true
+		}
+	}
+	if username == "" && !AllowGuests {
+		http.Error(w, "login_required", http.StatusUnauthorized)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		return
 	}
-
-	p := &Player{
-		ID:   uuid.NewString(),
-		Name: "",
-		conn: conn,
-		send: make(chan []byte, 256),
+	var p *Player
+	if id != "" {
+		p = resumePlayer(id)
+	} else {
+		p = newPlayer(uuid.NewString())
+		RegisterPlayer(p)
+	}
+	if username != "" {
+		p.account, p.Name = username, username
 	}
 
-	go p.writePump()
-	go p.readPump()
+	welcome, _ := protocol.Encode(protocol.Welcome{
+		ID:                 p.ID,
+		Name:               p.Name,
+		Account:            username,
+		Resumed:            resumed,
+		ResumeToken:        issueResumeToken(p.ID),
+		ProtocolVersion:    protocol.Version,
+		MinProtocolVersion: protocol.MinVersion,
+	})
+	done := p.attach(conn, welcome)
 
-	RegisterPlayer(p)
+	go p.writePump(conn, done)
+	go p.readPump(conn)
 
-	welcome := `{"type":"welcome","id":"` + p.ID + `"}`
-	p.send <- []byte(welcome)
+	if resumed {
+		playerReconnected(p)
+		sendResync(p)
+	}
 }
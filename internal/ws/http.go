@@ -3,6 +3,11 @@ package ws
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/store"
 )
 
 func ListPlayersHandler(w http.ResponseWriter, r *http.Request) {
@@ -23,33 +28,39 @@ func ListPlayersHandler(w http.ResponseWriter, r *http.Request) {
 
 func ListGamesHandler(w http.ResponseWriter, r *http.Request) {
 	type gameSummary struct {
-		MatchID   string   `json:"match_id"`
-		PlayerAID string   `json:"playerA_id"`
-		PlayerBID string   `json:"playerB_id"`
-		ReadyA    bool     `json:"readyA"`
-		ReadyB    bool     `json:"readyB"`
-		Turn      string   `json:"turn"`
-		Players   []string `json:"players"`
+		MatchID    string   `json:"match_id"`
+		PlayerAID  string   `json:"playerA_id"`
+		PlayerBID  string   `json:"playerB_id"`
+		ReadyA     bool     `json:"readyA"`
+		ReadyB     bool     `json:"readyB"`
+		Turn       string   `json:"turn"`
+		State      string   `json:"state"`
+		Players    []string `json:"players"`
+		Spectators int      `json:"spectators"`
 	}
 
 	gamesMu.RLock()
 	out := make([]gameSummary, 0, len(games))
 	for id, g := range games {
 		g.mu.Lock()
-		readyA := g.Ready[g.PlayerAID]
-		readyB := g.Ready[g.PlayerBID]
-		turn := string(g.Turn)
+		readyA := g.Game.Ready(game.SideA)
+		readyB := g.Game.Ready(game.SideB)
+		turn := string(wireSide(g.Game.Turn()))
+		state := g.Game.State().String()
 		players := []string{g.PlayerAID, g.PlayerBID}
+		spectators := len(g.spectators)
 		g.mu.Unlock()
 
 		out = append(out, gameSummary{
-			MatchID:   id,
-			PlayerAID: g.PlayerAID,
-			PlayerBID: g.PlayerBID,
-			ReadyA:    readyA,
-			ReadyB:    readyB,
-			Turn:      turn,
-			Players:   players,
+			MatchID:    id,
+			PlayerAID:  g.PlayerAID,
+			PlayerBID:  g.PlayerBID,
+			ReadyA:     readyA,
+			ReadyB:     readyB,
+			Turn:       turn,
+			State:      state,
+			Players:    players,
+			Spectators: spectators,
 		})
 	}
 	gamesMu.RUnlock()
@@ -64,3 +75,175 @@ func ListGamesHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 	w.Write(b)
 }
+
+// ProtocolSchemaHandler serves the JSON Schema of the wire protocol. An
+// optional ?direction=inbound|outbound narrows it to one side.
+func ProtocolSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	dir := protocol.Direction(r.URL.Query().Get("direction"))
+	if dir != protocol.Both && dir != protocol.ClientToServer && dir != protocol.ServerToClient {
+		http.Error(w, "bad_direction", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	b, err := json.MarshalIndent(protocol.Schema(dir), "", "  ")
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// ReplayHandler serves the ordered event log of a finished match at
+// /api/games/{id}/replay. Live matches are refused since their log holds
+// both fleets.
+func ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	matchID := r.PathValue("id")
+	rec, ok, err := matchStore.LoadArchived(matchID)
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		if _, live := GetGameState(matchID); live {
+			http.Error(w, "match_in_progress", http.StatusConflict)
+			return
+		}
+		http.Error(w, "match_not_found", http.StatusNotFound)
+		return
+	}
+	events, err := matchStore.LoadEvents(matchID)
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+
+	out := struct {
+		MatchID   string             `json:"match_id"`
+		PlayerAID string             `json:"playerA_id"`
+		PlayerBID string             `json:"playerB_id"`
+		Result    *store.MatchResult `json:"result"`
+		Events    []store.Event      `json:"events"`
+	}{rec.MatchID, rec.PlayerAID, rec.PlayerBID, rec.Result, events}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	b, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// RandomFleetHandler generates a random fleet. The rule set comes from a
+// POST body, or the classic rules for GET; seed, spacing and avoid_edges
+// query parameters tune the result.
+func RandomFleetHandler(w http.ResponseWriter, r *http.Request) {
+	rules := game.DefaultRules()
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&rules); err != nil {
+			http.Error(w, "bad_payload", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "method_not_allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := rules.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	q := r.URL.Query()
+	c := game.FleetConstraints{Spacing: game.Spacing(q.Get("spacing")), AvoidEdges: q.Get("avoid_edges") == "true"}
+	var seed *int64
+	if v := q.Get("seed"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, "bad_seed", http.StatusBadRequest)
+			return
+		}
+		seed = &n
+	}
+	used, ships, err := randomFleet(rules, seed, c)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	out := struct {
+		Seed  int64            `json:"seed"`
+		Rules game.RuleSet     `json:"rules"`
+		Ships []game.Placement `json:"ships"`
+	}{used, rules, ships}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	b, err := json.Marshal(out)
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// ProfileHandler serves a player's stored profile.
+func ProfileHandler(w http.ResponseWriter, r *http.Request) {
+	prof, ok, err := matchStore.LoadProfile(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		http.Error(w, "profile_not_found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	b, err := json.Marshal(prof)
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+func ListTournamentsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	b, err := json.Marshal(listTournaments())
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
+
+// TournamentHandler serves the bracket, pairings and standings of one
+// tournament.
+func TournamentHandler(w http.ResponseWriter, r *http.Request) {
+	info, ok := tournamentInfo(r.PathValue("id"))
+	if !ok {
+		http.Error(w, "tournament_not_found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	b, err := json.Marshal(info)
+	if err != nil {
+		http.Error(w, "internal_error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(b)
+}
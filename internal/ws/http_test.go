@@ -0,0 +1,31 @@
+package ws
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRandomFleetHandler(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		status int
+	}{
+		{"classic rules", http.MethodGet, "", http.StatusOK},
+		{"posted rules", http.MethodPost, `{"width":10,"height":10,"fleet":[{"type":"boat","size":2,"count":1}]}`, http.StatusOK},
+		{"malformed", http.MethodPost, "{", http.StatusBadRequest},
+		{"oversized", http.MethodPost, `{"name":"` + strings.Repeat("x", 8192) + `"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			RandomFleetHandler(w, httptest.NewRequest(tt.method, "/api/random-fleet?seed=1", strings.NewReader(tt.body)))
+			if w.Code != tt.status {
+				t.Errorf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+			}
+		})
+	}
+}
@@ -1,6 +1,10 @@
 package ws
 
-import "sync"
+import (
+	"sync"
+
+	"battleship-go/internal/protocol"
+)
 
 var (
 	playersMu sync.RWMutex
@@ -14,15 +18,24 @@ func RegisterPlayer(p *Player) {
 	playersMu.Unlock()
 }
 
-// UnregisterPlayer removes the player and closes the send channel.
+// UnregisterPlayer removes the player. Any attached connection is dropped;
+// the send channel is left open so late senders never panic.
 func UnregisterPlayer(id string) {
 	playersMu.Lock()
-	if p, ok := players[id]; ok {
-		// close send to signal writePump to exit
-		close(p.send)
+	p, ok := players[id]
+	if ok {
 		delete(players, id)
 	}
 	playersMu.Unlock()
+	if ok {
+		ChatLimiter.Forget(id)
+		p.mu.Lock()
+		if p.conn != nil {
+			close(p.done)
+			p.conn = nil
+		}
+		p.mu.Unlock()
+	}
 }
 
 // GetPlayer returns the player by id (nil,false) if not found.
@@ -33,16 +46,25 @@ func GetPlayer(id string) (*Player, bool) {
 	return p, ok
 }
 
-// ListPlayers makes a lightweight snapshot of connected players (id + name).
-func ListPlayers() []map[string]string {
+// ListPlayers makes a lightweight snapshot of the lobby (id, name, status
+// and rating, or bot difficulty). Humans who have not joined yet or are
+// waiting to resume a dropped session are left out.
+func ListPlayers() []protocol.LobbyPlayer {
+	// statuses look at matches, whose locks are taken before playersMu
+	// elsewhere, so work from a copy
 	playersMu.RLock()
-	defer playersMu.RUnlock()
-	out := make([]map[string]string, 0, len(players))
+	list := make([]*Player, 0, len(players))
 	for _, p := range players {
-		out = append(out, map[string]string{
-			"id":   p.ID,
-			"name": p.Name,
-		})
+		list = append(list, p)
+	}
+	playersMu.RUnlock()
+
+	out := make([]protocol.LobbyPlayer, 0, len(list))
+	for _, p := range list {
+		if p.bot == "" && !(p.Connected() && p.inLobby()) {
+			continue
+		}
+		out = append(out, lobbyEntry(p))
 	}
 	return out
 }
@@ -0,0 +1,177 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/store"
+)
+
+// FinishedLinger keeps a finished match in memory briefly after it has been
+// archived, so late messages get a phase error and clients can resync.
+var FinishedLinger = 30 * time.Second
+
+// seatMu makes checking that two players are free and seating them in a
+// new match one step, so two invitations accepted at once cannot put a
+// player in two matches. It is taken after tournamentsMu and before the
+// series, match, queue, room, challenge and player locks.
+var seatMu sync.Mutex
+
+// lookupMatch finds a live match, telling evicted (archived) matches apart
+// from unknown IDs.
+func lookupMatch(matchID string) (*GameState, error) {
+	if g, ok := GetGameState(matchID); ok {
+		return g, nil
+	}
+	if rec, ok, _ := matchStore.LoadArchived(matchID); ok {
+		return nil, &game.PhaseError{Phase: rec.Game.State}
+	}
+	return nil, errors.New("match_not_found")
+}
+
+// matchOptions tweaks how startMatch sets up a match.
+type matchOptions struct {
+	// FirstID forces the first shooter.
+	FirstID string
+	// PreviousMatchID links a rematch to the match it follows.
+	PreviousMatchID string
+	// Rules selects the game mode.
+	Rules game.RuleSet
+	// series is the series the match is a game of.
+	series *series
+}
+
+// startMatch creates a match between a and b, tells both players and opens
+// ship placement.
+func startMatch(a, b *Player, opts matchOptions) *GameState {
+	// a match however started takes both players out of matchmaking and
+	// withdraws their other invites and rooms
+	for _, id := range []string{a.ID, b.ID} {
+		leaveQueue(id)
+		withdrawChallengesOf(id, cancelBusy)
+		closeRoomsOf(id, roomOwnerBusy)
+	}
+	m, assignment := createMatch(a.ID, b.ID)
+	m.FirstID = opts.FirstID
+	m.Rules = opts.Rules
+	g := RegisterMatchState(m)
+
+	score := map[string]int{a.ID: 0, b.ID: 0}
+	if opts.series != nil {
+		score, _ = opts.series.state()
+	}
+	g.mu.Lock()
+	g.series = opts.series
+	g.logEvent("match_start", eventMatchStart{
+		PlayerAID: g.PlayerAID,
+		PlayerBID: g.PlayerBID,
+		FirstID:   g.FirstID,
+		Rules:     g.Game.Rules(),
+	})
+	rules := g.Game.Rules()
+	firstID := g.FirstID
+	g.Game.OpenPlacement()
+	g.persist()
+	g.mu.Unlock()
+
+	start := protocol.MatchStart{
+		MatchID:         m.ID,
+		SeriesScore:     score,
+		PreviousMatchID: opts.PreviousMatchID,
+		Rules:           rules,
+	}
+	if s := opts.series; s != nil {
+		start.SeriesID, start.BestOf = s.ID, s.BestOf
+		start.Game = s.added(m.ID, firstID)
+	}
+	for _, pair := range [][2]*Player{{a, b}, {b, a}} {
+		msg := start
+		msg.YourSide = string(assignment[pair[0].ID])
+		msg.OpponentID, msg.OpponentName = pair[1].ID, pair[1].Name
+		pair[0].sendMsg(msg)
+	}
+	announceMatch(lobbyMatchStarted, m.ID, a.ID, b.ID)
+	return g
+}
+
+func copyScore(score map[string]int) map[string]int {
+	out := make(map[string]int, len(score))
+	for id, n := range score {
+		out[id] = n
+	}
+	return out
+}
+
+// endMatch announces the result once the engine has reached Finished or
+// Abandoned, archives the match and schedules its eviction. Callers hold
+// g.mu.
+func (g *GameState) endMatch(reason string) {
+	state := g.Game.State()
+	if !state.Over() {
+		return
+	}
+	g.clock.stop()
+	for id, t := range g.graceTimers {
+		t.Stop()
+		delete(g.graceTimers, id)
+	}
+
+	msg := protocol.MatchOver{
+		MatchID:    g.MatchID,
+		Reason:     reason,
+		DurationMs: time.Since(g.CreatedAt).Milliseconds(),
+		Stats: map[string]game.Stats{
+			g.PlayerAID: g.Game.Stats(game.SideA),
+			g.PlayerBID: g.Game.Stats(game.SideB),
+		},
+	}
+	// a match outside any series is a series of one
+	msg.SeriesScore = map[string]int{g.PlayerAID: 0, g.PlayerBID: 0}
+	if winner, ok := g.Game.Winner(); ok {
+		msg.WinnerID = g.playerOn(winner)
+		msg.LoserID = g.playerOn(winner.Opponent())
+		msg.SeriesScore[msg.WinnerID] = 1
+		msg.Ratings = rateMatch(msg.WinnerID, msg.LoserID)
+	}
+	if g.series != nil {
+		msg.SeriesScore = g.series.won(msg.WinnerID)
+		msg.SeriesID, msg.BestOf = g.series.ID, g.series.BestOf
+	}
+	g.logEvent("match_over", eventMatchOver{WinnerID: msg.WinnerID, Reason: reason})
+
+	rec := g.record()
+	rec.Result = &store.MatchResult{WinnerID: msg.WinnerID, Reason: reason, FinishedAt: time.Now()}
+	if err := matchStore.ArchiveMatch(rec); err != nil {
+		log.Println("endMatch: archiving", g.MatchID, "failed:", err)
+	}
+
+	g.broadcast(msg)
+	g.cast(msg)
+	// the game is decided, so every spectator may now see both fleets
+	g.castState()
+	log.Println("endMatch:", g.MatchID, "state", state, "winner", msg.WinnerID, "reason", reason)
+
+	matchID := g.MatchID
+	// statuses lock the match, which the caller holds
+	go announceMatch(lobbyMatchEnded, matchID, g.PlayerAID, g.PlayerBID)
+	go seriesMatchOver(matchID, msg.WinnerID, reason)
+	time.AfterFunc(FinishedLinger, func() { evictMatch(matchID) })
+}
+
+func evictMatch(matchID string) {
+	gamesMu.Lock()
+	g, ok := games[matchID]
+	delete(games, matchID)
+	gamesMu.Unlock()
+	if ok {
+		g.mu.Lock()
+		g.closeSpectators()
+		g.mu.Unlock()
+	}
+	dropChatHistory(matchID)
+	log.Println("evictMatch: dropped finished match", matchID)
+}
@@ -0,0 +1,94 @@
+package ws
+
+import "battleship-go/internal/protocol"
+
+// Lobby update events.
+const (
+	lobbySnapshot     = "snapshot"
+	lobbyJoined       = "joined"
+	lobbyLeft         = "left"
+	lobbyRenamed      = "renamed"
+	lobbyStatus       = "status"
+	lobbyMatchStarted = "match_started"
+	lobbyMatchEnded   = "match_ended"
+)
+
+// lobbyEntry describes p as the lobby list shows it.
+func lobbyEntry(p *Player) protocol.LobbyPlayer {
+	return protocol.LobbyPlayer{
+		ID:     p.ID,
+		Name:   p.Name,
+		Status: playerStatus(p),
+		Rating: playerRating(p),
+		Bot:    p.bot,
+		Guest:  p.bot == "" && p.account == "",
+	}
+}
+
+// castLobby sends a lobby update to every human in the lobby.
+func castLobby(m protocol.LobbyUpdate) {
+	for _, pl := range lobbyPlayers() {
+		pl.sendMsg(m)
+	}
+}
+
+// lobbyEntries looks up the current entries of ids, skipping players that
+// are gone.
+func lobbyEntries(ids ...string) []protocol.LobbyPlayer {
+	out := make([]protocol.LobbyPlayer, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := GetPlayer(id); ok {
+			out = append(out, lobbyEntry(p))
+		}
+	}
+	return out
+}
+
+// announceJoin lists p in every lobby, or reports a new name when p was
+// already listed, and sends p the whole list.
+func announceJoin(p *Player, renamed bool) {
+	p.mu.Lock()
+	listed := p.listed
+	p.listed = true
+	p.mu.Unlock()
+
+	switch {
+	case !listed:
+		castLobby(protocol.LobbyUpdate{Event: lobbyJoined, Players: []protocol.LobbyPlayer{lobbyEntry(p)}})
+	case renamed:
+		announceRename(p)
+	}
+	p.sendMsg(protocol.LobbyUpdate{Event: lobbySnapshot, Players: ListPlayers()})
+}
+
+// announceRename reports p's new name if it is listed.
+func announceRename(p *Player) {
+	p.mu.Lock()
+	listed := p.listed
+	p.mu.Unlock()
+	if listed {
+		castLobby(protocol.LobbyUpdate{Event: lobbyRenamed, Players: []protocol.LobbyPlayer{lobbyEntry(p)}})
+	}
+}
+
+// announceLeave drops p from every lobby once it loses its connection.
+func announceLeave(p *Player) {
+	p.mu.Lock()
+	listed := p.listed
+	p.listed = false
+	p.mu.Unlock()
+	if listed {
+		castLobby(protocol.LobbyUpdate{Event: lobbyLeft, Players: []protocol.LobbyPlayer{{ID: p.ID, Name: p.Name}}})
+	}
+}
+
+// announceStatus reports the current status of ids.
+func announceStatus(ids ...string) {
+	castLobby(protocol.LobbyUpdate{Event: lobbyStatus, Players: lobbyEntries(ids...)})
+}
+
+// announceMatch reports a match starting or ending with both players'
+// new entries. It locks the match, so callers must not hold g.mu.
+func announceMatch(event, matchID, aID, bID string) {
+	castLobby(protocol.LobbyUpdate{Event: event, MatchID: matchID, Players: lobbyEntries(aID, bID)})
+}
@@ -0,0 +1,114 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+// lobbyUpdates drains p's lobby updates that mention id.
+func lobbyUpdates(t *testing.T, p *Player, id string) []protocol.LobbyUpdate {
+	t.Helper()
+	var out []protocol.LobbyUpdate
+	for _, raw := range sent(p, "lobby_update") {
+		var m protocol.LobbyUpdate
+		if err := json.Unmarshal(raw, &m); err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range m.Players {
+			if e.ID == id {
+				out = append(out, m)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// updateEvents lists the event of each update.
+func updateEvents(ms []protocol.LobbyUpdate) []string {
+	out := make([]string, 0, len(ms))
+	for _, m := range ms {
+		out = append(out, m.Event)
+	}
+	return out
+}
+
+func TestLobbyJoinRenameLeave(t *testing.T) {
+	a := joined(testPlayer(t))
+	b := online(testPlayer(t))
+	stranger := online(testPlayer(t))
+
+	announceJoin(b, false)
+	if got := updateEvents(lobbyUpdates(t, a, b.ID)); len(got) != 1 || got[0] != lobbyJoined {
+		t.Errorf("a saw %v for b joining, want [joined]", got)
+	}
+	if got := lobbyUpdates(t, stranger, b.ID); len(got) != 0 {
+		t.Errorf("unjoined socket got %v", updateEvents(got))
+	}
+	if got := lobbyUpdates(t, b, a.ID); len(got) != 1 || got[0].Event != lobbySnapshot {
+		t.Fatalf("b got %v listing a, want one snapshot", updateEvents(got))
+	}
+	for _, e := range ListPlayers() {
+		if e.ID == stranger.ID {
+			t.Error("snapshot lists a socket that never joined")
+		}
+	}
+
+	// joining again without a new name says nothing
+	announceJoin(b, false)
+	if got := lobbyUpdates(t, a, b.ID); len(got) != 0 {
+		t.Errorf("a saw %v for a repeated join", updateEvents(got))
+	}
+	b.Name = "renamed " + b.ID[:4]
+	announceJoin(b, true)
+	got := lobbyUpdates(t, a, b.ID)
+	if len(got) != 1 || got[0].Event != lobbyRenamed || got[0].Players[0].Name != b.Name {
+		t.Errorf("a saw %v for b's rename, want [renamed]", updateEvents(got))
+	}
+
+	announceLeave(b)
+	if got := updateEvents(lobbyUpdates(t, a, b.ID)); len(got) != 1 || got[0] != lobbyLeft {
+		t.Errorf("a saw %v for b leaving, want [left]", got)
+	}
+	announceLeave(b)
+	if got := lobbyUpdates(t, a, b.ID); len(got) != 0 {
+		t.Errorf("a saw %v when b left twice", updateEvents(got))
+	}
+}
+
+func TestLobbyMatchStartAndEnd(t *testing.T) {
+	watcher := joined(testPlayer(t))
+	a, b, g := testBattle(t, game.DefaultRules())
+
+	got := lobbyUpdates(t, watcher, a.ID)
+	if len(got) != 1 || got[0].Event != lobbyMatchStarted || got[0].MatchID != g.MatchID {
+		t.Fatalf("watcher saw %v for the match starting, want [match_started]", updateEvents(got))
+	}
+	for _, e := range got[0].Players {
+		if e.Status != statusInMatch {
+			t.Errorf("%s is %q at match start, want %s", e.ID, e.Status, statusInMatch)
+		}
+	}
+
+	forfeitMatch(t, g, b)
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if got := lobbyUpdates(t, watcher, a.ID); len(got) > 0 {
+			if got[0].Event != lobbyMatchEnded || got[0].MatchID != g.MatchID {
+				t.Errorf("watcher saw %v for the match ending, want [match_ended]", updateEvents(got))
+			}
+			for _, e := range got[0].Players {
+				if e.Status != statusIdle {
+					t.Errorf("%s is %q after the match, want %s", e.ID, e.Status, statusIdle)
+				}
+			}
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("no match_ended update")
+}
@@ -4,6 +4,8 @@ import (
 	"math/rand"
 	"time"
 
+	"battleship-go/internal/game"
+
 	"github.com/google/uuid"
 )
 
@@ -21,10 +23,13 @@ type Match struct {
 	CreatedAt  time.Time
 	StartedAt  time.Time
 	AssignedAt time.Time
+	// FirstID, when set, is the player who shoots first; otherwise the
+	// first shooter is drawn at random.
+	FirstID string
+	// Rules are what the match is played under.
+	Rules game.RuleSet
 }
 
-var rng = rand.New(rand.NewSource(time.Now().UnixNano()))
-
 // createMatch creates a match with random side assignment and returns the match plus a mapping
 // telling for each player id which side they were assigned.
 func createMatch(aID, bID string) (*Match, map[string]Side) {
@@ -37,15 +42,16 @@ func createMatch(aID, bID string) (*Match, map[string]Side) {
 
 	// random assignment
 	var mapping map[string]Side = make(map[string]Side)
-	if rng.Intn(2) == 0 {
+	if rand.Intn(2) == 0 {
 
 		// a is A, b is B
 		mapping[aID] = SideA
 		mapping[bID] = SideB
 	} else {
-		// swap
+		// swap, keeping PlayerAID on side A
 		mapping[aID] = SideB
 		mapping[bID] = SideA
+		m.PlayerAID, m.PlayerBID = bID, aID
 	}
 
 	m.AssignedAt = time.Now()
@@ -0,0 +1,123 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	"battleship-go/internal/names"
+	"battleship-go/internal/protocol"
+)
+
+var (
+	errNameTaken      = errors.New("name_taken")
+	errNameNotAllowed = errors.New("name_not_allowed")
+	errNameFixed      = errors.New("account_name_fixed")
+	errNotJoined      = errors.New("not_joined")
+)
+
+// namesMu serialises name claims so two players cannot take the same name
+// at once. It is taken before playersMu.
+var namesMu sync.Mutex
+
+// nameTaken reports whether anyone but selfID uses name: a registered
+// player, including those waiting to resume, or an account's username.
+func nameTaken(name, selfID string) bool {
+	key := names.Key(name)
+	playersMu.RLock()
+	for _, pl := range players {
+		if pl.ID != selfID && names.Key(pl.Name) == key {
+			playersMu.RUnlock()
+			return true
+		}
+	}
+	playersMu.RUnlock()
+	acct, ok, _ := matchStore.FindAccount(name)
+	return ok && acct.ID != selfID
+}
+
+// checkName normalizes requested and applies the name policy and the chat
+// filter to it.
+func checkName(requested string) (string, error) {
+	name, err := names.Normalize(requested)
+	if err != nil {
+		return "", err
+	}
+	if clean, err := ChatFilter.Clean(name); err != nil || clean != name {
+		return name, errNameNotAllowed
+	}
+	return name, nil
+}
+
+// claimName normalizes requested and gives it to p. When the name is in
+// use it returns errNameTaken with a free alternative that passes the same
+// checks.
+func claimName(p *Player, requested string) (string, string, error) {
+	name, err := checkName(requested)
+	if err != nil {
+		return name, "", err
+	}
+
+	namesMu.Lock()
+	defer namesMu.Unlock()
+	if nameTaken(name, p.ID) {
+		unusable := func(n string) bool {
+			if checked, err := checkName(n); err != nil || checked != n {
+				return true
+			}
+			return nameTaken(n, p.ID)
+		}
+		return name, names.Suggest(name, unusable), errNameTaken
+	}
+	p.Name = name
+	return name, "", nil
+}
+
+// claimDefaultName gives a guest that joined without a name a generated
+// one, claimed like any other.
+func claimDefaultName(p *Player) {
+	_, suggestion, err := claimName(p, "Player-"+p.ID[:8])
+	if errors.Is(err, errNameTaken) && suggestion != "" {
+		_, _, err = claimName(p, suggestion)
+	}
+	if err != nil {
+		log.Println("claimDefaultName:", p.ID, "kept unnamed:", err)
+	}
+}
+
+func nameError(p *Player, req, name, suggestion string, err error) {
+	p.sendMsg(protocol.JoinError{
+		Error:      err.Error(),
+		For:        req,
+		Name:       name,
+		Suggestion: suggestion,
+		MinLength:  names.MinLength,
+		MaxLength:  names.MaxLength,
+	})
+}
+
+func handleRename(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.Rename)
+	p.mu.Lock()
+	listed := p.listed
+	p.mu.Unlock()
+	if !listed {
+		nameError(p, "rename", "", "", errNotJoined)
+		return
+	}
+	if p.account != "" {
+		nameError(p, "rename", p.Name, "", errNameFixed)
+		return
+	}
+	previous := p.Name
+	name, suggestion, err := claimName(p, m.Name)
+	if err != nil {
+		nameError(p, "rename", name, suggestion, err)
+		return
+	}
+	p.sendMsg(protocol.Renamed{ID: p.ID, Name: p.Name})
+	if p.Name != previous {
+		log.Println("handleRename:", p.ID, previous, "->", p.Name)
+		announceRename(p)
+	}
+}
@@ -0,0 +1,55 @@
+package ws
+
+import (
+	"testing"
+
+	"battleship-go/internal/chat"
+	"battleship-go/internal/names"
+)
+
+func TestClaimName(t *testing.T) {
+	defer func(f chat.Filter) { ChatFilter = f }(ChatFilter)
+	ChatFilter = chat.NewWordFilter("darn", "ann2")
+	holder := testPlayer(t)
+	holder.Name = "Ann"
+	long := testPlayer(t)
+	long.Name = "Abcdefghijklmnopqrst"
+
+	tests := []struct {
+		name       string
+		requested  string
+		want       string
+		suggestion string
+		err        error
+	}{
+		{"free", "  Bob   Smith ", "Bob Smith", "", nil},
+		{"taken in another case", "ANN", "ANN", "ANN3", errNameTaken},
+		{"suggestion skips filtered names", "ann", "ann", "ann3", errNameTaken},
+		{"suggestion stays within the limit", "abcdefghijklmnopqrst", "abcdefghijklmnopqrst", "abcdefghijklmnopqrs2", errNameTaken},
+		{"filtered", "darn", "darn", "", errNameNotAllowed},
+		{"reserved", "Ad_min", "", "", names.ErrReserved},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := testPlayer(t)
+			name, suggestion, err := claimName(p, tt.requested)
+			if name != tt.want || suggestion != tt.suggestion || err != tt.err {
+				t.Errorf("claimName(%q) = %q, %q, %v; want %q, %q, %v", tt.requested, name, suggestion, err, tt.want, tt.suggestion, tt.err)
+			}
+			if (err == nil) != (p.Name == tt.want) {
+				t.Errorf("player is named %q", p.Name)
+			}
+		})
+	}
+}
+
+func TestDefaultNameIsClaimed(t *testing.T) {
+	p := testPlayer(t)
+	squatter := testPlayer(t)
+	squatter.Name = "player-" + p.ID[:8]
+	p.Name = ""
+	claimDefaultName(p)
+	if want := "Player-" + p.ID[:8] + "2"; p.Name != want {
+		t.Errorf("default name = %q, want %q", p.Name, want)
+	}
+}
@@ -0,0 +1,171 @@
+package ws
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/store"
+)
+
+// matchStore receives every match transition. It defaults to memory so the
+// server works without any configuration.
+var matchStore store.Store = store.NewMemoryStore()
+
+// SetStore replaces the persistence backend. Call before serving.
+func SetStore(s store.Store) {
+	matchStore = s
+}
+
+func (g *GameState) record() store.MatchRecord {
+	var bots []string
+	for _, id := range []string{g.PlayerAID, g.PlayerBID} {
+		if p, ok := GetPlayer(id); ok && p.bot != "" {
+			bots = append(bots, id)
+		}
+	}
+	return store.MatchRecord{
+		MatchID:   g.MatchID,
+		PlayerAID: g.PlayerAID,
+		PlayerBID: g.PlayerBID,
+		CreatedAt: g.CreatedAt,
+		UpdatedAt: time.Now(),
+		FirstID:   g.FirstID,
+		BotIDs:    bots,
+		Game:      g.Game.Snapshot(),
+	}
+}
+
+// persist writes the current state of g. Callers hold g.mu.
+func (g *GameState) persist() {
+	if err := matchStore.SaveMatch(g.record()); err != nil {
+		log.Println("persist: saving match", g.MatchID, "failed:", err)
+	}
+}
+
+// RestoreMatches loads every stored match and best-of-N series back into
+// memory. Players reattach to them through their resume tokens.
+func RestoreMatches() error {
+	recs, err := matchStore.LoadMatches()
+	if err != nil {
+		return err
+	}
+	seriesRecs, err := matchStore.LoadSeries()
+	if err != nil {
+		return err
+	}
+	runs := make([]*series, 0, len(seriesRecs))
+	for _, rec := range seriesRecs {
+		runs = append(runs, restoreSeries(rec))
+	}
+	restored := make([]*GameState, 0, len(recs))
+	gamesMu.Lock()
+	for _, rec := range recs {
+		g, err := restoreMatch(rec)
+		if err != nil {
+			gamesMu.Unlock()
+			return err
+		}
+		g.series = seriesOf(rec.MatchID)
+		games[rec.MatchID] = g
+		restored = append(restored, g)
+	}
+	gamesMu.Unlock()
+	for _, s := range runs {
+		s.resume()
+	}
+	for i, g := range restored {
+		g.resume(recs[i].BotIDs)
+	}
+	log.Println("RestoreMatches: restored", len(recs), "matches and", len(runs), "series")
+	return nil
+}
+
+// resume restarts a restored match. Nobody is connected yet, so both seats
+// get the disconnect grace window. Bots come back with new identities, so
+// a match against one is abandoned. A match that ended before the restart
+// but never made it to the archive is archived now.
+func (g *GameState) resume(botIDs []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.Game.State().Over() {
+		g.finishRestored()
+		return
+	}
+	if len(botIDs) > 0 {
+		g.Game.Abandon()
+		g.logEvent("abandoned", nil)
+		g.endMatch("abandoned")
+		return
+	}
+	for _, id := range []string{g.PlayerAID, g.PlayerBID} {
+		g.startGrace(id)
+	}
+	// clocks are not persisted; a restored battle gets a fresh turn
+	if g.Game.State() == game.Battle {
+		g.clock.start(g)
+	}
+}
+
+// finishRestored archives a restored match that is already over. If
+// endMatch never logged it, the match is ended now; otherwise only the
+// archive is missing. Callers hold g.mu.
+func (g *GameState) finishRestored() {
+	events, _ := matchStore.LoadEvents(g.MatchID)
+	reason := "all_ships_sunk"
+	if g.Game.State() == game.Abandoned {
+		reason = "abandoned"
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		switch events[i].Type {
+		case "match_over":
+			var d eventMatchOver
+			json.Unmarshal(events[i].Data, &d)
+			rec := g.record()
+			rec.Result = &store.MatchResult{WinnerID: d.WinnerID, Reason: d.Reason, FinishedAt: events[i].At}
+			if err := matchStore.ArchiveMatch(rec); err != nil {
+				log.Println("RestoreMatches: archiving", g.MatchID, "failed:", err)
+			}
+			// the series never heard the match was over
+			matchID := g.MatchID
+			go seriesMatchOver(matchID, d.WinnerID, d.Reason)
+			time.AfterFunc(FinishedLinger, func() { evictMatch(matchID) })
+			return
+		case "forfeit":
+			var d eventForfeit
+			json.Unmarshal(events[i].Data, &d)
+			if d.Reason != "" {
+				reason = d.Reason
+			}
+		}
+	}
+	g.endMatch(reason)
+}
+
+// restoreMatch rebuilds a stored match from its event log, falling back to
+// the saved snapshot for matches without a usable log.
+func restoreMatch(rec store.MatchRecord) (*GameState, error) {
+	events, err := matchStore.LoadEvents(rec.MatchID)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > 0 {
+		g, err := replayMatch(events)
+		if err == nil {
+			return g, nil
+		}
+		log.Println("RestoreMatches: replaying", rec.MatchID, "failed, using snapshot:", err)
+	}
+	g := &GameState{
+		MatchID:   rec.MatchID,
+		PlayerAID: rec.PlayerAID,
+		PlayerBID: rec.PlayerBID,
+		CreatedAt: rec.CreatedAt,
+		FirstID:   rec.FirstID,
+		Game:      game.Restore(rec.Game),
+		clock:     newTurnClock(DefaultClock),
+	}
+	g.eventSeq = len(events)
+	return g, nil
+}
@@ -0,0 +1,86 @@
+package ws
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"battleship-go/internal/rating"
+	"battleship-go/internal/store"
+)
+
+// profilesMu serialises profile read-modify-write cycles.
+var profilesMu sync.Mutex
+
+// loadProfile returns the stored profile for id, or a fresh unrated one.
+func loadProfile(id string) store.Profile {
+	prof, ok, err := matchStore.LoadProfile(id)
+	if err != nil {
+		log.Println("loadProfile:", id, err)
+	}
+	if !ok {
+		prof = store.Profile{ID: id, Rating: rating.Initial}
+	}
+	return prof
+}
+
+func saveProfile(prof store.Profile) {
+	prof.UpdatedAt = time.Now()
+	if err := matchStore.SaveProfile(prof); err != nil {
+		log.Println("saveProfile:", prof.ID, err)
+	}
+}
+
+// touchProfile creates p's profile on first join and keeps its display
+// name current. Only accounts have a profile; guests and bots get a zero
+// one.
+func touchProfile(p *Player) store.Profile {
+	if p.account == "" {
+		return store.Profile{}
+	}
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	prof, ok, _ := matchStore.LoadProfile(p.ID)
+	if ok && prof.Name == p.Name {
+		return prof
+	}
+	if !ok {
+		prof = loadProfile(p.ID)
+	}
+	prof.Name = p.Name
+	saveProfile(prof)
+	return prof
+}
+
+func isAccount(id string) bool {
+	_, ok, _ := matchStore.LoadAccount(id)
+	return ok
+}
+
+// rateMatch records a decided match on both profiles and returns the new
+// ratings. Only matches between two accounts are rated; others return nil.
+func rateMatch(winnerID, loserID string) map[string]int {
+	if !isAccount(winnerID) || !isAccount(loserID) {
+		return nil
+	}
+	profilesMu.Lock()
+	defer profilesMu.Unlock()
+	w, l := loadProfile(winnerID), loadProfile(loserID)
+	w.Rating, l.Rating = rating.Update(w.Rating, l.Rating, 1)
+	w.GamesPlayed++
+	l.GamesPlayed++
+	w.Wins++
+	l.Losses++
+	saveProfile(w)
+	saveProfile(l)
+	return map[string]int{w.ID: w.Rating, l.ID: l.Rating}
+}
+
+// playerRating is the rating shown next to an account's name, 0 for
+// guests and bots.
+func playerRating(p *Player) int {
+	if p.account == "" {
+		return 0
+	}
+	return loadProfile(p.ID).Rating
+}
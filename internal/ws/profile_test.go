@@ -0,0 +1,71 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/rating"
+	"battleship-go/internal/store"
+)
+
+// loggedIn turns p into a logged-in account.
+func loggedIn(t *testing.T, p *Player) *Player {
+	t.Helper()
+	p.account = p.Name
+	if err := matchStore.SaveAccount(store.Account{ID: p.ID, Username: p.Name}); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// matchOver drains p's match_over message.
+func matchOver(t *testing.T, p *Player) protocol.MatchOver {
+	t.Helper()
+	var m protocol.MatchOver
+	if msgs := sent(p, "match_over"); len(msgs) != 1 || json.Unmarshal(msgs[0], &m) != nil {
+		t.Fatalf("got %d match_over", len(msgs))
+	}
+	return m
+}
+
+func TestRatedMatch(t *testing.T) {
+	a, b, g := testBattle(t, game.DefaultRules())
+	loggedIn(t, a)
+	loggedIn(t, b)
+	forfeitMatch(t, g, b)
+
+	win, loss := rating.Update(rating.Initial, rating.Initial, 1)
+	if got := matchOver(t, a).Ratings; got[a.ID] != win || got[b.ID] != loss {
+		t.Errorf("match_over ratings = %v, want %s:%d %s:%d", got, a.ID, win, b.ID, loss)
+	}
+	for _, c := range []struct {
+		p            *Player
+		rating, wins int
+	}{{a, win, 1}, {b, loss, 0}} {
+		prof := loadProfile(c.p.ID)
+		if prof.Rating != c.rating || prof.GamesPlayed != 1 || prof.Wins != c.wins || prof.Losses != 1-c.wins {
+			t.Errorf("profile %+v, want rating %d after one game with %d wins", prof, c.rating, c.wins)
+		}
+		if playerRating(c.p) != c.rating {
+			t.Errorf("playerRating = %d, want %d", playerRating(c.p), c.rating)
+		}
+	}
+}
+
+func TestGuestMatchIsUnrated(t *testing.T) {
+	a, b, g := testBattle(t, game.DefaultRules())
+	loggedIn(t, a)
+	forfeitMatch(t, g, b)
+
+	if got := matchOver(t, a).Ratings; got != nil {
+		t.Errorf("match against a guest rated: %v", got)
+	}
+	if prof, ok, _ := matchStore.LoadProfile(a.ID); ok && prof.GamesPlayed != 0 {
+		t.Errorf("account profile counts a guest match: %+v", prof)
+	}
+	if playerRating(b) != 0 {
+		t.Errorf("guest shows rating %d", playerRating(b))
+	}
+}
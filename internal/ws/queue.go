@@ -0,0 +1,207 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/rating"
+)
+
+// Matchmaking settings. Two queued players are paired when they asked for
+// the same rules and their ratings are within the band of whichever has
+// waited longer; the band starts at QueueBand and grows by QueueWiden
+// every QueueWidenEvery.
+var (
+	QueueBand       = 100
+	QueueWiden      = 50
+	QueueWidenEvery = 10 * time.Second
+	// queueTick is how often waiting players are re-examined as their
+	// bands widen.
+	queueTick = time.Second
+)
+
+type queueEntry struct {
+	p        *Player
+	rules    game.RuleSet
+	rating   int
+	joinedAt time.Time
+}
+
+// band is how far from e's rating an opponent may be at now.
+func (e queueEntry) band(now time.Time) int {
+	return QueueBand + QueueWiden*int(now.Sub(e.joinedAt)/QueueWidenEvery)
+}
+
+var (
+	queueMu   sync.Mutex
+	queue     []queueEntry
+	queueOnce sync.Once
+)
+
+// inLiveMatch reports whether playerID is seated in a match still being
+// played or waits between the games of a best-of-N series.
+func inLiveMatch(playerID string) bool {
+	for _, g := range matchesFor(playerID) {
+		g.mu.Lock()
+		over := g.Game.State().Over()
+		g.mu.Unlock()
+		if !over {
+			return true
+		}
+	}
+	return betweenGames(playerID)
+}
+
+// joinQueue adds p to the matchmaking queue and tries to pair it at once.
+func joinQueue(p *Player, rules game.RuleSet) error {
+	if p.bot != "" {
+		return errors.New("bots_cannot_queue")
+	}
+	if inLiveMatch(p.ID) {
+		return errors.New("already_in_match")
+	}
+	queueMu.Lock()
+	for _, e := range queue {
+		if e.p.ID == p.ID {
+			queueMu.Unlock()
+			return errors.New("already_queued")
+		}
+	}
+	// guests are matched as if freshly rated
+	r := playerRating(p)
+	if r == 0 {
+		r = rating.Initial
+	}
+	queue = append(queue, queueEntry{p: p, rules: rules, rating: r, joinedAt: time.Now()})
+	queueMu.Unlock()
+
+	queueOnce.Do(func() { go runQueue() })
+	log.Println("joinQueue:", p.ID, "rules", rules.Mode, rules.Width, "x", rules.Height)
+	matchQueued()
+	return nil
+}
+
+// leaveQueue drops playerID from the queue, reporting whether it was there.
+func leaveQueue(playerID string) bool {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	for i, e := range queue {
+		if e.p.ID == playerID {
+			queue = append(queue[:i], queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func inQueue(playerID string) bool {
+	queueMu.Lock()
+	defer queueMu.Unlock()
+	for _, e := range queue {
+		if e.p.ID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+func runQueue() {
+	for range time.Tick(queueTick) {
+		matchQueued()
+	}
+}
+
+// matchQueued pairs every compatible pair in the queue, longest waiting
+// first, and starts their matches.
+func matchQueued() {
+	now := time.Now()
+	var pairs [][2]queueEntry
+
+	queueMu.Lock()
+	for i := 0; i < len(queue); i++ {
+		for j := i + 1; j < len(queue); j++ {
+			a, b := queue[i], queue[j]
+			diff := a.rating - b.rating
+			if diff < 0 {
+				diff = -diff
+			}
+			band := a.band(now)
+			if b.joinedAt.Before(a.joinedAt) {
+				band = b.band(now)
+			}
+			if !a.rules.Equal(b.rules) || diff > band {
+				continue
+			}
+			pairs = append(pairs, [2]queueEntry{a, b})
+			queue = append(queue[:j], queue[j+1:]...)
+			queue = append(queue[:i], queue[i+1:]...)
+			i--
+			break
+		}
+	}
+	queueMu.Unlock()
+
+	for _, pair := range pairs {
+		a, b := pair[0], pair[1]
+		seatMu.Lock()
+		if inLiveMatch(a.p.ID) || inLiveMatch(b.p.ID) {
+			// one of them was seated elsewhere after leaving the queue; the
+			// other keeps its place
+			for _, e := range pair {
+				if !inLiveMatch(e.p.ID) {
+					queueMu.Lock()
+					queue = append(queue, e)
+					queueMu.Unlock()
+				}
+			}
+			seatMu.Unlock()
+			continue
+		}
+		log.Println("matchQueued: pairing", a.p.ID, a.rating, "with", b.p.ID, b.rating)
+		startMatch(a.p, b.p, matchOptions{Rules: a.rules})
+		seatMu.Unlock()
+	}
+}
+
+func handleQueueJoin(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.QueueJoin)
+	rules := game.DefaultRules()
+	if m.Rules != nil {
+		rules = *m.Rules
+	}
+	if err := rules.Validate(); err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "queue_join"})
+		return
+	}
+	if err := joinQueue(p, rules); err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "queue_join"})
+		return
+	}
+	announceStatus(p.ID)
+	// a match may already have started; only report a still-waiting player
+	queueMu.Lock()
+	waiting := len(queue)
+	queued := false
+	for _, e := range queue {
+		if e.p.ID == p.ID {
+			queued = true
+		}
+	}
+	queueMu.Unlock()
+	if queued {
+		p.sendMsg(protocol.QueueStatus{Queued: true, Waiting: waiting, Rules: &rules})
+	}
+}
+
+func handleQueueLeave(p *Player, msg protocol.Message) {
+	if !leaveQueue(p.ID) {
+		p.sendMsg(protocol.Error{Error: "not_queued", For: "queue_leave"})
+		return
+	}
+	p.sendMsg(protocol.QueueStatus{Queued: false})
+	announceStatus(p.ID)
+}
@@ -0,0 +1,98 @@
+package ws
+
+import (
+	"testing"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/rating"
+)
+
+// enqueue puts p in the queue as if it had joined ago, rated r.
+func enqueue(t *testing.T, p *Player, rules game.RuleSet, r int, ago time.Duration) {
+	t.Helper()
+	queueMu.Lock()
+	queue = append(queue, queueEntry{p: p, rules: rules, rating: r, joinedAt: time.Now().Add(-ago)})
+	queueMu.Unlock()
+	t.Cleanup(func() { leaveQueue(p.ID) })
+}
+
+func TestQueueBandWidens(t *testing.T) {
+	e := queueEntry{joinedAt: time.Now()}
+	for _, c := range []struct {
+		waited time.Duration
+		want   int
+	}{
+		{0, QueueBand},
+		{QueueWidenEvery - time.Millisecond, QueueBand},
+		{QueueWidenEvery, QueueBand + QueueWiden},
+		{3 * QueueWidenEvery, QueueBand + 3*QueueWiden},
+	} {
+		if got := e.band(e.joinedAt.Add(c.waited)); got != c.want {
+			t.Errorf("band after %v = %d, want %d", c.waited, got, c.want)
+		}
+	}
+}
+
+func TestQueuePairsWithinBand(t *testing.T) {
+	rules := game.DefaultRules()
+	a, b := testPlayer(t), testPlayer(t)
+	far := rating.Initial + QueueBand + QueueWiden
+	enqueue(t, a, rules, rating.Initial, 0)
+	enqueue(t, b, rules, far, 0)
+
+	matchQueued()
+	if !inQueue(a.ID) || !inQueue(b.ID) || inLiveMatch(a.ID) {
+		t.Fatal("players outside each other's band were paired")
+	}
+
+	// once a has waited long enough its band reaches b
+	leaveQueue(a.ID)
+	enqueue(t, a, rules, rating.Initial, QueueWidenEvery)
+	matchQueued()
+	if inQueue(a.ID) || inQueue(b.ID) {
+		t.Fatal("players within the widened band stayed queued")
+	}
+	if ms := matchesFor(a.ID); len(ms) != 1 || !inLiveMatch(b.ID) {
+		t.Fatalf("a is in %d matches, want one against b", len(ms))
+	}
+}
+
+func TestQueueNeedsSameRules(t *testing.T) {
+	small := game.DefaultRules()
+	small.Width, small.Height = 8, 8
+	a, b := testPlayer(t), testPlayer(t)
+	enqueue(t, a, game.DefaultRules(), rating.Initial, 0)
+	enqueue(t, b, small, rating.Initial, 0)
+
+	matchQueued()
+	if !inQueue(a.ID) || !inQueue(b.ID) {
+		t.Error("players asking for different rules were paired")
+	}
+}
+
+func TestQueueRequeuesFreePlayer(t *testing.T) {
+	a, _, _ := testBattle(t, game.DefaultRules())
+	c := testPlayer(t)
+	enqueue(t, a, game.DefaultRules(), rating.Initial, 0)
+	enqueue(t, c, game.DefaultRules(), rating.Initial, 0)
+
+	matchQueued()
+	if inQueue(a.ID) {
+		t.Error("seated player went back in the queue")
+	}
+	if !inQueue(c.ID) || inLiveMatch(c.ID) {
+		t.Error("free player lost its place when its pair was busy")
+	}
+}
+
+func TestJoinQueueRefusesSeatedPlayers(t *testing.T) {
+	a, _, _ := testBattle(t, game.DefaultRules())
+	if err := joinQueue(a, game.DefaultRules()); err == nil || err.Error() != "already_in_match" {
+		t.Errorf("seated player: got %v, want already_in_match", err)
+	}
+	bot := testBot(t, "easy").p
+	if err := joinQueue(bot, game.DefaultRules()); err == nil || err.Error() != "bots_cannot_queue" {
+		t.Errorf("bot: got %v, want bots_cannot_queue", err)
+	}
+}
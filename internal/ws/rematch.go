@@ -0,0 +1,183 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sync"
+
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/store"
+)
+
+// rematchOffer is an open rematch proposal for a finished match.
+type rematchOffer struct {
+	FromID    string
+	SwapFirst bool
+}
+
+var (
+	rematchMu sync.Mutex
+	// rematchOffers holds open offers keyed by the finished match ID.
+	rematchOffers = make(map[string]rematchOffer)
+	// rematched maps a finished match ID to the rematch it produced, so a
+	// match can only be replayed once.
+	rematched = make(map[string]string)
+)
+
+// finishedMatch loads the archived record of matchID and checks playerID
+// was seated in it.
+func finishedMatch(matchID, playerID string) (store.MatchRecord, error) {
+	rec, ok, err := matchStore.LoadArchived(matchID)
+	if err != nil {
+		return store.MatchRecord{}, err
+	}
+	if !ok {
+		if _, live := GetGameState(matchID); live {
+			return store.MatchRecord{}, errors.New("match_not_finished")
+		}
+		return store.MatchRecord{}, errors.New("match_not_found")
+	}
+	if rec.PlayerAID != playerID && rec.PlayerBID != playerID {
+		return store.MatchRecord{}, errors.New("not_in_match")
+	}
+	if s := seriesOf(matchID); s != nil {
+		// the tournament decides who plays next
+		if s.TournamentID != "" {
+			return store.MatchRecord{}, errors.New("tournament_match")
+		}
+		// a best-of-N series starts its own next game
+		if s.inProgress() {
+			return store.MatchRecord{}, errors.New("series_in_progress")
+		}
+	}
+	return rec, nil
+}
+
+func opponentIn(rec store.MatchRecord, playerID string) string {
+	if rec.PlayerAID == playerID {
+		return rec.PlayerBID
+	}
+	return rec.PlayerAID
+}
+
+func handleRematchRequest(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.RematchRequest)
+	rec, err := finishedMatch(m.MatchID, p.ID)
+	if err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "rematch_request"})
+		return
+	}
+	opponent, ok := GetPlayer(opponentIn(rec, p.ID))
+	if !ok {
+		p.sendMsg(protocol.Error{Error: "opponent_not_connected", For: "rematch_request"})
+		return
+	}
+
+	rematchMu.Lock()
+	if _, done := rematched[m.MatchID]; done {
+		rematchMu.Unlock()
+		p.sendMsg(protocol.Error{Error: "rematch_already_started", For: "rematch_request"})
+		return
+	}
+	if offer, ok := rematchOffers[m.MatchID]; ok && offer.FromID != p.ID {
+		// both sides asked: treat the second request as an acceptance
+		rematchMu.Unlock()
+		handleRematchResponse(p, &protocol.RematchResponse{MatchID: m.MatchID, Accept: true})
+		return
+	}
+	rematchOffers[m.MatchID] = rematchOffer{FromID: p.ID, SwapFirst: m.SwapFirst}
+	rematchMu.Unlock()
+
+	log.Println("handleRematchRequest:", p.ID, "offers rematch of", m.MatchID)
+	opponent.sendMsg(protocol.RematchOffer{
+		MatchID:   m.MatchID,
+		FromID:    p.ID,
+		FromName:  p.Name,
+		SwapFirst: m.SwapFirst,
+	})
+}
+
+func handleRematchResponse(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.RematchResponse)
+	rec, err := finishedMatch(m.MatchID, p.ID)
+	if err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "rematch_response"})
+		return
+	}
+
+	rematchMu.Lock()
+	offer, ok := rematchOffers[m.MatchID]
+	if !ok || offer.FromID == p.ID {
+		rematchMu.Unlock()
+		p.sendMsg(protocol.Error{Error: "no_rematch_offer", For: "rematch_response"})
+		return
+	}
+	delete(rematchOffers, m.MatchID)
+	if m.Accept {
+		rematched[m.MatchID] = ""
+	}
+	rematchMu.Unlock()
+
+	requester, ok := GetPlayer(offer.FromID)
+	if !ok {
+		p.sendMsg(protocol.Error{Error: "opponent_not_connected", For: "rematch_response"})
+		rematchMu.Lock()
+		delete(rematched, m.MatchID)
+		rematchMu.Unlock()
+		return
+	}
+	if m.Accept {
+		// either side may have started another match since the offer;
+		// the check holds until the rematch below has seated them
+		seatMu.Lock()
+		defer seatMu.Unlock()
+		if err := checkChallenge(p, requester, rec.Game.Rules); err != nil {
+			p.sendMsg(protocol.Error{Error: err.Error(), For: "rematch_response"})
+			requester.sendMsg(protocol.RematchResponseForward{
+				MatchID:  m.MatchID,
+				FromID:   p.ID,
+				FromName: p.Name,
+				Reason:   err.Error(),
+			})
+			rematchMu.Lock()
+			delete(rematched, m.MatchID)
+			rematchMu.Unlock()
+			return
+		}
+	}
+	requester.sendMsg(protocol.RematchResponseForward{
+		MatchID:  m.MatchID,
+		FromID:   p.ID,
+		FromName: p.Name,
+		Accept:   m.Accept,
+	})
+	if !m.Accept {
+		return
+	}
+
+	s := seriesOf(m.MatchID)
+	switch {
+	case s == nil:
+		// a first rematch turns the pair's games into an open-ended series
+		s = newSeries(rec.PlayerAID, rec.PlayerBID, 0, rec.Game.Rules)
+		winnerID := ""
+		if rec.Result != nil {
+			winnerID = rec.Result.WinnerID
+		}
+		s.adopt(m.MatchID, winnerID)
+	case s.BestOf > 0:
+		// a decided best-of-N series is replayed as a fresh one
+		s = newSeries(rec.PlayerAID, rec.PlayerBID, s.BestOf, rec.Game.Rules)
+	}
+	// without a swap the first shooter is drawn at random, as for any match
+	firstID := ""
+	if offer.SwapFirst && rec.FirstID != "" {
+		firstID = opponentIn(rec, rec.FirstID)
+	}
+	g := s.play(requester, p, firstID, m.MatchID)
+
+	rematchMu.Lock()
+	rematched[m.MatchID] = g.MatchID
+	rematchMu.Unlock()
+	log.Println("handleRematchResponse: rematch of", m.MatchID, "started as", g.MatchID)
+}
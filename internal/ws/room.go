@@ -0,0 +1,228 @@
+package ws
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+// RoomTTL is how long a private room waits for its second player.
+var RoomTTL = 10 * time.Minute
+
+const (
+	// roomCodeAlphabet leaves out characters easily mistaken for others.
+	roomCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	roomCodeLength   = 6
+)
+
+// Reasons a room closes, sent in room_closed.
+const (
+	roomJoined        = "joined"
+	roomClosedByOwner = "closed"
+	roomExpired       = "expired"
+	roomOwnerLeft     = "owner_left"
+	roomOwnerBusy     = "busy"
+	roomReplaced      = "replaced"
+)
+
+// room is a private match waiting for a second player with its code.
+type room struct {
+	Code    string
+	OwnerID string
+	Rules   game.RuleSet
+	// salt and hash protect an optional password; hash is nil when the
+	// room is open to anyone with the code.
+	salt  []byte
+	hash  []byte
+	timer *time.Timer
+}
+
+var (
+	roomsMu sync.Mutex
+	rooms   = map[string]*room{}
+)
+
+func hashRoomPassword(salt []byte, password string) []byte {
+	sum := sha256.Sum256(append(append([]byte(nil), salt...), password...))
+	return sum[:]
+}
+
+func (r *room) checkPassword(password string) bool {
+	if r.hash == nil {
+		return true
+	}
+	return subtle.ConstantTimeCompare(hashRoomPassword(r.salt, password), r.hash) == 1
+}
+
+// newRoomCode draws a code not used by an open room. Callers hold roomsMu.
+func newRoomCode() string {
+	b := make([]byte, roomCodeLength)
+	for {
+		if _, err := rand.Read(b); err != nil {
+			log.Fatal("room: cannot generate code: ", err)
+		}
+		for i := range b {
+			b[i] = roomCodeAlphabet[int(b[i])%len(roomCodeAlphabet)]
+		}
+		if _, taken := rooms[string(b)]; !taken {
+			return string(b)
+		}
+	}
+}
+
+// normalizeRoomCode accepts codes typed in any case and with spaces.
+func normalizeRoomCode(code string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// roomURL is the link that opens the web client straight into the room.
+func roomURL(code string) string {
+	return "/players.html?room=" + code
+}
+
+// closeRoom removes room code, if still open, and tells its owner why. It
+// reports whether the room was still open.
+func closeRoom(code, reason string) bool {
+	roomsMu.Lock()
+	r, ok := rooms[code]
+	if ok {
+		delete(rooms, code)
+		r.timer.Stop()
+	}
+	roomsMu.Unlock()
+	if !ok {
+		return false
+	}
+	if pl, ok := GetPlayer(r.OwnerID); ok {
+		pl.sendMsg(protocol.RoomClosed{Code: code, Reason: reason})
+	}
+	log.Println("closeRoom:", code, reason)
+	return true
+}
+
+// closeRoomsOf closes every room owned by playerID.
+func closeRoomsOf(playerID, reason string) {
+	roomsMu.Lock()
+	var codes []string
+	for code, r := range rooms {
+		if r.OwnerID == playerID {
+			codes = append(codes, code)
+		}
+	}
+	roomsMu.Unlock()
+	for _, code := range codes {
+		closeRoom(code, reason)
+	}
+}
+
+func handleRoomCreate(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.RoomCreate)
+	rules := game.DefaultRules()
+	if m.Rules != nil {
+		rules = *m.Rules
+	}
+	if err := rules.Validate(); err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "room_create"})
+		return
+	}
+	if playerStatus(p) == statusInMatch {
+		p.sendMsg(protocol.Error{Error: "already_in_match", For: "room_create"})
+		return
+	}
+	// one open room per player; a new one replaces the last
+	closeRoomsOf(p.ID, roomReplaced)
+
+	r := &room{OwnerID: p.ID, Rules: rules}
+	if m.Password != "" {
+		r.salt = make([]byte, 16)
+		if _, err := rand.Read(r.salt); err != nil {
+			p.sendMsg(protocol.Error{Error: "internal_error", For: "room_create"})
+			return
+		}
+		r.hash = hashRoomPassword(r.salt, m.Password)
+	}
+	roomsMu.Lock()
+	r.Code = newRoomCode()
+	code := r.Code
+	r.timer = time.AfterFunc(RoomTTL, func() { closeRoom(code, roomExpired) })
+	rooms[code] = r
+	roomsMu.Unlock()
+
+	p.sendMsg(protocol.RoomCreated{
+		Code:        code,
+		URL:         roomURL(code),
+		Rules:       rules,
+		HasPassword: r.hash != nil,
+		ExpiresInMs: RoomTTL.Milliseconds(),
+	})
+	log.Println("handleRoomCreate:", p.ID, "opened room", code)
+}
+
+// joinRoom checks p may enter room code and that its owner can play, then
+// takes the room out of the open set. A room whose owner cannot play is
+// closed. Callers hold seatMu, so the owner stays free until seated.
+func joinRoom(p *Player, code, password string) (*room, *Player, error) {
+	roomsMu.Lock()
+	r, ok := rooms[code]
+	roomsMu.Unlock()
+	switch {
+	case !ok:
+		return nil, nil, errors.New("room_not_found")
+	case r.OwnerID == p.ID:
+		return nil, nil, errors.New("own_room")
+	case !r.checkPassword(password):
+		return nil, nil, errors.New("wrong_password")
+	}
+	owner, ok := GetPlayer(r.OwnerID)
+	switch {
+	case !ok || !owner.Connected():
+		closeRoom(code, roomOwnerLeft)
+		return nil, nil, errors.New("room_not_found")
+	case playerStatus(owner) == statusInMatch:
+		closeRoom(code, roomOwnerBusy)
+		return nil, nil, errors.New("room_not_found")
+	}
+	// it may have expired or been closed since the lookup
+	if !closeRoom(code, roomJoined) {
+		return nil, nil, errors.New("room_not_found")
+	}
+	return r, owner, nil
+}
+
+func handleRoomJoin(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.RoomJoin)
+	seatMu.Lock()
+	defer seatMu.Unlock()
+	if playerStatus(p) == statusInMatch {
+		p.sendMsg(protocol.Error{Error: "already_in_match", For: "room_join"})
+		return
+	}
+	r, owner, err := joinRoom(p, normalizeRoomCode(m.Code), m.Password)
+	if err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "room_join"})
+		return
+	}
+	log.Println("handleRoomJoin:", p.ID, "joined room", r.Code)
+	startMatch(owner, p, matchOptions{Rules: r.Rules})
+}
+
+func handleRoomClose(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.RoomClose)
+	code := normalizeRoomCode(m.Code)
+	roomsMu.Lock()
+	r, ok := rooms[code]
+	roomsMu.Unlock()
+	if !ok || r.OwnerID != p.ID {
+		p.sendMsg(protocol.Error{Error: "room_not_found", For: "room_close"})
+		return
+	}
+	closeRoom(code, roomClosedByOwner)
+}
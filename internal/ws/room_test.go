@@ -0,0 +1,126 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// online gives p a socket that is never written to, so it counts as
+// connected.
+func online(p *Player) *Player {
+	p.attach(new(websocket.Conn), nil)
+	return p
+}
+
+// openRoom has owner create a room and returns its code.
+func openRoom(t *testing.T, owner *Player, password string) string {
+	t.Helper()
+	handleRoomCreate(owner, &protocol.RoomCreate{Password: password})
+	var m protocol.RoomCreated
+	if msgs := sent(owner, "room_created"); len(msgs) != 1 || json.Unmarshal(msgs[0], &m) != nil {
+		t.Fatalf("owner got %d room_created", len(msgs))
+	}
+	t.Cleanup(func() { closeRoom(m.Code, roomClosedByOwner) })
+	return m.Code
+}
+
+func TestNormalizeRoomCode(t *testing.T) {
+	for in, want := range map[string]string{"ABC234": "ABC234", "abc234": "ABC234", " ab c2 34 ": "ABC234"} {
+		if got := normalizeRoomCode(in); got != want {
+			t.Errorf("normalizeRoomCode(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNewRoomCode(t *testing.T) {
+	roomsMu.Lock()
+	defer roomsMu.Unlock()
+	for i := 0; i < 100; i++ {
+		code := newRoomCode()
+		if len(code) != roomCodeLength {
+			t.Fatalf("code %q has %d characters", code, len(code))
+		}
+		for _, c := range code {
+			if !strings.ContainsRune(roomCodeAlphabet, c) {
+				t.Fatalf("code %q uses %q", code, c)
+			}
+		}
+	}
+}
+
+func TestRoomJoin(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string // the room's
+		typed    string // the joiner's
+		owner    func(t *testing.T, owner *Player)
+		err      string
+		closed   string // room_closed reason the owner gets, if any
+	}{
+		{"open room", "", "", nil, "", roomJoined},
+		{"password", "hunter2", "hunter2", nil, "", roomJoined},
+		{"wrong password", "hunter2", "hunter3", nil, "wrong_password", ""},
+		{"no password given", "hunter2", "", nil, "wrong_password", ""},
+		{"owner left", "", "", func(t *testing.T, owner *Player) {
+			owner.mu.Lock()
+			owner.conn = nil
+			close(owner.done)
+			owner.mu.Unlock()
+		}, "room_not_found", roomOwnerLeft},
+		{"owner between games", "", "", func(t *testing.T, owner *Player) {
+			s := newSeries(owner.ID, uuid.NewString(), 3, game.DefaultRules())
+			seriesMu.Lock()
+			seriesWaiting[s.ID] = s
+			seriesMu.Unlock()
+			t.Cleanup(func() { s.finish("", seriesAbandoned) })
+		}, "room_not_found", roomOwnerBusy},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner, p := online(testPlayer(t)), online(testPlayer(t))
+			code := openRoom(t, owner, tt.password)
+			if tt.owner != nil {
+				tt.owner(t, owner)
+			}
+			// codes are typed in any case
+			handleRoomJoin(p, &protocol.RoomJoin{Code: " " + strings.ToLower(code), Password: tt.typed})
+
+			var e protocol.Error
+			errs := sent(p, "error")
+			if tt.err != "" && (len(errs) != 1 || json.Unmarshal(errs[0], &e) != nil || e.Error != tt.err) {
+				t.Errorf("joiner errors = %s, want %s", errs, tt.err)
+			}
+			if tt.err == "" && len(errs) != 0 {
+				t.Errorf("joiner errors = %s", errs)
+			}
+			var rc protocol.RoomClosed
+			msgs := sent(owner, "room_closed")
+			switch {
+			case tt.closed == "" && len(msgs) != 0:
+				t.Errorf("owner told %s, want the room still open", msgs)
+			case tt.closed != "" && (len(msgs) != 1 || json.Unmarshal(msgs[0], &rc) != nil || rc.Reason != tt.closed):
+				t.Errorf("owner told %s, want room_closed %q", msgs, tt.closed)
+			}
+			roomsMu.Lock()
+			_, open := rooms[code]
+			roomsMu.Unlock()
+			if open != (tt.closed == "") {
+				t.Errorf("room open = %v", open)
+			}
+			if tt.err == "" {
+				live := matchesFor(p.ID)
+				if len(live) != 1 {
+					t.Fatalf("joiner in %d matches", len(live))
+				}
+				forfeitMatch(t, live[0], p)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/store"
+
+	"github.com/google/uuid"
+)
+
+// SeriesGap is the pause between the games of a best-of-N series.
+var SeriesGap = 5 * time.Second
+
+// series_over reasons.
+const (
+	seriesDecided   = "decided"
+	seriesForfeit   = "forfeit"
+	seriesAbandoned = "abandoned"
+)
+
+var errBadBestOf = errors.New("bad_best_of")
+
+// checkBestOf accepts 0 or 1 for a single game and 3, 5 or 7 for a series.
+func checkBestOf(n int) error {
+	switch n {
+	case 0, 1, 3, 5, 7:
+		return nil
+	}
+	return errBadBestOf
+}
+
+// series is a run of matches between one pair of players. A best-of-N
+// series starts its games itself, alternating the first shooter, until one
+// player has won a majority. An open-ended series (BestOf 0) grows by one
+// match with each accepted rematch.
+type series struct {
+	ID        string
+	BestOf    int
+	Rules     game.RuleSet
+	PlayerIDs [2]string
+	// TournamentID is set when the series plays a tournament pairing.
+	TournamentID string
+	// Score, MatchIDs, firstID and over are guarded by seriesMu. Score is
+	// the only tally of the pair's wins; matches read it from here.
+	Score    map[string]int
+	MatchIDs []string
+	// firstID shot first in the latest game.
+	firstID string
+	over    bool
+	// onOver hears how a best-of-N series ended.
+	onOver func(s *series, winnerID, reason string)
+}
+
+var (
+	seriesMu sync.Mutex
+	// seriesOfMatch maps match IDs to the series they were played in.
+	seriesOfMatch = map[string]*series{}
+	// seriesWaiting holds the best-of-N series pausing between games, by ID.
+	seriesWaiting = map[string]*series{}
+)
+
+func newSeries(aID, bID string, bestOf int, rules game.RuleSet) *series {
+	return &series{
+		ID:        uuid.NewString(),
+		BestOf:    bestOf,
+		Rules:     rules,
+		PlayerIDs: [2]string{aID, bID},
+		Score:     map[string]int{aID: 0, bID: 0},
+	}
+}
+
+// seriesOf returns the series matchID was played in, if any.
+func seriesOf(matchID string) *series {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	return seriesOfMatch[matchID]
+}
+
+// startSeries plays a best-of-N series between a and b. onOver, if set,
+// runs once it is over.
+func startSeries(a, b *Player, bestOf int, rules game.RuleSet, tournamentID string, onOver func(s *series, winnerID, reason string)) *series {
+	s := newSeries(a.ID, b.ID, bestOf, rules)
+	s.TournamentID = tournamentID
+	s.onOver = onOver
+	log.Println("startSeries:", s.ID, "best of", bestOf, "between", a.ID, b.ID)
+	s.play(a, b, "", "")
+	return s
+}
+
+// play starts the next game of the series; firstID picks the first shooter
+// and is drawn at random when empty.
+func (s *series) play(a, b *Player, firstID, previousMatchID string) *GameState {
+	return startMatch(a, b, matchOptions{
+		FirstID:         firstID,
+		PreviousMatchID: previousMatchID,
+		Rules:           s.Rules,
+		series:          s,
+	})
+}
+
+// added records the game startMatch opened for the series and returns its
+// number.
+func (s *series) added(matchID, firstID string) int {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	s.MatchIDs = append(s.MatchIDs, matchID)
+	s.firstID = firstID
+	seriesOfMatch[matchID] = s
+	s.save()
+	return len(s.MatchIDs)
+}
+
+// adopt makes a finished standalone match the first game of the series.
+func (s *series) adopt(matchID, winnerID string) {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	if winnerID != "" {
+		s.Score[winnerID]++
+	}
+	s.MatchIDs = append(s.MatchIDs, matchID)
+	seriesOfMatch[matchID] = s
+}
+
+// won counts a finished game for winnerID, if any, and returns the score.
+// endMatch calls it with the match lock held.
+func (s *series) won(winnerID string) map[string]int {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	if winnerID != "" {
+		s.Score[winnerID]++
+		s.save()
+	}
+	return copyScore(s.Score)
+}
+
+// save persists a best-of-N series so it resumes after a restart. Open
+// series are just a tally of rematches and tournament series go with
+// their tournament, so neither is kept. Callers hold seriesMu.
+func (s *series) save() {
+	if s.BestOf == 0 || s.TournamentID != "" {
+		return
+	}
+	rec := store.SeriesRecord{
+		ID:        s.ID,
+		BestOf:    s.BestOf,
+		Rules:     s.Rules,
+		PlayerIDs: s.PlayerIDs,
+		Score:     copyScore(s.Score),
+		MatchIDs:  append([]string(nil), s.MatchIDs...),
+		FirstID:   s.firstID,
+		Over:      s.over,
+	}
+	if err := matchStore.SaveSeries(rec); err != nil {
+		log.Println("series: saving", s.ID, "failed:", err)
+	}
+}
+
+// inProgress reports whether a best-of-N series is still being played.
+func (s *series) inProgress() bool {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	return s.BestOf > 0 && !s.over
+}
+
+func (s *series) other(id string) string {
+	if s.PlayerIDs[0] == id {
+		return s.PlayerIDs[1]
+	}
+	return s.PlayerIDs[0]
+}
+
+// outcome decides whether a game that ended with reason, already counted
+// in the score, ends the series. Callers hold seriesMu.
+func (s *series) outcome(winnerID, reason string) string {
+	switch {
+	case winnerID == "":
+		return seriesAbandoned
+	case reason == "forfeit_disconnect":
+		// leaving forfeits the series; running out of time only the game
+		return seriesForfeit
+	case s.Score[winnerID] > s.BestOf/2:
+		return seriesDecided
+	}
+	return ""
+}
+
+// seriesMatchOver decides what a finished match, counted by endMatch, means
+// for its series, then schedules whatever tournament games its players are
+// now free for. endMatch runs it on its own goroutine since it holds the
+// match lock.
+func seriesMatchOver(matchID, winnerID, reason string) {
+	defer scheduleTournaments()
+	seriesMu.Lock()
+	s, ok := seriesOfMatch[matchID]
+	if !ok || s.over {
+		seriesMu.Unlock()
+		return
+	}
+	outcome := s.outcome(winnerID, reason)
+	bestOf := s.BestOf
+	seriesMu.Unlock()
+
+	switch {
+	case bestOf == 0:
+		// an open-ended series only grows by rematch
+	case outcome != "":
+		s.finish(winnerID, outcome)
+	default:
+		// its players stay busy until the next game starts
+		seriesMu.Lock()
+		seriesWaiting[s.ID] = s
+		seriesMu.Unlock()
+		announceStatus(s.PlayerIDs[:]...)
+		time.AfterFunc(SeriesGap, func() { s.next(matchID) })
+	}
+}
+
+// betweenGames reports whether playerID waits for the next game of a
+// best-of-N series.
+func betweenGames(playerID string) bool {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	for _, s := range seriesWaiting {
+		if s.PlayerIDs[0] == playerID || s.PlayerIDs[1] == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// next starts the game after previousMatchID with the other player shooting
+// first. A human who has left, or sits in another match, by then forfeits
+// the series.
+func (s *series) next(previousMatchID string) {
+	seriesMu.Lock()
+	delete(seriesWaiting, s.ID)
+	if s.over {
+		seriesMu.Unlock()
+		return
+	}
+	firstID := s.other(s.firstID)
+	seriesMu.Unlock()
+
+	// finishing may reach the tournament lock, so only the check and the
+	// next game are seated under seatMu
+	seatMu.Lock()
+	a, aok := seriesPresent(s.PlayerIDs[0])
+	b, bok := seriesPresent(s.PlayerIDs[1])
+	if aok && bok {
+		g := s.play(a, b, firstID, previousMatchID)
+		seatMu.Unlock()
+		log.Println("series:", s.ID, "next game", g.MatchID)
+		return
+	}
+	seatMu.Unlock()
+	switch {
+	case aok:
+		s.finish(a.ID, seriesForfeit)
+	case bok:
+		s.finish(b.ID, seriesForfeit)
+	default:
+		s.finish("", seriesAbandoned)
+	}
+}
+
+// restoreSeries rebuilds a stored series and links it to its games.
+func restoreSeries(rec store.SeriesRecord) *series {
+	s := &series{
+		ID:        rec.ID,
+		BestOf:    rec.BestOf,
+		Rules:     rec.Rules,
+		PlayerIDs: rec.PlayerIDs,
+		Score:     rec.Score,
+		MatchIDs:  rec.MatchIDs,
+		firstID:   rec.FirstID,
+	}
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	for _, id := range s.MatchIDs {
+		seriesOfMatch[id] = s
+	}
+	return s
+}
+
+// resume picks a restored series up where it stopped. A game still being
+// played goes on by itself; after one that had ended the series is over or
+// plays its next game once the players have had the disconnect grace to
+// come back.
+func (s *series) resume() {
+	_, latest := s.state()
+	if _, live := GetGameState(latest); live {
+		return
+	}
+	winnerID, reason := "", "abandoned"
+	if rec, ok, _ := matchStore.LoadArchived(latest); ok && rec.Result != nil {
+		winnerID, reason = rec.Result.WinnerID, rec.Result.Reason
+	}
+	seriesMu.Lock()
+	outcome := s.outcome(winnerID, reason)
+	if outcome == "" {
+		seriesWaiting[s.ID] = s
+	}
+	seriesMu.Unlock()
+	if outcome != "" {
+		s.finish(winnerID, outcome)
+		return
+	}
+	log.Println("series:", s.ID, "resumes after", latest)
+	time.AfterFunc(max(SeriesGap, DisconnectGrace), func() { s.next(latest) })
+}
+
+// seriesPresent returns the player for id if it can play the next game.
+func seriesPresent(id string) (*Player, bool) {
+	p, ok := GetPlayer(id)
+	if !ok {
+		return nil, false
+	}
+	return p, p.bot != "" || (p.Connected() && !inLiveMatch(id))
+}
+
+// finish ends the series, tells both players and runs onOver.
+func (s *series) finish(winnerID, reason string) {
+	seriesMu.Lock()
+	if s.over {
+		seriesMu.Unlock()
+		return
+	}
+	s.over = true
+	delete(seriesWaiting, s.ID)
+	s.save()
+	msg := protocol.SeriesOver{
+		SeriesID: s.ID,
+		BestOf:   s.BestOf,
+		WinnerID: winnerID,
+		Score:    copyScore(s.Score),
+		MatchIDs: append([]string(nil), s.MatchIDs...),
+		Reason:   reason,
+	}
+	seriesMu.Unlock()
+
+	for _, id := range s.PlayerIDs {
+		if p, ok := GetPlayer(id); ok {
+			p.sendMsg(msg)
+		}
+	}
+	// players waiting out the gap are free again
+	announceStatus(s.PlayerIDs[:]...)
+	log.Println("series:", s.ID, reason, "winner", winnerID, "score", msg.Score)
+	if s.onOver != nil {
+		s.onOver(s, winnerID, reason)
+	}
+}
+
+// state returns the score and latest match of the series.
+func (s *series) state() (map[string]int, string) {
+	seriesMu.Lock()
+	defer seriesMu.Unlock()
+	latest := ""
+	if n := len(s.MatchIDs); n > 0 {
+		latest = s.MatchIDs[n-1]
+	}
+	return copyScore(s.Score), latest
+}
@@ -0,0 +1,186 @@
+package ws
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/store"
+
+	"github.com/google/uuid"
+)
+
+func TestSeriesMatchOver(t *testing.T) {
+	defer func(gap time.Duration) { SeriesGap = gap }(SeriesGap)
+	SeriesGap = time.Hour
+
+	tests := []struct {
+		name   string
+		won    int // games the winner had won before this one
+		winner bool
+		reason string
+		want   string
+	}{
+		{"game won", 0, true, "all_ships_sunk", ""},
+		{"majority", 1, true, "all_ships_sunk", seriesDecided},
+		{"timeout loses the game only", 0, true, "forfeit_timeout", ""},
+		{"timeout can decide", 1, true, "forfeit_timeout", seriesDecided},
+		{"leaving forfeits the series", 0, true, "forfeit_disconnect", seriesForfeit},
+		{"abandoned", 0, false, "abandoned", seriesAbandoned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			s := newSeries(uuid.NewString(), uuid.NewString(), 3, game.DefaultRules())
+			s.onOver = func(_ *series, _, reason string) { got = reason }
+			defer s.finish("", seriesAbandoned)
+			winnerID := ""
+			if tt.winner {
+				winnerID = s.PlayerIDs[0]
+				// endMatch has counted this game already
+				s.Score[winnerID] = tt.won + 1
+			}
+			matchID := uuid.NewString()
+			s.added(matchID, s.PlayerIDs[0])
+
+			seriesMatchOver(matchID, winnerID, tt.reason)
+			if got != tt.want {
+				t.Errorf("series over with %q, want %q", got, tt.want)
+			}
+			if waiting := betweenGames(s.PlayerIDs[0]); waiting != (tt.want == "") {
+				t.Errorf("between games = %v", waiting)
+			}
+		})
+	}
+}
+
+// seriesGame returns the live game of s.
+func seriesGame(t *testing.T, s *series) *GameState {
+	t.Helper()
+	_, latest := s.state()
+	g, ok := GetGameState(latest)
+	if !ok {
+		t.Fatalf("series %s has no live game", s.ID)
+	}
+	return g
+}
+
+// restart drops everything a restart loses about s and its games.
+func restart(s *series) {
+	seriesMu.Lock()
+	s.over = true
+	delete(seriesWaiting, s.ID)
+	ids := s.MatchIDs
+	for _, id := range ids {
+		delete(seriesOfMatch, id)
+	}
+	seriesMu.Unlock()
+	gamesMu.Lock()
+	defer gamesMu.Unlock()
+	for _, id := range ids {
+		if g, ok := games[id]; ok {
+			g.mu.Lock()
+			g.clock.stop()
+			g.mu.Unlock()
+			delete(games, id)
+		}
+	}
+}
+
+func TestSeriesKeepsTheScore(t *testing.T) {
+	defer func(st store.Store, gap time.Duration) { matchStore, SeriesGap = st, gap }(matchStore, SeriesGap)
+	SetStore(store.NewMemoryStore())
+	SeriesGap = time.Hour
+	a, b := testPlayer(t), testPlayer(t)
+	s := startSeries(a, b, 3, game.DefaultRules(), "", nil)
+	defer s.finish("", seriesAbandoned)
+	forfeitMatch(t, seriesGame(t, s), b)
+
+	var over protocol.MatchOver
+	if msgs := sent(a, "match_over"); len(msgs) != 1 || json.Unmarshal(msgs[0], &over) != nil {
+		t.Fatalf("got %d match_over", len(msgs))
+	}
+	want := map[string]int{a.ID: 1, b.ID: 0}
+	score, _ := s.state()
+	if !reflect.DeepEqual(over.SeriesScore, want) || !reflect.DeepEqual(score, want) {
+		t.Errorf("match_over says %v and the series %v, want %v", over.SeriesScore, score, want)
+	}
+	recs, _ := matchStore.LoadSeries()
+	if len(recs) != 1 || !reflect.DeepEqual(recs[0].Score, want) || len(recs[0].MatchIDs) != 1 {
+		t.Errorf("stored series = %+v", recs)
+	}
+}
+
+func TestRestoreResumesSeries(t *testing.T) {
+	defer func(st store.Store, gap, grace time.Duration) {
+		matchStore, SeriesGap, DisconnectGrace = st, gap, grace
+	}(matchStore, SeriesGap, DisconnectGrace)
+
+	tests := []struct {
+		name string
+		// midGame restarts during the second game instead of before it
+		midGame bool
+	}{
+		{"between games", false},
+		{"during a game", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetStore(store.NewMemoryStore())
+			SeriesGap, DisconnectGrace = time.Hour, time.Hour
+			a, b := online(testPlayer(t)), online(testPlayer(t))
+			s := startSeries(a, b, 3, game.DefaultRules(), "", nil)
+			forfeitMatch(t, seriesGame(t, s), b)
+			_, first := s.state()
+			if tt.midGame {
+				s.next(first)
+			}
+			restart(s)
+
+			SeriesGap, DisconnectGrace = 0, 10*time.Millisecond
+			if err := RestoreMatches(); err != nil {
+				t.Fatal(err)
+			}
+			restored := seriesOf(first)
+			if restored == nil || restored.ID != s.ID {
+				t.Fatal("series not restored")
+			}
+			t.Cleanup(func() { restored.finish("", seriesAbandoned) })
+			if !tt.midGame {
+				// the second game starts once the grace has passed
+				for deadline := time.Now().Add(time.Second); ; time.Sleep(5 * time.Millisecond) {
+					if _, latest := restored.state(); latest != first {
+						break
+					}
+					if time.Now().After(deadline) {
+						t.Fatal("second game never started")
+					}
+				}
+			}
+			g := seriesGame(t, restored)
+			g.mu.Lock()
+			linked := g.series == restored
+			g.mu.Unlock()
+			if !linked {
+				t.Fatal("game not linked to the restored series")
+			}
+			sent(a, "")
+			forfeitMatch(t, g, b)
+			var over protocol.SeriesOver
+			for deadline := time.Now().Add(time.Second); over.Reason == ""; time.Sleep(5 * time.Millisecond) {
+				if time.Now().After(deadline) {
+					t.Fatal("series never ended")
+				}
+				if msgs := sent(a, "series_over"); len(msgs) == 1 {
+					json.Unmarshal(msgs[0], &over)
+				}
+			}
+			if over.WinnerID != a.ID || over.Score[a.ID] != 2 || over.Reason != seriesDecided {
+				t.Errorf("series_over = %+v, want a to win 2-0", over)
+			}
+		})
+	}
+}
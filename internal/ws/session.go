@@ -0,0 +1,169 @@
+package ws
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+const (
+	// sessionGrace is how long a detached player is kept around waiting for
+	// a reconnect before being unregistered.
+	sessionGrace = 2 * time.Minute
+	// resumeTokenTTL bounds how old a presented resume token may be.
+	resumeTokenTTL = 24 * time.Hour
+)
+
+var sessionSecret = loadSessionSecret()
+
+// loadSessionSecret reads SESSION_SECRET so tokens survive restarts, falling
+// back to a random per-process key.
+func loadSessionSecret() []byte {
+	if s := os.Getenv("SESSION_SECRET"); s != "" {
+		return []byte(s)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Fatal("session: cannot generate secret: ", err)
+	}
+	return b
+}
+
+func signToken(payload string) string {
+	mac := hmac.New(sha256.New, sessionSecret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// issueResumeToken returns a signed token binding the caller to playerID.
+func issueResumeToken(playerID string) string {
+	payload := playerID + "|" + strconv.FormatInt(time.Now().Unix(), 10)
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + signToken(payload)
+}
+
+// verifyResumeToken checks the signature and age of token and returns the
+// player ID it was issued for.
+func verifyResumeToken(token string) (string, error) {
+	enc, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", errors.New("bad_resume_token")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return "", errors.New("bad_resume_token")
+	}
+	payload := string(raw)
+	if !hmac.Equal([]byte(sig), []byte(signToken(payload))) {
+		return "", errors.New("bad_resume_token")
+	}
+	id, ts, ok := strings.Cut(payload, "|")
+	if !ok || id == "" {
+		return "", errors.New("bad_resume_token")
+	}
+	issued, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return "", errors.New("bad_resume_token")
+	}
+	if time.Since(time.Unix(issued, 0)) > resumeTokenTTL {
+		return "", errors.New("resume_token_expired")
+	}
+	return id, nil
+}
+
+// resumePlayer returns the player for id, recreating it if the session was
+// already reaped so the identity carries over regardless.
+func resumePlayer(id string) *Player {
+	if p, ok := GetPlayer(id); ok {
+		return p
+	}
+	p := newPlayer(id)
+	RegisterPlayer(p)
+	return p
+}
+
+// sendResync pushes a match_resync snapshot for every match playerID is in.
+func sendResync(p *Player) {
+	for _, g := range matchesFor(p.ID) {
+		p.sendMsg(buildResync(g, p.ID))
+		sendChatHistory(p, ChannelMatch, g.MatchID)
+	}
+}
+
+func buildResync(g *GameState, playerID string) protocol.MatchResync {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	side, _ := g.sideOf(playerID)
+	oppID := g.playerOn(side.Opponent())
+	oppName := ""
+	if opp, ok := GetPlayer(oppID); ok {
+		oppName = opp.Name
+	}
+
+	own := boardCells(g.Game.Board(side), false)
+	revealed := boardCells(g.Game.Board(side.Opponent()), true)
+
+	sunk := []protocol.SunkShip{}
+	for _, s := range []game.PlayerSide{side, side.Opponent()} {
+		for _, ship := range g.Game.Ships(s) {
+			if !ship.Sunk() {
+				continue
+			}
+			typ := ship.Type
+			if s != side {
+				typ = g.publicShipType(typ)
+			}
+			sunk = append(sunk, protocol.SunkShip{OwnerID: g.playerOn(s), ShipType: typ})
+		}
+	}
+
+	state := g.Game.State()
+	started := state != game.Created && state != game.Placing
+	msg := protocol.MatchResync{
+		MatchID:       g.MatchID,
+		Phase:         state.String(),
+		YourSide:      string(wireSide(side)),
+		OpponentID:    oppID,
+		OpponentName:  oppName,
+		Ready:         g.Game.Ready(side),
+		OpponentReady: g.Game.Ready(side.Opponent()),
+		Started:       started,
+		YourBoard:     own,
+		OpponentBoard: revealed,
+		SunkShips:     sunk,
+	}
+	msg.Rules = g.Game.Rules()
+	if started {
+		msg.Turn = string(wireSide(g.Game.Turn()))
+		if msg.Rules.Mode == game.ModeSalvo {
+			msg.SalvoSize = g.Game.SalvoSize(g.Game.Turn())
+		}
+	}
+	return msg
+}
+
+// boardCells flattens a board into rows of cell codes. With fog set, unhit
+// ship cells are reported as empty water.
+func boardCells(b game.Board, fog bool) [][]game.Cell {
+	out := make([][]game.Cell, len(b))
+	for y := range b {
+		out[y] = make([]game.Cell, len(b[y]))
+		for x, c := range b[y] {
+			if fog && c == game.Ship {
+				c = game.Empty
+			}
+			out[y][x] = c
+		}
+	}
+	return out
+}
@@ -0,0 +1,252 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+// SpectatorDelay is how far behind the live game reveal-mode spectators
+// run, so a cast does not spoil shots as they happen. Zero disables reveal
+// mode.
+var SpectatorDelay = 30 * time.Second
+
+// Casters lists the account usernames allowed to watch in reveal mode.
+// Fleets never move once placed, so a delayed reveal feed still gives a
+// player the opponent's fleet; only trusted casters and the organizer of a
+// tournament they do not play in get one.
+var Casters []string
+
+// canReveal reports whether p may watch matchID with both fleets shown.
+func canReveal(p *Player, matchID string) bool {
+	if p.account != "" {
+		for _, name := range Casters {
+			if strings.EqualFold(strings.TrimSpace(name), p.account) {
+				return true
+			}
+		}
+	}
+	return organizesMatch(p.ID, matchID)
+}
+
+// spectator is one connection watching a match. Fog spectators get events
+// as they happen; reveal spectators get every event, including full
+// boards, SpectatorDelay late.
+type spectator struct {
+	p      *Player
+	reveal bool
+	feed   chan delayedMsg
+}
+
+type delayedMsg struct {
+	at  time.Time
+	msg protocol.Message
+}
+
+func (s *spectator) deliver(m protocol.Message) {
+	if !s.reveal {
+		s.p.sendMsg(m)
+		return
+	}
+	select {
+	case s.feed <- delayedMsg{at: time.Now().Add(SpectatorDelay), msg: m}:
+	default:
+		log.Println("spectator: delayed feed full for", s.p.ID)
+	}
+}
+
+func (s *spectator) run() {
+	for d := range s.feed {
+		time.Sleep(time.Until(d.at))
+		s.p.sendMsg(d.msg)
+	}
+}
+
+// addSpectator subscribes p to the match. Callers hold g.mu.
+func (g *GameState) addSpectator(p *Player, reveal bool) error {
+	if _, seated := g.sideOf(p.ID); seated {
+		return errors.New("already_in_match")
+	}
+	if g.Game.State().Over() {
+		return &game.PhaseError{Phase: g.Game.State()}
+	}
+	if reveal && SpectatorDelay <= 0 {
+		return errors.New("reveal_disabled")
+	}
+	if g.spectators == nil {
+		g.spectators = make(map[string]*spectator)
+	}
+	if old, ok := g.spectators[p.ID]; ok {
+		old.close()
+	}
+	s := &spectator{p: p, reveal: reveal}
+	if reveal {
+		s.feed = make(chan delayedMsg, 256)
+		go s.run()
+	}
+	g.spectators[p.ID] = s
+	s.deliver(g.spectateState(reveal))
+	g.spectatorCountChanged()
+	return nil
+}
+
+// removeSpectator drops p from the match. Callers hold g.mu.
+func (g *GameState) removeSpectator(playerID string) bool {
+	s, ok := g.spectators[playerID]
+	if !ok {
+		return false
+	}
+	s.close()
+	delete(g.spectators, playerID)
+	g.spectatorCountChanged()
+	return true
+}
+
+func (s *spectator) close() {
+	if s.feed != nil {
+		close(s.feed)
+	}
+}
+
+// cast sends m to every spectator. It must only carry fog-safe events;
+// anything revealing unshot ships goes through castState. Callers hold
+// g.mu.
+func (g *GameState) cast(m protocol.Message) {
+	for _, s := range g.spectators {
+		s.deliver(m)
+	}
+}
+
+// castState sends each spectator a fresh view of the match in its mode.
+// Callers hold g.mu.
+func (g *GameState) castState() {
+	if len(g.spectators) == 0 {
+		return
+	}
+	fog := g.spectateState(false)
+	full := g.spectateState(true)
+	for _, s := range g.spectators {
+		if s.reveal {
+			s.deliver(full)
+		} else {
+			s.deliver(fog)
+		}
+	}
+}
+
+// spectatorCountChanged tells players and spectators how many are
+// watching. Callers hold g.mu.
+func (g *GameState) spectatorCountChanged() {
+	msg := protocol.SpectatorCount{MatchID: g.MatchID, Count: len(g.spectators)}
+	g.broadcast(msg)
+	for _, s := range g.spectators {
+		s.p.sendMsg(msg)
+	}
+}
+
+// closeSpectators ends every feed once the match leaves memory. Callers
+// hold g.mu.
+func (g *GameState) closeSpectators() {
+	for id, s := range g.spectators {
+		s.close()
+		delete(g.spectators, id)
+	}
+}
+
+// spectateState describes the match to a spectator. Fogged boards never
+// include unshot ship cells; reveal boards show both fleets. Callers hold
+// g.mu.
+func (g *GameState) spectateState(reveal bool) protocol.SpectateState {
+	state := g.Game.State()
+	msg := protocol.SpectateState{
+		MatchID:    g.MatchID,
+		Reveal:     reveal,
+		Phase:      state.String(),
+		PlayerAID:  g.PlayerAID,
+		PlayerBID:  g.PlayerBID,
+		Boards:     map[string][][]game.Cell{},
+		SunkShips:  []protocol.SunkShip{},
+		Spectators: len(g.spectators),
+		Rules:      g.Game.Rules(),
+	}
+	if reveal {
+		msg.DelayMs = SpectatorDelay.Milliseconds()
+	}
+	if a, ok := GetPlayer(g.PlayerAID); ok {
+		msg.PlayerAName = a.Name
+	}
+	if b, ok := GetPlayer(g.PlayerBID); ok {
+		msg.PlayerBName = b.Name
+	}
+	if state == game.Battle || state.Over() {
+		msg.Turn = string(wireSide(g.Game.Turn()))
+	}
+	fog := !reveal && !state.Over()
+	for _, side := range []game.PlayerSide{game.SideA, game.SideB} {
+		id := g.playerOn(side)
+		msg.Boards[id] = boardCells(g.Game.Board(side), fog)
+		for _, ship := range g.Game.Ships(side) {
+			if ship.Sunk() {
+				typ := ship.Type
+				if fog {
+					typ = g.publicShipType(typ)
+				}
+				msg.SunkShips = append(msg.SunkShips, protocol.SunkShip{OwnerID: id, ShipType: typ})
+			}
+		}
+	}
+	return msg
+}
+
+// unspectateAll drops p from every match it is watching.
+func unspectateAll(p *Player) {
+	gamesMu.RLock()
+	all := make([]*GameState, 0, len(games))
+	for _, g := range games {
+		all = append(all, g)
+	}
+	gamesMu.RUnlock()
+
+	for _, g := range all {
+		g.mu.Lock()
+		g.removeSpectator(p.ID)
+		g.mu.Unlock()
+	}
+}
+
+func handleSpectate(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.Spectate)
+	g, err := lookupMatch(m.MatchID)
+	if err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "spectate"})
+		return
+	}
+	if m.Reveal && !canReveal(p, m.MatchID) {
+		p.sendMsg(protocol.Error{Error: "reveal_not_allowed", For: "spectate"})
+		return
+	}
+	g.mu.Lock()
+	err = g.addSpectator(p, m.Reveal)
+	g.mu.Unlock()
+	if err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "spectate"})
+		return
+	}
+	sendChatHistory(p, ChannelSpectators, m.MatchID)
+	log.Println("handleSpectate:", p.ID, "watching", m.MatchID, "reveal", m.Reveal)
+}
+
+func handleStopSpectating(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.StopSpectating)
+	g, ok := GetGameState(m.MatchID)
+	if !ok {
+		return
+	}
+	g.mu.Lock()
+	g.removeSpectator(p.ID)
+	g.mu.Unlock()
+}
@@ -0,0 +1,130 @@
+package ws
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+)
+
+// spectateStates drains p's spectate_state messages.
+func spectateStates(t *testing.T, p *Player) []protocol.SpectateState {
+	t.Helper()
+	var out []protocol.SpectateState
+	for _, raw := range sent(p, "spectate_state") {
+		var m protocol.SpectateState
+		if err := json.Unmarshal(raw, &m); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// shipCells counts the unshot ship cells shown on every board of m.
+func shipCells(m protocol.SpectateState) int {
+	n := 0
+	for _, board := range m.Boards {
+		for _, row := range board {
+			for _, c := range row {
+				if c == game.Ship {
+					n++
+				}
+			}
+		}
+	}
+	return n
+}
+
+func TestSpectatorFog(t *testing.T) {
+	a, b, g := testBattle(t, game.DefaultRules())
+	if _, err := ProcessShot(g.MatchID, a.ID, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	s := testPlayer(t)
+	handleSpectate(s, &protocol.Spectate{MatchID: g.MatchID})
+	t.Cleanup(func() { unspectateAll(s) })
+
+	states := spectateStates(t, s)
+	if len(states) == 0 {
+		t.Fatal("fog spectator got no spectate_state")
+	}
+	for _, m := range states {
+		if m.Reveal {
+			t.Error("fog spectator got a reveal state")
+		}
+		if n := shipCells(m); n != 0 {
+			t.Errorf("fog state shows %d unshot ship cells", n)
+		}
+	}
+	if last := states[len(states)-1]; last.Boards[b.ID][0][0] != game.Hit {
+		t.Errorf("fog state hides the hit at 0,0: %v", last.Boards[b.ID][0][0])
+	}
+}
+
+func TestSpectatorRevealGating(t *testing.T) {
+	defer func(c []string) { Casters = c }(Casters)
+	Casters = []string{" Caster "}
+	_, _, g := testBattle(t, game.DefaultRules())
+
+	guest := testPlayer(t)
+	handleSpectate(guest, &protocol.Spectate{MatchID: g.MatchID, Reveal: true})
+	var e protocol.Error
+	if msgs := sent(guest, "error"); len(msgs) != 1 || json.Unmarshal(msgs[0], &e) != nil {
+		t.Fatalf("guest asking for reveal got %d errors, want 1", len(msgs))
+	}
+	if e.Error != "reveal_not_allowed" {
+		t.Errorf("guest asking for reveal: got %q, want reveal_not_allowed", e.Error)
+	}
+
+	caster := testPlayer(t)
+	caster.account = "caster"
+	if !canReveal(caster, g.MatchID) {
+		t.Fatal("listed caster may not reveal")
+	}
+	other := testPlayer(t)
+	other.account = "someone"
+	if canReveal(other, g.MatchID) {
+		t.Error("an account that is not a caster may reveal")
+	}
+
+	defer func(d time.Duration) { SpectatorDelay = d }(SpectatorDelay)
+	SpectatorDelay = 0
+	g.mu.Lock()
+	err := g.addSpectator(caster, true)
+	g.mu.Unlock()
+	if err == nil || err.Error() != "reveal_disabled" {
+		t.Errorf("reveal with no delay: got %v, want reveal_disabled", err)
+	}
+}
+
+func TestSpectatorRevealIsDelayed(t *testing.T) {
+	defer func(d time.Duration) { SpectatorDelay = d }(SpectatorDelay)
+	SpectatorDelay = 100 * time.Millisecond
+	_, _, g := testBattle(t, game.DefaultRules())
+	s := testPlayer(t)
+	g.mu.Lock()
+	if err := g.addSpectator(s, true); err != nil {
+		g.mu.Unlock()
+		t.Fatal(err)
+	}
+	g.mu.Unlock()
+	t.Cleanup(func() { unspectateAll(s) })
+
+	if got := spectateStates(t, s); len(got) != 0 {
+		t.Fatalf("reveal state arrived before the delay")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if got := spectateStates(t, s); len(got) > 0 {
+			if !got[0].Reveal || shipCells(got[0]) == 0 {
+				t.Errorf("reveal state hides the fleets: reveal=%v ships=%d", got[0].Reveal, shipCells(got[0]))
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("reveal state never arrived")
+}
@@ -0,0 +1,525 @@
+package ws
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"battleship-go/internal/game"
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/tournament"
+
+	"github.com/google/uuid"
+)
+
+// TournamentNoShow is how long a pairing that is due waits for both players
+// to be connected and free. Whoever is still missing then forfeits.
+var TournamentNoShow = 2 * time.Minute
+
+// TournamentInviteTTL is how long the players of a new tournament have to
+// accept before it is called off.
+var TournamentInviteTTL = 2 * time.Minute
+
+// TournamentLinger keeps a finished or cancelled tournament listed before
+// it is dropped.
+var TournamentLinger = 10 * time.Minute
+
+// MaxOpenTournaments caps the tournaments one organizer may have inviting
+// or running at once.
+var MaxOpenTournaments = 3
+
+const (
+	tournamentInviting  = "inviting"
+	tournamentRunning   = "running"
+	tournamentFinished  = "finished"
+	tournamentCancelled = "cancelled"
+)
+
+// tournament_update events.
+const (
+	tournamentCreated      = "created"
+	tournamentAccepted     = "accepted"
+	tournamentDeclined     = "declined"
+	tournamentStarted      = "started"
+	tournamentMatchStarted = "match_started"
+	tournamentResult       = "result"
+	tournamentRoundStarted = "round_started"
+	tournamentEnded        = "finished"
+	tournamentStopped      = "cancelled"
+)
+
+const maxTournamentName = 40
+
+// tournamentRun drives one tournament: it starts a series for each due
+// pairing and feeds the results back into the bracket.
+type tournamentRun struct {
+	ID          string
+	Name        string
+	OrganizerID string
+	Rules       game.RuleSet
+	// BestOf is the length of every pairing's series.
+	BestOf int
+	t      *tournament.Tournament
+	state  string
+	// names remembers each player's name for when they are offline.
+	names map[string]string
+	// series maps pairings to the series playing them.
+	series map[string]*series
+	// noShow holds the forfeit timers of due pairings not yet started.
+	noShow map[string]*time.Timer
+	// accepted marks the players who agreed to play.
+	accepted map[string]bool
+	// invite calls the tournament off if not everyone accepts in time.
+	invite *time.Timer
+}
+
+var (
+	// tournamentsMu guards every run. It is taken before the seat, series,
+	// match, player and lobby locks, never while holding them.
+	tournamentsMu sync.Mutex
+	tournaments   = map[string]*tournamentRun{}
+)
+
+func handleTournamentCreate(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.TournamentCreate)
+	run, err := createTournament(p, m)
+	if err != nil {
+		p.sendMsg(protocol.Error{Error: err.Error(), For: "tournament_create"})
+		return
+	}
+	log.Println("handleTournamentCreate:", p.ID, "opened", run.ID, run.t.Format, len(run.t.Players), "players")
+}
+
+func createTournament(p *Player, m *protocol.TournamentCreate) (*tournamentRun, error) {
+	name := strings.TrimSpace(m.Name)
+	if name == "" {
+		name = "Tournament"
+	}
+	if utf8.RuneCountInString(name) > maxTournamentName {
+		return nil, errors.New("name_too_long")
+	}
+	rules := game.DefaultRules()
+	if m.Rules != nil {
+		rules = *m.Rules
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	if err := checkBestOf(m.BestOf); err != nil {
+		return nil, err
+	}
+	bestOf := m.BestOf
+	if bestOf == 0 {
+		bestOf = 1
+	}
+	names := map[string]string{}
+	// the organizer and bots need not confirm; everyone else is invited
+	accepted := map[string]bool{}
+	for _, id := range m.Players {
+		pl, ok := GetPlayer(id)
+		if !ok {
+			return nil, errors.New("unknown_player:" + id)
+		}
+		names[id] = pl.Name
+		accepted[id] = id == p.ID || pl.bot != ""
+	}
+	t, err := tournament.New(m.Format, m.Players, m.Rounds)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &tournamentRun{
+		ID:          uuid.NewString(),
+		Name:        name,
+		OrganizerID: p.ID,
+		Rules:       rules,
+		BestOf:      bestOf,
+		t:           t,
+		state:       tournamentInviting,
+		names:       names,
+		series:      map[string]*series{},
+		noShow:      map[string]*time.Timer{},
+		accepted:    accepted,
+	}
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	open := 0
+	for _, other := range tournaments {
+		if other.OrganizerID == p.ID && other.open() {
+			open++
+		}
+	}
+	if open >= MaxOpenTournaments {
+		return nil, errors.New("too_many_tournaments")
+	}
+	tournaments[run.ID] = run
+	id := run.ID
+	run.invite = time.AfterFunc(TournamentInviteTTL, func() { tournamentInviteExpired(id) })
+	run.cast(tournamentCreated, "")
+	run.startIfAccepted()
+	return run, nil
+}
+
+func handleTournamentResponse(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.TournamentResponse)
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	run, ok := tournaments[m.TournamentID]
+	if !ok {
+		p.sendMsg(protocol.Error{Error: "tournament_not_found", For: "tournament_response"})
+		return
+	}
+	if _, entered := run.accepted[p.ID]; !entered {
+		p.sendMsg(protocol.Error{Error: "not_entered", For: "tournament_response"})
+		return
+	}
+	if run.state != tournamentInviting {
+		p.sendMsg(protocol.Error{Error: "invitation_closed", For: "tournament_response"})
+		return
+	}
+	if !m.Accept {
+		run.stop(tournamentCancelled)
+		run.cast(tournamentDeclined, "")
+		log.Println("handleTournamentResponse:", p.ID, "declined", run.ID)
+		return
+	}
+	run.accepted[p.ID] = true
+	run.cast(tournamentAccepted, "")
+	run.startIfAccepted()
+}
+
+// startIfAccepted starts play once every player has accepted. Callers hold
+// tournamentsMu.
+func (run *tournamentRun) startIfAccepted() {
+	if run.state != tournamentInviting {
+		return
+	}
+	for _, ok := range run.accepted {
+		if !ok {
+			return
+		}
+	}
+	run.invite.Stop()
+	run.state = tournamentRunning
+	run.cast(tournamentStarted, "")
+	run.schedule()
+}
+
+// tournamentInviteExpired calls off a tournament whose players did not all
+// accept in time.
+func tournamentInviteExpired(tournamentID string) {
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	run, ok := tournaments[tournamentID]
+	if !ok || run.state != tournamentInviting {
+		return
+	}
+	run.stop(tournamentCancelled)
+	run.cast(tournamentStopped, "")
+	log.Println("tournamentInviteExpired:", run.ID)
+}
+
+func handleTournamentCancel(p *Player, msg protocol.Message) {
+	m := msg.(*protocol.TournamentCancel)
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	run, ok := tournaments[m.TournamentID]
+	switch {
+	case !ok:
+		p.sendMsg(protocol.Error{Error: "tournament_not_found", For: "tournament_cancel"})
+		return
+	case run.OrganizerID != p.ID:
+		p.sendMsg(protocol.Error{Error: "not_organizer", For: "tournament_cancel"})
+		return
+	case !run.open():
+		p.sendMsg(protocol.Error{Error: "tournament_over", For: "tournament_cancel"})
+		return
+	}
+	// series already under way are played out but no longer count
+	run.stop(tournamentCancelled)
+	run.cast(tournamentStopped, "")
+	log.Println("handleTournamentCancel:", run.ID)
+}
+
+// organizesMatch reports whether playerID organizes the tournament matchID
+// was played for without being entered in it.
+func organizesMatch(playerID, matchID string) bool {
+	s := seriesOf(matchID)
+	if s == nil || s.TournamentID == "" {
+		return false
+	}
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	run, ok := tournaments[s.TournamentID]
+	if !ok || run.OrganizerID != playerID {
+		return false
+	}
+	for _, id := range run.t.Players {
+		if id == playerID {
+			return false
+		}
+	}
+	return true
+}
+
+// tournamentAvailable returns the player for id if it can start a match now.
+// Bots play several matches at once; humans need to be connected and free.
+func tournamentAvailable(id string) (*Player, bool) {
+	p, ok := GetPlayer(id)
+	if !ok {
+		return nil, false
+	}
+	if p.bot != "" {
+		return p, true
+	}
+	return p, p.Connected() && !inLiveMatch(id)
+}
+
+// schedule starts a series for every due pairing whose players are both
+// available and arms the no-show timer of the rest. Callers hold
+// tournamentsMu.
+func (run *tournamentRun) schedule() {
+	if run.state != tournamentRunning {
+		return
+	}
+	seatMu.Lock()
+	defer seatMu.Unlock()
+	for _, pr := range run.t.Ready() {
+		if _, started := run.series[pr.ID]; started {
+			continue
+		}
+		a, aok := tournamentAvailable(pr.PlayerA)
+		b, bok := tournamentAvailable(pr.PlayerB)
+		if aok && bok {
+			if t, ok := run.noShow[pr.ID]; ok {
+				t.Stop()
+				delete(run.noShow, pr.ID)
+			}
+			id, pairingID := run.ID, pr.ID
+			s := startSeries(a, b, run.BestOf, run.Rules, run.ID, func(s *series, winnerID, reason string) {
+				tournamentSeriesOver(id, pairingID, s, winnerID, reason)
+			})
+			run.series[pr.ID] = s
+			log.Println("tournament:", run.ID, "pairing", pr.ID, "is series", s.ID)
+			run.cast(tournamentMatchStarted, pr.ID)
+			continue
+		}
+		if _, armed := run.noShow[pr.ID]; !armed {
+			id, pairingID := run.ID, pr.ID
+			run.noShow[pr.ID] = time.AfterFunc(TournamentNoShow, func() { tournamentNoShow(id, pairingID) })
+		}
+	}
+}
+
+// tournamentNoShow settles a pairing whose players did not both turn up in
+// time: an absent player forfeits, and if neither is there both do.
+func tournamentNoShow(tournamentID, pairingID string) {
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	run, ok := tournaments[tournamentID]
+	if !ok || run.state != tournamentRunning {
+		return
+	}
+	delete(run.noShow, pairingID)
+	if _, started := run.series[pairingID]; started {
+		return
+	}
+	pr, ok := run.t.Pairing(pairingID)
+	if !ok || !pr.Ready() {
+		return
+	}
+	_, aok := tournamentAvailable(pr.PlayerA)
+	_, bok := tournamentAvailable(pr.PlayerB)
+	var err error
+	switch {
+	case aok && bok:
+		run.schedule()
+		return
+	case aok:
+		err = run.t.Record(pairingID, pr.PlayerA, tournament.ResultForfeit)
+	case bok:
+		err = run.t.Record(pairingID, pr.PlayerB, tournament.ResultForfeit)
+	default:
+		err = run.t.Record(pairingID, "", tournament.ResultDoubleForfeit)
+	}
+	if err != nil {
+		log.Println("tournamentNoShow:", run.ID, pairingID, err)
+		return
+	}
+	log.Println("tournamentNoShow:", run.ID, "pairing", pairingID, "decided by no-show")
+	run.recorded(pairingID)
+}
+
+// tournamentSeriesOver feeds the result of a pairing's series into its
+// tournament.
+func tournamentSeriesOver(tournamentID, pairingID string, s *series, winnerID, reason string) {
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	run, ok := tournaments[tournamentID]
+	if !ok || run.state != tournamentRunning || run.series[pairingID] != s {
+		return
+	}
+	if winnerID == "" {
+		// an abandoned series decides nothing; play the pairing again
+		delete(run.series, pairingID)
+		log.Println("tournament:", run.ID, "pairing", pairingID, "abandoned, replaying")
+		return
+	}
+	result := tournament.ResultWin
+	if reason == seriesForfeit {
+		result = tournament.ResultForfeit
+	}
+	if err := run.t.Record(pairingID, winnerID, result); err != nil {
+		log.Println("tournament:", run.ID, "recording", pairingID, err)
+		return
+	}
+	run.recorded(pairingID)
+}
+
+// recorded announces a new result and moves the tournament on.
+func (run *tournamentRun) recorded(pairingID string) {
+	round := run.t.Round
+	run.cast(tournamentResult, pairingID)
+	if run.t.Finished() {
+		run.stop(tournamentFinished)
+		run.cast(tournamentEnded, "")
+		log.Println("tournament:", run.ID, "finished, winner", run.t.Winner())
+		return
+	}
+	if run.t.Round != round {
+		run.cast(tournamentRoundStarted, "")
+	}
+	run.schedule()
+}
+
+// open reports whether the tournament is still inviting or running.
+func (run *tournamentRun) open() bool {
+	return run.state == tournamentInviting || run.state == tournamentRunning
+}
+
+// stop ends the tournament and drops it once TournamentLinger has passed.
+// Callers hold tournamentsMu.
+func (run *tournamentRun) stop(state string) {
+	run.state = state
+	run.invite.Stop()
+	for id, t := range run.noShow {
+		t.Stop()
+		delete(run.noShow, id)
+	}
+	id := run.ID
+	time.AfterFunc(TournamentLinger, func() {
+		tournamentsMu.Lock()
+		delete(tournaments, id)
+		tournamentsMu.Unlock()
+	})
+}
+
+// scheduleTournaments retries every running tournament, e.g. once a player
+// (re)joins.
+func scheduleTournaments() {
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	for _, run := range tournaments {
+		run.schedule()
+	}
+}
+
+// cast sends a tournament_update to the organizer and every player.
+func (run *tournamentRun) cast(event, pairingID string) {
+	msg := protocol.TournamentUpdate{Event: event, PairingID: pairingID, Tournament: run.info()}
+	sent := map[string]bool{}
+	for _, id := range append([]string{run.OrganizerID}, run.t.Players...) {
+		if sent[id] {
+			continue
+		}
+		sent[id] = true
+		if p, ok := GetPlayer(id); ok && p.bot == "" {
+			p.sendMsg(msg)
+		}
+	}
+}
+
+func (run *tournamentRun) info() protocol.Tournament {
+	out := protocol.Tournament{
+		ID:          run.ID,
+		Name:        run.Name,
+		Format:      run.t.Format,
+		State:       run.state,
+		OrganizerID: run.OrganizerID,
+		Rules:       run.Rules,
+		BestOf:      run.BestOf,
+		Round:       run.t.Round,
+		Rounds:      run.t.Rounds,
+		WinnerID:    run.t.Winner(),
+		Standings:   run.t.Standings(),
+	}
+	for i, id := range run.t.Players {
+		if p, ok := GetPlayer(id); ok {
+			run.names[id] = p.Name
+		}
+		out.Players = append(out.Players, protocol.TournamentPlayer{ID: id, Name: run.names[id], Seed: i + 1, Accepted: run.accepted[id]})
+	}
+	for _, pr := range run.t.Pairings() {
+		entry := protocol.TournamentPairing{Pairing: pr}
+		if s, ok := run.series[pr.ID]; ok {
+			entry.SeriesID = s.ID
+			entry.Score, entry.MatchID = s.state()
+		}
+		out.Pairings = append(out.Pairings, entry)
+	}
+	return out
+}
+
+// tournamentInfo returns the state of one tournament.
+func tournamentInfo(id string) (protocol.Tournament, bool) {
+	tournamentsMu.Lock()
+	defer tournamentsMu.Unlock()
+	run, ok := tournaments[id]
+	if !ok {
+		return protocol.Tournament{}, false
+	}
+	return run.info(), true
+}
+
+// tournamentSummary is a tournament listing entry.
+type tournamentSummary struct {
+	ID       string            `json:"id"`
+	Name     string            `json:"name"`
+	Format   tournament.Format `json:"format"`
+	State    string            `json:"state"`
+	Players  int               `json:"players"`
+	Round    int               `json:"round,omitempty"`
+	Rounds   int               `json:"rounds,omitempty"`
+	WinnerID string            `json:"winner_id,omitempty"`
+}
+
+// listTournaments summarises every tournament, open ones first.
+func listTournaments() []tournamentSummary {
+	tournamentsMu.Lock()
+	out := make([]tournamentSummary, 0, len(tournaments))
+	for _, run := range tournaments {
+		out = append(out, tournamentSummary{
+			ID:       run.ID,
+			Name:     run.Name,
+			Format:   run.t.Format,
+			State:    run.state,
+			Players:  len(run.t.Players),
+			Round:    run.t.Round,
+			Rounds:   run.t.Rounds,
+			WinnerID: run.t.Winner(),
+		})
+	}
+	tournamentsMu.Unlock()
+	sort.Slice(out, func(i, j int) bool {
+		oi, oj := out[i].State == tournamentInviting || out[i].State == tournamentRunning, out[j].State == tournamentInviting || out[j].State == tournamentRunning
+		if oi != oj {
+			return oi
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
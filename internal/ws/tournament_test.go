@@ -0,0 +1,152 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"battleship-go/internal/protocol"
+	"battleship-go/internal/tournament"
+)
+
+// testTournament creates a single elimination tournament organized by the
+// first player and cancels it when the test ends.
+func testTournament(t *testing.T, players ...*Player) *tournamentRun {
+	t.Helper()
+	m := &protocol.TournamentCreate{Format: tournament.SingleElimination}
+	for _, p := range players {
+		m.Players = append(m.Players, p.ID)
+	}
+	run, err := createTournament(players[0], m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		tournamentsMu.Lock()
+		defer tournamentsMu.Unlock()
+		if run.open() {
+			run.stop(tournamentCancelled)
+		}
+	})
+	return run
+}
+
+// events lists the tournament_update events queued for p.
+func events(p *Player) []string {
+	var out []string
+	for _, raw := range sent(p, "tournament_update") {
+		var m protocol.TournamentUpdate
+		json.Unmarshal(raw, &m)
+		out = append(out, m.Event)
+	}
+	return out
+}
+
+func TestTournamentInvitations(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept []bool // answers of the invited players, in order
+		state  string
+		last   string
+		bots   bool
+	}{
+		{"everyone accepts", []bool{true, true}, tournamentRunning, tournamentStarted, false},
+		{"one declines", []bool{true, false}, tournamentCancelled, tournamentDeclined, false},
+		{"still waiting", []bool{true}, tournamentInviting, tournamentAccepted, false},
+		{"bots need not accept", nil, tournamentRunning, tournamentStarted, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			org := testPlayer(t)
+			entrants := []*Player{org}
+			if tt.bots {
+				// the organizer is not connected, so play waits on the no-show timer
+				bot := testPlayer(t)
+				bot.bot = "easy"
+				entrants = append(entrants, bot)
+			} else {
+				entrants = append(entrants, testPlayer(t), testPlayer(t))
+			}
+			run := testTournament(t, entrants...)
+			for i, accept := range tt.accept {
+				handleTournamentResponse(entrants[i+1], &protocol.TournamentResponse{TournamentID: run.ID, Accept: accept})
+			}
+			tournamentsMu.Lock()
+			state := run.state
+			tournamentsMu.Unlock()
+			if state != tt.state {
+				t.Errorf("state = %q, want %q", state, tt.state)
+			}
+			got := events(org)
+			if len(got) == 0 || got[len(got)-1] != tt.last {
+				t.Errorf("organizer saw %v, want it to end with %q", got, tt.last)
+			}
+		})
+	}
+}
+
+func TestTournamentResponseErrors(t *testing.T) {
+	org, a, outsider := testPlayer(t), testPlayer(t), testPlayer(t)
+	run := testTournament(t, org, a)
+	tests := []struct {
+		name string
+		p    *Player
+		id   string
+		err  string
+	}{
+		{"unknown tournament", a, "nope", "tournament_not_found"},
+		{"not entered", outsider, run.ID, "not_entered"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handleTournamentResponse(tt.p, &protocol.TournamentResponse{TournamentID: tt.id, Accept: true})
+			if errs := sent(tt.p, "error"); len(errs) != 1 || !strings.Contains(string(errs[0]), tt.err) {
+				t.Errorf("errors = %s, want %s", errs, tt.err)
+			}
+		})
+	}
+	handleTournamentResponse(a, &protocol.TournamentResponse{TournamentID: run.ID, Accept: true})
+	handleTournamentResponse(a, &protocol.TournamentResponse{TournamentID: run.ID, Accept: false})
+	if errs := sent(a, "error"); len(errs) != 1 || !strings.Contains(string(errs[0]), "invitation_closed") {
+		t.Errorf("answering a started tournament gave %s", errs)
+	}
+}
+
+func TestTournamentCap(t *testing.T) {
+	defer func(n int) { MaxOpenTournaments = n }(MaxOpenTournaments)
+	MaxOpenTournaments = 2
+	org, a := testPlayer(t), testPlayer(t)
+	first := testTournament(t, org, a)
+	testTournament(t, org, a)
+	m := &protocol.TournamentCreate{Format: tournament.SingleElimination, Players: []string{org.ID, a.ID}}
+	if _, err := createTournament(org, m); err == nil || err.Error() != "too_many_tournaments" {
+		t.Fatalf("third tournament: %v", err)
+	}
+	// an ended tournament no longer counts
+	tournamentsMu.Lock()
+	first.stop(tournamentCancelled)
+	tournamentsMu.Unlock()
+	testTournament(t, org, a)
+}
+
+func TestTournamentInviteExpiry(t *testing.T) {
+	defer func(ttl, linger time.Duration) { TournamentInviteTTL, TournamentLinger = ttl, linger }(TournamentInviteTTL, TournamentLinger)
+	TournamentInviteTTL, TournamentLinger = 10*time.Millisecond, 30*time.Millisecond
+	org, a := testPlayer(t), testPlayer(t)
+	run := testTournament(t, org, a)
+
+	time.Sleep(2 * TournamentInviteTTL)
+	if info, _ := tournamentInfo(run.ID); info.State != tournamentCancelled {
+		t.Fatalf("state after the invitation lapsed = %q", info.State)
+	}
+	if got := events(a); len(got) == 0 || got[len(got)-1] != tournamentStopped {
+		t.Errorf("invited player saw %v", got)
+	}
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if _, ok := tournamentInfo(run.ID); !ok {
+			return
+		}
+	}
+	t.Error("ended tournament never dropped")
+}